@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/progress/tui"
+)
+
+// Renders the standard scenes at a small size and reports how many rays per second were traced. Returns the exit code.
+func bench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	sceneList := fs.String("S", "1,3,6,7", "Comma separated numbers of the built-in scenes to render")
+	threads := fs.Int("threads", runtime.NumCPU(), "Set the number of threads to allocate to rendering")
+	fs.IntVar(threads, "N", runtime.NumCPU(), "Shorthand for -threads")
+	width := fs.Int("width", 200, "Image width in pixels, the height follows from each scene's aspect ratio")
+	spp := fs.Int("spp", 16, "Samples per pixel")
+	seed := fs.Uint64("seed", 1, "Seed for the camera's sampler, so every run takes the same camera samples")
+	progressName := fs.String("progress", "auto", "How progress is reported: auto (a progress bar on a terminal, log lines otherwise), tui, log, json or none")
+	fs.Parse(args)
+	if *width <= 0 || *spp <= 0 {
+		log.Print("-width and -spp must be positive")
+		return 2
+	}
+	reporter, err := tui.ByName(*progressName, os.Stdout)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	var ids []int
+	for _, field := range strings.Split(*sceneList, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(field))
+		if _, ok := demoScenes[id]; err != nil || !ok {
+			log.Printf("unknown scene %q in -S", field)
+			return 2
+		}
+		ids = append(ids, id)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	type result struct {
+		id            int
+		width, height int
+		elapsed       time.Duration
+		rays          uint64
+	}
+	var results []result
+	for _, id := range ids {
+		c := camera.Camera{}
+		world, lights, _, err := loadScene("", id, &c)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		c.Width = *width
+		c.SamplesPerPixel = *spp
+		c.MaxThreads = *threads
+		c.Seed = *seed
+		c.Progress = reporter
+
+		start := time.Now()
+		if err := c.Render(ctx, world, lights); errors.Is(err, context.Canceled) {
+			log.Print("Benchmark interrupted")
+			return 130
+		} else if err != nil {
+			log.Print(err)
+			return 1
+		}
+		w, h := c.ImageSize()
+		results = append(results, result{id, w, h, time.Since(start), c.Rays()})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "scene\tsize\ttime\trays\trays/s")
+	var total time.Duration
+	var totalRays uint64
+	for _, r := range results {
+		fmt.Fprintf(w, "%d %s\t%dx%d\t%.2fs\t%d\t%s\n", r.id, demoScenes[r.id].name, r.width, r.height, r.elapsed.Seconds(), r.rays, rate(r.rays, r.elapsed))
+		total += r.elapsed
+		totalRays += r.rays
+	}
+	fmt.Fprintf(w, "total\t\t%.2fs\t%d\t%s\n", total.Seconds(), totalRays, rate(totalRays, total))
+	w.Flush()
+	fmt.Printf("%d threads, %d samples per pixel\n", *threads, *spp)
+	return 0
+}
+
+// Formats the number of rays traced per second with a metric suffix, e.g. 1.25M.
+func rate(rays uint64, elapsed time.Duration) string {
+	perSecond := float64(rays) / max(elapsed.Seconds(), 1e-9)
+	switch {
+	case perSecond >= 1e9:
+		return fmt.Sprintf("%.2fG", perSecond/1e9)
+	case perSecond >= 1e6:
+		return fmt.Sprintf("%.2fM", perSecond/1e6)
+	case perSecond >= 1e3:
+		return fmt.Sprintf("%.2fk", perSecond/1e3)
+	}
+	return fmt.Sprintf("%.0f", perSecond)
+}
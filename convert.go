@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/mesh"
+	"github.com/nsp5488/go_raytracer/internal/objLoader"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+)
+
+// Mesh formats convert can read and write, by file extension.
+var meshExtensions = map[string]string{".obj": "obj", ".ply": "ply"}
+
+// Converts an image or a mesh to another format. Returns the exit code.
+func convert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	format := fs.String("format", "", "Override the output format, an image format (ppm, png, png16, jpeg, exr, hdr) or a mesh format (obj, ply)")
+	binaryPLY := fs.Bool("binary", false, "Write PLY meshes in binary instead of ASCII")
+	tonemapOperator := fs.String("tonemap", "clamp", "Tone mapping operator when converting to 8 and 16-bit image formats (clamp, reinhard, reinhard-extended, aces, hable)")
+	exposure := fs.Float64("exposure", 0, "Exposure adjustment in stops, applied before tone mapping")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go-raytracer convert [flags] input output")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	var err error
+	if _, ok := meshExtensions[strings.ToLower(filepath.Ext(in))]; ok {
+		err = convertMesh(in, out, *format, *binaryPLY)
+	} else {
+		var op tonemap.Operator
+		if op, err = tonemap.ParseOperator(*tonemapOperator); err == nil {
+			err = convertImage(in, out, *format, tonemap.Mapper{Operator: op, Exposure: *exposure})
+		}
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// Reads an image in any supported format and writes it in the format given by name or by the output's extension.
+func convertImage(in, out, format string, mapper tonemap.Mapper) error {
+	fb, err := encoder.DecodeFile(in)
+	if err != nil {
+		return err
+	}
+	var enc encoder.Encoder
+	if format != "" {
+		enc, err = encoder.ByName(format)
+	} else {
+		enc, err = encoder.ForFile(out)
+	}
+	if err != nil {
+		return err
+	}
+	return writeImage(out, encoder.WithTonemap(enc, mapper), fb)
+}
+
+// Reads an OBJ or PLY mesh and writes it in the format given by name or by the output's extension.
+// Only the geometry is converted, OBJ materials are ignored.
+func convertMesh(in, out, format string, binaryPLY bool) error {
+	if format == "" {
+		var ok bool
+		if format, ok = meshExtensions[strings.ToLower(filepath.Ext(out))]; !ok {
+			return fmt.Errorf("cannot infer a mesh format from extension %q (supported: obj, ply)", filepath.Ext(out))
+		}
+	}
+	var write func(io.Writer, *mesh.Mesh) error
+	switch strings.ToLower(format) {
+	case "obj":
+		write = mesh.WriteOBJ
+	case "ply":
+		write = func(w io.Writer, m *mesh.Mesh) error { return mesh.WritePLY(w, m, binaryPLY) }
+	default:
+		return fmt.Errorf("unknown mesh format %q (supported: obj, ply)", format)
+	}
+
+	var m *mesh.Mesh
+	var err error
+	if meshExtensions[strings.ToLower(filepath.Ext(in))] == "obj" {
+		// keep the model where it is rather than centering it as the loader does by default
+		opts := objLoader.DefaultLoadOptions()
+		opts.Center = false
+		opts.Debug = nil
+		opts.IgnoreMtl = true
+		model, _, _, loadErr := objLoader.LoadObjWithOptions(in, opts)
+		if loadErr != nil {
+			return loadErr
+		}
+		m, err = mesh.FromHittable(model)
+	} else {
+		var file *os.File
+		if file, err = os.Open(in); err != nil {
+			return err
+		}
+		m, err = mesh.ReadPLY(bufio.NewReader(file))
+		file.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", in, err)
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	err = write(file, m)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		fmt.Printf("Wrote %d vertices and %d triangles to %s\n", len(m.Positions), len(m.Faces), out)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/cluster"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+)
+
+// Renders a scene with the workers connecting to addr, leaving the merged image in the camera's framebuffer.
+// Workers load the scene file, or a built-in scene exported to the scene format, with the camera's settings.
+func distribute(ctx context.Context, co *cluster.Coordinator, c *camera.Camera, world, lights hittable.Hittable, sceneFile, addr string) error {
+	var doc []byte
+	var dir string
+	var err error
+	if sceneFile != "" {
+		if doc, err = os.ReadFile(sceneFile); err != nil {
+			return err
+		}
+		dir = filepath.Dir(sceneFile)
+	} else {
+		var buf bytes.Buffer
+		if err := scene.Export(&buf, c, world, lights); err != nil {
+			return fmt.Errorf("the scene can't be sent to workers: %w", err)
+		}
+		doc, dir = buf.Bytes(), "."
+	}
+	// workers on the same machine resolve files from wherever they were started
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+	co.Job = cluster.NewJob(c, doc, dir)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("Waiting for workers on %s, %d passes of %d samples per pixel", ln.Addr(), co.Job.Passes, co.Job.PassSamples)
+	fb, err := co.Render(ctx, ln)
+	c.Framebuffer = fb
+	if err == nil {
+		log.Printf("The workers traced %d rays", co.Rays())
+	}
+	return err
+}
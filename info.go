@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+)
+
+// Prints statistics about a built-in scene or a scene file without rendering it. Returns the exit code.
+func info(args []string) int {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	sceneID := fs.Int("S", -1, "Describe the built-in scene with this number")
+	sceneFile := fs.String("scene", "", "Describe the scene in this JSON file, which can also be given as an argument")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: go-raytracer info [-S=n | scene.json]")
+		return 2
+	}
+	if fs.NArg() == 1 {
+		*sceneFile = fs.Arg(0)
+	}
+
+	c := camera.Camera{}
+	world, lights, _, err := loadScene(*sceneFile, *sceneID, &c)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	c.ApplyDefaults()
+	width, height := c.ImageSize()
+
+	name := *sceneFile
+	if name == "" {
+		name = fmt.Sprintf("built-in scene %d", *sceneID)
+		if demo, ok := demoScenes[*sceneID]; ok {
+			name += " (" + demo.name + ")"
+		}
+	}
+	stats := countShapes(world)
+	bbox := world.BBox()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "scene:\t%s\n", name)
+	fmt.Fprintf(w, "image:\t%dx%d, %d samples per pixel, max depth %d\n", width, height, c.SamplesPerPixel, c.MaxDepth)
+	fmt.Fprintf(w, "primitives:\t%d%s\n", stats.primitives(), stats.breakdown(hittable.SPHERE, hittable.QUAD, hittable.TRIANGLE))
+	fmt.Fprintf(w, "media:\t%d\n", stats.shapes[hittable.MEDIUM])
+	fmt.Fprintf(w, "materials:\t%d\n", len(stats.materials))
+	fmt.Fprintf(w, "lights:\t%d\n", countShapes(lights).primitives())
+	fmt.Fprintf(w, "bvh depth:\t%d\n", hittable.BVHDepth(world))
+	x, y, z := bbox.AxisInterval(0), bbox.AxisInterval(1), bbox.AxisInterval(2)
+	fmt.Fprintf(w, "bounds:\t(%g, %g, %g) to (%g, %g, %g)\n", x.Min, y.Min, z.Min, x.Max, y.Max, z.Max)
+	w.Flush()
+	return 0
+}
+
+// Counts of the objects a world is built from.
+type shapeStats struct {
+	shapes    map[hittable.Shape]int
+	materials map[hittable.Material]bool
+}
+
+// Counts the distinct objects and materials h is built from. Boxes are counted as their six quads.
+func countShapes(h hittable.Hittable) shapeStats {
+	stats := shapeStats{shapes: map[hittable.Shape]int{}, materials: map[hittable.Material]bool{}}
+	hittable.Walk(h, func(o hittable.Hittable) {
+		if d, ok := hittable.Describe(o); ok {
+			stats.shapes[d.Shape]++
+		}
+		if m := hittable.MaterialOf(o); m != nil {
+			stats.materials[m] = true
+		}
+	})
+	return stats
+}
+
+// Returns the number of primitives rays are intersected with.
+func (s shapeStats) primitives() int {
+	return s.shapes[hittable.SPHERE] + s.shapes[hittable.QUAD] + s.shapes[hittable.TRIANGLE]
+}
+
+// Lists the counts of the given shapes, e.g. " (2 spheres, 5 quads)", leaving out those which don't occur.
+func (s shapeStats) breakdown(shapes ...hittable.Shape) string {
+	names := map[hittable.Shape]string{hittable.SPHERE: "sphere", hittable.QUAD: "quad", hittable.TRIANGLE: "triangle"}
+	var parts []string
+	for _, shape := range shapes {
+		n := s.shapes[shape]
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+names[shape])
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, names[shape]))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
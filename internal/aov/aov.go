@@ -0,0 +1,160 @@
+package aov
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Kind selects an auxiliary output, each is taken from the first surface hit by a camera ray.
+type Kind uint8
+
+const (
+	_              Kind = iota
+	DEPTH               // distance from the camera along its viewing direction
+	NORMAL              // outward geometric normal in world space
+	SHADING_NORMAL      // normal used for shading, after vertex normal interpolation and facing the ray
+	ALBEDO              // surface color of the material, without lighting
+	POSITION            // world space position
+	OBJECT_ID           // index of the primitive hit, starting at 1
+	MATERIAL_ID         // index of the material hit, starting at 1
+	UV                  // texture coordinates
+)
+
+var names = map[Kind]string{
+	DEPTH:          "depth",
+	NORMAL:         "normal",
+	SHADING_NORMAL: "shading_normal",
+	ALBEDO:         "albedo",
+	POSITION:       "position",
+	OBJECT_ID:      "object_id",
+	MATERIAL_ID:    "material_id",
+	UV:             "uv",
+}
+
+// Returns the name of the output, used for file suffixes and EXR layer names.
+func (k Kind) String() string {
+	if name, ok := names[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", k)
+}
+
+// Returns the names of the channels the output stores in the X, Y and Z components of its framebuffer.
+func (k Kind) Channels() []string {
+	switch k {
+	case DEPTH:
+		return []string{"Z"}
+	case ALBEDO:
+		return []string{"R", "G", "B"}
+	case OBJECT_ID, MATERIAL_ID:
+		return []string{"id"}
+	case UV:
+		return []string{"U", "V"}
+	}
+	return []string{"X", "Y", "Z"}
+}
+
+// Reports whether the output holds identifiers, which are taken from a single sample rather than averaged.
+func (k Kind) IsID() bool {
+	return k == OBJECT_ID || k == MATERIAL_ID
+}
+
+// Parses a comma separated list of output names, e.g. "depth,normal,albedo". Each output may only be listed once.
+func ParseList(list string) ([]Kind, error) {
+	var kinds []Kind
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		kind, err := Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(kinds, kind) {
+			return nil, fmt.Errorf("output variable %q is listed more than once", kind)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// Parses the name of a single output.
+func Parse(name string) (Kind, error) {
+	for k, n := range names {
+		if strings.EqualFold(name, n) {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown output variable %q (supported: depth, normal, shading_normal, albedo, position, object_id, material_id, uv)", name)
+}
+
+// Remaps an output into displayable colors for formats without floating point channels:
+// normals are mapped into [0, 1], depth and position are normalized by their range and IDs get distinct colors.
+func Visualize(k Kind, fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	lo, hi := bounds(fb)
+	out := framebuffer.New(fb.Width, fb.Height)
+	for y := range fb.Height {
+		for x := range fb.Width {
+			n := fb.Samples(x, y)
+			if n == 0 {
+				continue
+			}
+			c := fb.Color(x, y)
+			switch k {
+			case NORMAL, SHADING_NORMAL:
+				c = c.Scale(.5).Add(vec.New(.5, .5, .5))
+			case DEPTH:
+				c = c.Scale(1 / max(hi.X(), math.SmallestNonzeroFloat64))
+			case POSITION:
+				c = vec.New(normalize(c.X(), lo.X(), hi.X()), normalize(c.Y(), lo.Y(), hi.Y()), normalize(c.Z(), lo.Z(), hi.Z()))
+			case OBJECT_ID, MATERIAL_ID:
+				c = idColor(int(c.X()))
+			}
+			out.Set(x, y, c, n)
+		}
+	}
+	return out
+}
+
+// Returns the per component minimum and maximum over the sampled pixels of a framebuffer.
+func bounds(fb *framebuffer.Framebuffer) (lo, hi *vec.Vec3) {
+	lo = vec.New(math.Inf(1), math.Inf(1), math.Inf(1))
+	hi = vec.New(math.Inf(-1), math.Inf(-1), math.Inf(-1))
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if fb.Samples(x, y) == 0 {
+				continue
+			}
+			c := fb.Color(x, y)
+			lo = vec.New(min(lo.X(), c.X()), min(lo.Y(), c.Y()), min(lo.Z(), c.Z()))
+			hi = vec.New(max(hi.X(), c.X()), max(hi.Y(), c.Y()), max(hi.Z(), c.Z()))
+		}
+	}
+	return lo, hi
+}
+
+func normalize(v, lo, hi float64) float64 {
+	if hi <= lo {
+		return 0
+	}
+	return (v - lo) / (hi - lo)
+}
+
+// Picks a bright color for an ID by hashing it, 0 (nothing hit) stays black.
+func idColor(id int) *vec.Vec3 {
+	if id <= 0 {
+		return vec.Empty()
+	}
+	h := uint32(id) * 2654435761
+	return vec.New(
+		.2+.8*float64(h&0xff)/255,
+		.2+.8*float64(h>>8&0xff)/255,
+		.2+.8*float64(h>>16&0xff)/255,
+	)
+}
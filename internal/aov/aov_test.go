@@ -0,0 +1,64 @@
+package aov_test
+
+import (
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/aov"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func TestParseList(t *testing.T) {
+	kinds, err := aov.ParseList("depth, Normal,object_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []aov.Kind{aov.DEPTH, aov.NORMAL, aov.OBJECT_ID}
+	if len(kinds) != len(exp) {
+		t.Fatalf("Expected %v, but got %v", exp, kinds)
+	}
+	for i := range exp {
+		if kinds[i] != exp[i] {
+			t.Errorf("Expected %v, but got %v", exp[i], kinds[i])
+		}
+	}
+	if kinds, err := aov.ParseList(""); err != nil || len(kinds) != 0 {
+		t.Errorf("Expected no outputs, but got %v (%v)", kinds, err)
+	}
+	if _, err := aov.ParseList("depth,motion"); err == nil {
+		t.Error("Expected an error for an unknown output")
+	}
+	// a repeated output would be accumulated into its buffer twice
+	if _, err := aov.ParseList("depth,normal,Depth"); err == nil {
+		t.Error("Expected an error for a repeated output")
+	}
+}
+
+func TestVisualize(t *testing.T) {
+	fb := framebuffer.New(3, 1)
+	fb.Set(0, 0, vec.New(0, -1, 1), 1)
+	fb.Set(1, 0, vec.New(1, 0, 0), 1)
+
+	normals := aov.Visualize(aov.NORMAL, fb)
+	if c := normals.Color(0, 0); !c.Equals(vec.New(.5, 0, 1)) {
+		t.Errorf("Expected %v, but got %v", vec.New(.5, 0, 1), c)
+	}
+	if normals.Samples(2, 0) != 0 {
+		t.Errorf("Expected %d, but got %d", 0, normals.Samples(2, 0))
+	}
+
+	depth := framebuffer.New(2, 1)
+	depth.Set(0, 0, vec.New(2, 2, 2), 1)
+	depth.Set(1, 0, vec.New(4, 4, 4), 1)
+	if c := aov.Visualize(aov.DEPTH, depth).Color(0, 0); !c.Equals(vec.New(.5, .5, .5)) {
+		t.Errorf("Expected %v, but got %v", vec.New(.5, .5, .5), c)
+	}
+
+	ids := framebuffer.New(2, 1)
+	ids.Set(0, 0, vec.New(1, 1, 1), 1)
+	ids.Set(1, 0, vec.New(2, 2, 2), 1)
+	visualized := aov.Visualize(aov.OBJECT_ID, ids)
+	if visualized.Color(0, 0).Equals(visualized.Color(1, 0)) {
+		t.Error("Expected different IDs to get different colors")
+	}
+}
@@ -0,0 +1,107 @@
+package camera
+
+import (
+	"math"
+
+	"github.com/nsp5488/go_raytracer/internal/aov"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/interval"
+	"github.com/nsp5488/go_raytracer/internal/ray"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Returns the buffer of a requested output variable, cropped like Image, or nil if it was not requested.
+func (c *Camera) AOV(kind aov.Kind) *framebuffer.Framebuffer {
+	fb, ok := c.aovs[kind]
+	if !ok {
+		return nil
+	}
+	if c.CropToRegion {
+		return fb.Crop(c.region)
+	}
+	return fb
+}
+
+// Allocates a buffer for every requested output variable and numbers the primitives and materials of the world.
+// IDs follow the order of the scene graph, so they are stable between runs of the same scene.
+func (c *Camera) initializeAOVs(world hittable.Hittable) {
+	c.aovs = nil
+	if len(c.AOVs) == 0 {
+		return
+	}
+	c.aovs = make(map[aov.Kind]*framebuffer.Framebuffer, len(c.AOVs))
+	for _, kind := range c.AOVs {
+		c.aovs[kind] = framebuffer.New(c.frame.Dx(), c.frame.Dy())
+	}
+
+	c.objectIDs = map[hittable.Hittable]int{}
+	c.materialIDs = map[hittable.Material]int{}
+	hittable.Walk(world, func(h hittable.Hittable) {
+		mat := hittable.MaterialOf(h)
+		if mat == nil {
+			return
+		}
+		c.objectIDs[h] = len(c.objectIDs) + 1
+		if _, ok := c.materialIDs[mat]; !ok {
+			c.materialIDs[mat] = len(c.materialIDs) + 1
+		}
+	})
+}
+
+// Adds the output variables of one sample's first hit to sums, which holds one entry per requested output.
+// IDs are only taken from the first sample of a pixel, rays that miss the scene contribute zeros.
+func (c *Camera) sampleAOVs(r *ray.Ray, world hittable.Hittable, sums []*vec.Vec3, first bool) {
+	rec := hittable.HitRecord{}
+	if !world.Hit(r, *interval.New(0.001, math.Inf(1)), &rec) {
+		return
+	}
+	for k, kind := range c.AOVs {
+		if kind.IsID() && !first {
+			continue
+		}
+		sums[k].AddInplace(c.aovValue(kind, &rec))
+	}
+}
+
+// Returns the value of an output variable for a hit.
+func (c *Camera) aovValue(kind aov.Kind, rec *hittable.HitRecord) *vec.Vec3 {
+	switch kind {
+	case aov.DEPTH:
+		d := c.center.Sub(rec.P()).Dot(c.w)
+		if c.Projection.panoramic() {
+			// panoramic views see behind the camera, so their depth is the distance from it
+			d = rec.P().Sub(c.center).Length()
+		}
+		return vec.New(d, d, d)
+	case aov.NORMAL:
+		return rec.GeometricNormal().UnitVector()
+	case aov.SHADING_NORMAL:
+		return rec.Normal().UnitVector()
+	case aov.ALBEDO:
+		return rec.Albedo()
+	case aov.POSITION:
+		return rec.P()
+	case aov.OBJECT_ID:
+		id := float64(c.objectIDs[rec.Object()])
+		return vec.New(id, id, id)
+	case aov.MATERIAL_ID:
+		id := float64(c.materialIDs[rec.Material])
+		return vec.New(id, id, id)
+	case aov.UV:
+		return vec.New(rec.U(), rec.V(), 0)
+	}
+	return vec.Empty()
+}
+
+// Adds the summed output variables of n samples of the pixel at (i, j) to their buffers.
+func (c *Camera) addAOVs(i, j int, sums []*vec.Vec3, n int) {
+	for k, kind := range c.AOVs {
+		fb := c.aovs[kind]
+		if !kind.IsID() {
+			fb.AddSamples(i, j, sums[k], n)
+		} else if fb.Samples(i, j) == 0 {
+			fb.Set(i, j, sums[k], 1)
+		}
+	}
+}
@@ -1,20 +1,23 @@
 package camera
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"image"
 	"math"
-	"math/rand"
-	"sort"
+	"math/rand/v2"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	tea "github.com/charmbracelet/bubbletea"
+	"github.com/nsp5488/go_raytracer/internal/aov"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
 	"github.com/nsp5488/go_raytracer/internal/interval"
+	"github.com/nsp5488/go_raytracer/internal/lens"
 	"github.com/nsp5488/go_raytracer/internal/progress"
 	"github.com/nsp5488/go_raytracer/internal/ray"
-	"github.com/nsp5488/go_raytracer/internal/util"
+	"github.com/nsp5488/go_raytracer/internal/tiles"
 	"github.com/nsp5488/go_raytracer/internal/vec"
 
 	"github.com/nsp5488/go_raytracer/internal/hittable"
@@ -25,7 +28,6 @@ type Camera struct {
 	// public members
 	AspectRatio     float64
 	Width           int
-	Out             io.Writer
 	SamplesPerPixel int
 	MaxDepth        int
 	MaxThreads      int
@@ -35,19 +37,127 @@ type Camera struct {
 	Background      *vec.Vec3
 	MaxContribution float64
 
+	// How rays leave the camera, perspective by default. Every projection is focused at FocusDistance.
+	Projection Projection
+	// Height in world units of an orthographic view, 0 takes the height a perspective view has at FocusDistance.
+	OrthoHeight float64
+
+	// Stereo renders a view for each eye into the image, arranged as given. Width and AspectRatio give the size of
+	// each view. The eyes are Interocular world units apart along u, 0.064 (in meters) by default, and their views
+	// converge at the Convergence distance, where objects appear at the depth of the screen. When it is 0 the views
+	// are parallel. Panoramic projections render omni-directional stereo, with the eyes turning with the view.
+	Stereo      Stereo
+	Interocular float64
+	Convergence float64
+
+	// Lens shift moves the viewport across by ShiftX and up by ShiftY fractions of its width and height without
+	// turning the camera, so verticals stay parallel when looking up at a building. TiltX and TiltY tilt the plane in
+	// focus by that many degrees about u and v, turning its top or right side away from the camera like a tilted lens,
+	// while it still passes through the center of the view at FocusDistance. They apply to the perspective and
+	// orthographic projections, and Distortion renders the image as seen through a calibrated perspective lens.
+	ShiftX     float64
+	ShiftY     float64
+	TiltX      float64
+	TiltY      float64
+	Distortion Distortion
+
+	// Physical camera: a lens of FocalLength millimeters in front of a sensor SensorWidth millimeters wide, 36 by
+	// default, gives the field of view in place of VerticalFOV. Opened to the FStop f-number, it gives the size of the
+	// lens in place of DefocusAngle, with world units taken to be meters.
+	FocalLength float64
+	SensorWidth float64
+	FStop       float64
+	// Shape of the aperture, which gives out of focus highlights their shape: a regular polygon of ApertureBlades
+	// sides turned by ApertureRotation degrees, or a circle when there are less than 3. An ApertureMask overrides it,
+	// its brightness over the square around the lens weighs where rays pass through it.
+	ApertureBlades   int
+	ApertureRotation float64
+	ApertureMask     hittable.Texture
+	// The shutter is open from ShutterOpen to ShutterClose, 0 and 1 by default, the times motion blur is sampled
+	// between, and ShutterCurve weighs the moments in between.
+	ShutterOpen  float64
+	ShutterClose float64
+	ShutterCurve Shutter
+	// Exposes radiance, taken to be in nits, as a camera set to ISO with an exposure time of ExposureTime seconds
+	// and an aperture of FStop would. When ISO is 0 radiance is left as it is.
+	// MaxContribution applies to exposed radiance.
+	ISO          float64
+	ExposureTime float64
+
+	// Lens traces rays through a system of lens elements in place of the thin lens, focused at FocusDistance from the
+	// sensor, which is SensorWidth millimeters wide, with world units taken to be meters. The center of the image
+	// keeps the radiance it sees, towards the corners it is darkened by the rays the lens blocks and by the cos⁴θ fall
+	// off of those it lets through, as pbrt weighs them. It only renders perspective views.
+	Lens *lens.System
+
+	// The linear radiance of the last render. If set before rendering, its storage is reused.
+	Framebuffer *framebuffer.Framebuffer
+
+	// Progressive rendering: when PassSamples is set the whole image is swept in passes of PassSamples samples per pixel
+	// until SamplesPerPixel samples have been accumulated or TimeBudget has elapsed.
+	PassSamples      int
+	TimeBudget       time.Duration
+	SnapshotInterval time.Duration                                 // minimum time between snapshots, 0 takes one after every pass
+	Snapshot         func(fb *framebuffer.Framebuffer, passes int) // called between passes with the image accumulated so far
+
+	// Tiled rendering: the image is split into TileSize square tiles handed out in TileOrder to MaxThreads workers.
+	// Only the tiles covering Region are rendered, an empty Region renders the full image.
+	// Region is given in pixels of the full frame and is projected with the full-frame camera.
+	TileSize  int
+	TileOrder tiles.Order
+	Region    image.Rectangle
+	// Crops the image returned by Image to Region, otherwise pixels outside Region are left without samples.
+	CropToRegion bool
+
+	// Seeds the camera's sampling so resumed renders continue with fresh samples, 0 picks a random seed.
+	Seed uint64
+	// Index of the first pass, which selects the sampler streams it draws from. Renders with the same seed starting
+	// at different passes take distinct camera samples, so together they converge like a single render taking all of them.
+	FirstPass int
+	// Checkpointing: the accumulated image and sampler state are saved to CheckpointFile between passes,
+	// at most once per CheckpointInterval, and once more when the render finishes.
+	CheckpointFile     string
+	CheckpointInterval time.Duration
+	// A checkpoint to continue rendering from instead of starting with an empty image.
+	Resume *Checkpoint
+
+	// Auxiliary outputs taken from the first hit of every sample, read back with AOV once the render finishes.
+	AOVs []aov.Kind
+
+	// Reports the progress of renders, in tiles. When nil, nothing is reported.
+	Progress progress.Reporter
+
 	// private members
-	groupSize         chan struct{}
-	waitGroup         *sync.WaitGroup
-	imageHeight       int
-	center            *vec.Vec3
-	pixel00Loc        *vec.Vec3
-	pixelDeltaU       *vec.Vec3
-	pixelDeltaV       *vec.Vec3
-	pixelSamplesScale float64
-	sppSqrt           int
-	recipSppSqrt      float64
-	defocusDiskU      *vec.Vec3
-	defocusDiskV      *vec.Vec3
+	imageHeight   int             // of each eye's view
+	frame         image.Rectangle // the whole image, which holds both views when rendering stereo
+	region        image.Rectangle
+	tiles         []image.Rectangle
+	center        *vec.Vec3
+	pixel00Loc    *vec.Vec3
+	pixelDeltaU   *vec.Vec3
+	pixelDeltaV   *vec.Vec3
+	sppSqrt       int
+	recipSppSqrt  float64
+	passes        int
+	firstPass     int // index of the first pass rendered, used to pick sampler streams
+	deadline      time.Time
+	defocusDiskU  *vec.Vec3
+	defocusDiskV  *vec.Vec3
+	defocusRadius float64
+	parallax      float64       // the fraction of an eye's offset its viewport is shifted by
+	apertureCDF   []float64     // cumulative weights of the cells of the aperture mask, nil without one
+	focusNormal   *vec.Vec3     // normal of the tilted plane in focus, nil when it faces the camera
+	filmDistance  float64       // from the rear of the lens system, in millimeters
+	pupilRadius   float64       // of the disk rays leave the film towards, in millimeters
+	lensScale     float64       // scales the weights of rays through the lens system to average 1 at the image's center
+	exposure      float64       // the factor radiance is scaled by
+	maxRadiance   float64       // MaxContribution before exposure
+	fov           float64       // the vertical field of view in radians
+	rays          atomic.Uint64 // traced by the current render, including bounces
+
+	aovs        map[aov.Kind]*framebuffer.Framebuffer
+	objectIDs   map[hittable.Hittable]int
+	materialIDs map[hittable.Material]int
 
 	u        *vec.Vec3
 	v        *vec.Vec3
@@ -56,9 +166,9 @@ type Camera struct {
 	lookAt   *vec.Vec3
 	vup      *vec.Vec3
 
-	// progress bar state
-	progressBar *tea.Program
-	pbarMutex   sync.Mutex
+	// progress reporting state
+	progressMutex sync.Mutex
+	tilesDone     int
 }
 
 // PositionCamera positions the camera with the given parameters.
@@ -80,113 +190,114 @@ func (c *Camera) PositionCamera(lookFrom, lookAt, vup *vec.Vec3) {
 	}
 }
 
-// A struct for representing a row of pixel data.
-type rowData struct {
-	index int
-	data  *bytes.Buffer
+// Position returns the camera's position as set by PositionCamera.
+func (c *Camera) Position() (lookFrom, lookAt, vup *vec.Vec3) {
+	return c.lookFrom, c.lookAt, c.vup
 }
 
-// calculates the pixel data for one row of the image utilizing a thread pool.
-func (c *Camera) renderRow(world, lights hittable.Hittable, buf *rowData) {
-	defer c.waitGroup.Done()
-	c.groupSize <- struct{}{}
-	for j := range c.Width {
-		pixelColor := vec.Empty()
-
-		// Perform stratification
-		for s_i := range c.sppSqrt {
-			for s_j := range c.sppSqrt {
-				r := c.getRay(j, buf.index, s_j, s_i)
-				pixelColor.AddInplace(c.rayColor(r, world, lights, c.MaxDepth))
+// Sweeps the image once per pass, taking snapshots and checkpoints between passes, until the sample or time budget is spent
+// or ctx is cancelled. Returns the error of the last checkpoint if it couldn't be saved.
+func (c *Camera) renderPasses(ctx context.Context, world, lights hittable.Hittable) error {
+	var checkpointErr error
+	lastSnapshot := time.Now()
+	lastCheckpoint := time.Now()
+	nextPass := c.firstPass
+	for pass := range c.passes {
+		if c.budgetExceeded() || ctx.Err() != nil {
+			break
+		}
+		c.renderPass(ctx, world, lights, c.firstPass+pass)
+		// An interrupted pass still counts, so resuming never draws the samples some of its pixels already took.
+		nextPass++
+
+		lastPass := pass == c.passes-1 || c.budgetExceeded() || ctx.Err() != nil
+		if c.Snapshot != nil && !lastPass && time.Since(lastSnapshot) >= c.SnapshotInterval {
+			c.Snapshot(c.Image(), pass+1)
+			lastSnapshot = time.Now()
+		}
+		if c.CheckpointFile != "" && (lastPass || time.Since(lastCheckpoint) >= c.CheckpointInterval) {
+			checkpointErr = nil
+			if err := c.checkpoint(nextPass).Save(c.CheckpointFile); err != nil {
+				checkpointErr = fmt.Errorf("%w: %w", ErrCheckpoint, err)
 			}
+			lastCheckpoint = time.Now()
 		}
-		pixelColor.Scale(c.pixelSamplesScale).PrintColor(buf.data)
 	}
-	<-c.groupSize
-	c.pbarMutex.Lock()
-	c.progressBar.Send(1)
-	c.pbarMutex.Unlock()
+	// Complete the progress report, including any tiles skipped because the time budget ran out.
+	// An interrupted render is reported as it stands.
+	if ctx.Err() == nil {
+		c.advanceProgress(c.passes*len(c.tiles) - c.tilesDone)
+	}
+	return checkpointErr
 }
 
-// A threaded variant of the renderer.
-func (c *Camera) threadedRenderer(world, lights hittable.Hittable) {
-	buffers := make([]rowData, c.imageHeight, c.imageHeight)
-	for i := range c.imageHeight {
-		buffers[i].data = &bytes.Buffer{}
-		buffers[i].index = i
+// Returns the rendered image, cropped to the region of interest when CropToRegion is set.
+func (c *Camera) Image() *framebuffer.Framebuffer {
+	if c.CropToRegion {
+		return c.Framebuffer.Crop(c.region)
 	}
+	return c.Framebuffer
+}
 
-	for i := range c.imageHeight {
-		c.waitGroup.Add(1)
-		go c.renderRow(world, lights, &buffers[i])
-	}
-	c.waitGroup.Wait()
+// Returns the number of rays traced by the last render, counting every bounce but not the rays cast for output variables.
+func (c *Camera) Rays() uint64 {
+	return c.rays.Load()
+}
 
-	sort.Slice(buffers, func(i, j int) bool {
-		return buffers[i].index < buffers[j].index
-	})
-	for _, buf := range buffers {
-		buf.data.WriteTo(c.Out)
+// Returns the fraction of the last render's tiles which were rendered, between 0 and 1.
+func (c *Camera) Completion() float64 {
+	c.progressMutex.Lock()
+	defer c.progressMutex.Unlock()
+	total := c.passes * len(c.tiles)
+	if total == 0 {
+		return 1
 	}
-	c.progressBar.Send(1)
+	return float64(c.tilesDone) / float64(total)
 }
 
-// A synchronous variant of the renderer.
-func (c *Camera) syncRenderer(world, lights hittable.Hittable) {
-	for i := range c.imageHeight {
-		for j := range c.Width {
-			pixelColor := vec.Empty()
-
-			// Perform stratification
-			for s_i := range c.sppSqrt {
-				for s_j := range c.sppSqrt {
-					r := c.getRay(j, i, s_j, s_i)
-					pixelColor.AddInplace(c.rayColor(r, world, lights, c.MaxDepth))
-				}
-			}
+// Reports whether the render has run past its time budget.
+func (c *Camera) budgetExceeded() bool {
+	return !c.deadline.IsZero() && time.Now().After(c.deadline)
+}
 
-			pixelColor.Scale(c.pixelSamplesScale).PrintColor(c.Out)
-		}
-		c.progressBar.Send(1)
-	}
-	c.progressBar.Send(1)
+// Reports n more tiles as rendered.
+func (c *Camera) advanceProgress(n int) {
+	c.progressMutex.Lock()
+	c.tilesDone += n
+	c.Progress.Advance(n)
+	c.progressMutex.Unlock()
 }
 
 // Render the provided scene using the camera's settings.
-func (c *Camera) Render(world, lights hittable.Hittable) {
-	fmt.Println("Beginning render. . .")
-	c.initialize()
-
-	io.WriteString(c.Out, fmt.Sprintf("P3\n%d %d\n255\n", c.Width, c.imageHeight))
-
-	// Run the processing in a separate goroutine
-	if c.MaxThreads <= 1 {
-		// use a low-overhead synchronous renderer if we're only alloted one thread.
-		go c.syncRenderer(world, lights)
-	} else {
-		go c.threadedRenderer(world, lights)
+// When ctx is cancelled, or the user interrupts a progress reporter implementing progress.Interruptible,
+// the workers stop after the row they are tracing and Render returns the context's error.
+// The framebuffer then holds the samples taken so far, pixels which were never reached have none.
+// A render whose last checkpoint couldn't be saved still finishes, and then returns an error matching ErrCheckpoint.
+func (c *Camera) Render(ctx context.Context, world, lights hittable.Hittable) error {
+	if err := c.initialize(); err != nil {
+		return err
 	}
+	c.initializeAOVs(world)
 
-	if _, err := c.progressBar.Run(); err != nil {
-		fmt.Printf("Error running program: %v", err)
-		c.progressBar.ReleaseTerminal()
-		return
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if r, ok := c.Progress.(progress.Interruptible); ok {
+		r.OnInterrupt(cancel)
 	}
-
+	c.Progress.Start(c.passes * len(c.tiles))
+	err := c.renderPasses(ctx, world, lights)
+	c.Progress.Finish()
+	return errors.Join(ctx.Err(), err)
 }
 
-// initialize the camera's settings.
-func (c *Camera) initialize() {
-	// Ensure defaults:
+// ApplyDefaults fills in the settings which were left unset with the values Render uses for them.
+func (c *Camera) ApplyDefaults() {
 	if c.AspectRatio == 0 {
 		c.AspectRatio = 1.0
 	}
 	if c.Width == 0 {
 		c.Width = 100
 	}
-	if c.Out == nil {
-		log.Fatal("Must specify an output")
-	}
 	if c.SamplesPerPixel == 0 {
 		c.SamplesPerPixel = 100
 	}
@@ -205,19 +316,136 @@ func (c *Camera) initialize() {
 	if c.MaxContribution == 0 {
 		c.MaxContribution = 1.5
 	}
-	// calculate image height given aspect ratio, clamped to >=1
-	c.imageHeight = max(1, int(float64(c.Width)/c.AspectRatio))
+	if c.TileSize == 0 {
+		c.TileSize = 32
+	}
+	if c.TileOrder == 0 {
+		c.TileOrder = tiles.SCANLINE
+	}
+	if c.Projection == 0 {
+		c.Projection = PERSPECTIVE
+	}
+	if c.Stereo == 0 {
+		c.Stereo = MONO
+	}
+	if c.Stereo != MONO && c.Interocular == 0 {
+		c.Interocular = defaultInterocular
+	}
+	if (c.FocalLength > 0 || c.Lens != nil) && c.SensorWidth == 0 {
+		c.SensorWidth = defaultSensorWidth
+	}
+	if c.ShutterOpen == 0 && c.ShutterClose == 0 {
+		c.ShutterClose = 1
+	}
+	if c.ShutterCurve == 0 {
+		c.ShutterCurve = BOX
+	}
+}
+
+// Returns an error for settings the camera cannot render with, which would otherwise panic or hang a render.
+// Unset settings must have been filled in by ApplyDefaults.
+func (c *Camera) validate() error {
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"width", float64(c.Width)},
+		{"aspect ratio", c.AspectRatio},
+		{"samples per pixel", float64(c.SamplesPerPixel)},
+		{"max depth", float64(c.MaxDepth)},
+		{"threads", float64(c.MaxThreads)},
+		{"tile size", float64(c.TileSize)},
+	} {
+		if field.value < 0 {
+			return fmt.Errorf("%s must not be negative, but got %v", field.name, field.value)
+		}
+	}
+	if c.ShutterClose <= c.ShutterOpen {
+		return fmt.Errorf("the shutter must close after it opens, but it is open from %v to %v", c.ShutterOpen, c.ShutterClose)
+	}
+	if math.Abs(c.TiltX) >= 90 || math.Abs(c.TiltY) >= 90 {
+		return fmt.Errorf("the tilt must be between -90 and 90 degrees, but got %v and %v", c.TiltX, c.TiltY)
+	}
+	return nil
+}
+
+// ImageSize returns the size in pixels of the full frame, with the height given by the width and aspect ratio.
+// A stereo image is twice as wide or twice as tall, to hold the views of both eyes.
+// Unset settings must have been filled in by ApplyDefaults.
+func (c *Camera) ImageSize() (width, height int) {
+	width, height = c.Width, max(1, int(float64(c.Width)/c.AspectRatio))
+	switch c.Stereo {
+	case SIDE_BY_SIDE:
+		width *= 2
+	case TOP_BOTTOM:
+		height *= 2
+	}
+	return width, height
+}
+
+// Passes returns the number of passes a render takes and the samples per pixel each of them takes.
+// Each pass takes a stratified grid of samples, a non-progressive render is a single pass of every sample.
+// Unset settings must have been filled in by ApplyDefaults.
+func (c *Camera) Passes() (passes, samples int) {
+	passSamples := c.SamplesPerPixel
+	if c.PassSamples > 0 {
+		passSamples = min(c.PassSamples, c.SamplesPerPixel)
+	}
+	sppSqrt := max(1, int(math.Sqrt(float64(passSamples))))
+	return max(1, c.SamplesPerPixel/(sppSqrt*sppSqrt)), sppSqrt * sppSqrt
+}
 
-	c.sppSqrt = int(math.Sqrt(float64(c.SamplesPerPixel)))
-	c.pixelSamplesScale = 1.0 / float64(c.sppSqrt*c.sppSqrt)
+// initialize the camera's settings.
+func (c *Camera) initialize() error {
+	c.ApplyDefaults()
+	if err := c.validate(); err != nil {
+		return err
+	}
+	c.imageHeight = max(1, int(float64(c.Width)/c.AspectRatio))
+	c.frame = image.Rectangle{Max: image.Pt(c.ImageSize())}
+
+	// split the region of interest into tiles
+	c.region = c.frame
+	if !c.Region.Empty() {
+		c.region = c.Region.Intersect(c.region)
+		if c.region.Empty() {
+			return fmt.Errorf("region %v lies outside of the %dx%d image", c.Region, c.frame.Dx(), c.frame.Dy())
+		}
+	}
+	c.tiles = tiles.Split(c.region, c.TileSize, c.TileOrder)
+
+	passes, passSamples := c.Passes()
+	c.sppSqrt = int(math.Sqrt(float64(passSamples)))
+	c.passes = passes
+	c.firstPass = c.FirstPass
+	c.rays.Store(0)
+	if c.Seed == 0 {
+		c.Seed = rand.Uint64()
+	}
+	if c.Resume != nil {
+		if err := c.resume(c.Resume); err != nil {
+			return err
+		}
+	} else if c.Framebuffer == nil {
+		c.Framebuffer = framebuffer.New(c.frame.Dx(), c.frame.Dy())
+	} else {
+		c.Framebuffer.Reset(c.frame.Dx(), c.frame.Dy())
+	}
+	c.deadline = time.Time{}
+	if c.TimeBudget > 0 {
+		c.deadline = time.Now().Add(c.TimeBudget)
+	}
 	c.recipSppSqrt = 1.0 / float64(c.sppSqrt)
 
 	// define camera information
 	c.center = c.lookFrom
 
-	theta := util.DegressToRadians(c.VerticalFOV)
-	h := math.Tan(theta / 2)
+	c.fov = c.fieldOfView()
+	h := math.Tan(c.fov / 2)
 	viewportHeight := 2.0 * h * c.FocusDistance
+	if c.Projection == ORTHOGRAPHIC && c.OrthoHeight > 0 {
+		viewportHeight = c.OrthoHeight
+	}
 	viewportWidth := viewportHeight * (float64(c.Width) / float64(c.imageHeight))
 
 	// calculate camera basis vectors
@@ -236,64 +464,154 @@ func (c *Camera) initialize() {
 	// Calculate the location of the upper left pixel.
 	viewportTopLeft := c.center.Sub(
 		c.w.Scale(c.FocusDistance)).
-		Sub(viewportU.Scale(0.5)).
-		Sub(viewportV.Scale(0.5))
+		Sub(viewportU.Scale(0.5 - c.ShiftX)).
+		Sub(viewportV.Scale(0.5 + c.ShiftY))
 	c.pixel00Loc = viewportTopLeft.Add(c.pixelDeltaU.Add(c.pixelDeltaV).Scale(0.5))
 
 	// calculate defocus disk basis vectors
-	defocusRadius := c.FocusDistance * math.Tan(util.DegressToRadians(c.DefocusAngle/2.0))
-	c.defocusDiskU = c.u.Scale(defocusRadius)
-	c.defocusDiskV = c.v.Scale(defocusRadius)
+	c.defocusRadius = c.lensRadius()
+	c.defocusDiskU = c.u.Scale(c.defocusRadius)
+	c.defocusDiskV = c.v.Scale(c.defocusRadius)
+	if err := c.initializeAperture(); err != nil {
+		return err
+	}
+	c.exposure = c.exposureScale()
+	if c.Lens != nil {
+		if err := c.initializeLens(); err != nil {
+			return err
+		}
+	}
+	c.maxRadiance = c.MaxContribution / c.exposure
+
+	c.focusNormal = nil
+	if c.TiltX != 0 || c.TiltY != 0 {
+		c.focusNormal = c.focusPlaneNormal()
+	}
 
-	c.waitGroup = &sync.WaitGroup{}
-	c.groupSize = make(chan struct{}, c.MaxThreads)
+	// Views converging at the focus distance share their viewport, parallel ones move it with the eye.
+	c.parallax = 1
+	if c.Convergence > 0 {
+		c.parallax = 1 - c.FocusDistance/c.Convergence
+	}
 
-	// initialize the progress bar
-	c.progressBar = progress.InitBar(c.imageHeight + 1)
+	// initialize the progress report
+	c.tilesDone = 0
+	if c.Progress == nil {
+		c.Progress = progress.Silent{}
+	}
+	return nil
 }
 
 // getRay returns a ray from the camera with some amount of defocus and sampling to offset. This creates a smoother image and simulates depth of field.
-func (c *Camera) getRay(i, j, s_i, s_j int) *ray.Ray {
-	offset := c.sampleSquareStratified(s_i, s_j)
+// The radiance the ray brings is scaled by its weight, which is 1 except for rays through a lens system.
+// Returns nil for samples which see nothing, outside the image circle of a fisheye or blocked by a lens system.
+func (c *Camera) getRay(rng *rand.Rand, i, j, s_i, s_j int) (*ray.Ray, float64) {
+	offset := c.sampleSquareStratified(rng, s_i, s_j)
+	eye, i, j := c.eye(i, j)
+	if c.Lens != nil {
+		return c.getLensRay(rng, eye, float64(i)+offset.X()+0.5, float64(j)+offset.Y()+0.5)
+	}
+	if c.Projection.panoramic() {
+		return c.getPanoramicRay(rng, eye, float64(i)+offset.X()+0.5, float64(j)+offset.Y()+0.5), 1
+	}
 	pixelSample := c.pixel00Loc.
 		Add(c.pixelDeltaU.Scale(float64(i) + offset.X())).
 		Add(c.pixelDeltaV.Scale(float64(j) + offset.Y()))
+	if c.Distortion != (Distortion{}) {
+		pixelSample = c.undistortViewport(pixelSample)
+	}
+	// orthographic rays leave the camera's plane straight behind the pixel
+	center := c.center
+	if c.Projection == ORTHOGRAPHIC {
+		center = pixelSample.Add(c.w.Scale(c.FocusDistance))
+	}
+	if eye != 0 {
+		// The eye moves along u. Shifting its viewport by less than that turns its view towards the other eye's,
+		// so the two cross at the convergence distance.
+		shift := c.u.Scale(eye * c.Interocular / 2)
+		center = center.Add(shift)
+		pixelSample = pixelSample.Add(shift.Scale(c.parallax))
+	}
+	if c.focusNormal != nil {
+		pixelSample = c.tiltedFocus(center, pixelSample)
+	}
 	var rayOrigin *vec.Vec3
-	if c.DefocusAngle <= 0 {
-		rayOrigin = c.center
+	if c.defocusRadius <= 0 {
+		rayOrigin = center
 	} else {
-		rayOrigin = c.defocusDiskSample()
+		rayOrigin = c.defocusDiskSample(rng, center)
 	}
 	rayDirection := pixelSample.Sub(rayOrigin)
-	rayTime := rand.Float64()
-	return ray.NewWithTime(rayOrigin, rayDirection, rayTime)
+	rayTime := c.shutterTime(rng)
+	return ray.NewWithTime(rayOrigin, rayDirection, rayTime), 1
+}
+
+// Returns a ray of a panoramic projection through the point (x, y) of an eye's view, in pixels from its top left corner.
+// Its lens is a disk facing the ray's direction, focused on the sphere of radius FocusDistance around the eye.
+func (c *Camera) getPanoramicRay(rng *rand.Rand, eye, x, y float64) *ray.Ray {
+	direction := c.panoramicDirection(x, y)
+	if direction == nil {
+		return nil
+	}
+	rayOrigin := c.center
+	if eye != 0 {
+		rayOrigin = c.center.Add(c.panoramicEyeOffset(eye, direction))
+		if c.Convergence > 0 {
+			direction = c.center.Add(direction.Scale(c.Convergence)).Sub(rayOrigin).UnitVector()
+		}
+	}
+	if c.defocusRadius > 0 {
+		// a basis for the lens, which falls back to u when looking along v
+		lensU := direction.Cross(c.v)
+		if lensU.NearZero() {
+			lensU = c.u
+		}
+		lensU = lensU.UnitVector()
+		lensV := lensU.Cross(direction)
+		p := c.apertureSample(rng)
+		focus := rayOrigin.Add(direction.Scale(c.FocusDistance))
+		rayOrigin = rayOrigin.Add(lensU.Scale(c.defocusRadius * p.X())).Add(lensV.Scale(c.defocusRadius * p.Y()))
+		direction = focus.Sub(rayOrigin)
+	}
+	return ray.NewWithTime(rayOrigin, direction, c.shutterTime(rng))
 }
 
 // Returns a random offset within a 1x1 square
-func (c *Camera) sampleSquare() *vec.Vec3 {
-	return vec.New(rand.Float64()-0.5, rand.Float64()-0.5, 0)
+func (c *Camera) sampleSquare(rng *rand.Rand) *vec.Vec3 {
+	return vec.New(rng.Float64()-0.5, rng.Float64()-0.5, 0)
 }
 
-func (c *Camera) sampleSquareStratified(s_i, s_j int) *vec.Vec3 {
-	px := ((float64(s_i) + rand.Float64()) * c.recipSppSqrt) - .5
-	py := ((float64(s_j) + rand.Float64()) * c.recipSppSqrt) - .5
+func (c *Camera) sampleSquareStratified(rng *rand.Rand, s_i, s_j int) *vec.Vec3 {
+	px := ((float64(s_i) + rng.Float64()) * c.recipSppSqrt) - .5
+	py := ((float64(s_j) + rng.Float64()) * c.recipSppSqrt) - .5
 
 	return vec.New(px, py, 0)
 }
 
-// Returns a randomly offset center point for the ray to simulate depth of field
-func (c *Camera) defocusDiskSample() *vec.Vec3 {
-	p := vec.RandomUnitDisk()
-	return c.center.
+// Returns a point randomly offset from center on the lens to simulate depth of field
+func (c *Camera) defocusDiskSample(rng *rand.Rand, center *vec.Vec3) *vec.Vec3 {
+	p := c.apertureSample(rng)
+	return center.
 		Add(c.defocusDiskU.Scale(p.X())).
 		Add(c.defocusDiskV.Scale(p.Y()))
 }
 
-// Calculates the color of a ray after it has been traced through the scene.
-func (c *Camera) rayColor(r *ray.Ray, world, lights hittable.Hittable, depth int) *vec.Vec3 {
+// Generates a random point in the unit disk using the given generator
+func randomUnitDisk(rng *rand.Rand) *vec.Vec3 {
+	for {
+		p := vec.New(2*rng.Float64()-1, 2*rng.Float64()-1, 0)
+		if p.LengthSquared() < 1 {
+			return p
+		}
+	}
+}
+
+// Calculates the color of a ray after it has been traced through the scene, counting it and its bounces in rays.
+func (c *Camera) rayColor(r *ray.Ray, world, lights hittable.Hittable, depth int, rays *int) *vec.Vec3 {
 	if depth < 0 {
 		return vec.Empty()
 	}
+	*rays++
 
 	rec := hittable.HitRecord{}
 
@@ -313,7 +631,7 @@ func (c *Camera) rayColor(r *ray.Ray, world, lights hittable.Hittable, depth int
 		return emitColor
 	}
 	if srecord.SkipPdf {
-		return srecord.Attenuation.Multiply(c.rayColor(srecord.SkipPdfRay, world, lights, depth-1))
+		return srecord.Attenuation.Multiply(c.rayColor(srecord.SkipPdfRay, world, lights, depth-1, rays))
 	}
 
 	lightPdf := hittable.HittablePdf(rec.P(), lights)
@@ -324,10 +642,10 @@ func (c *Camera) rayColor(r *ray.Ray, world, lights hittable.Hittable, depth int
 
 	scatterPdf := rec.Material.ScatteringPdf(r, scattered, &rec)
 
-	sampleColor := c.rayColor(scattered, world, lights, depth-1)
+	sampleColor := c.rayColor(scattered, world, lights, depth-1, rays)
 	scatterColor = srecord.Attenuation.Scale(scatterPdf).Multiply(sampleColor).Scale(1 / pdfValue)
 
-	return clampContribution(emitColor.Add(scatterColor), c.MaxContribution)
+	return clampContribution(emitColor.Add(scatterColor), c.maxRadiance)
 }
 
 // Clamps the maximum contribution of a single ray to prevent "fireflies"
@@ -0,0 +1,87 @@
+package camera
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+)
+
+// ErrCheckpoint is returned by Render, along with the reason, when the render state couldn't be saved to CheckpointFile.
+var ErrCheckpoint = errors.New("could not save the checkpoint")
+
+// Checkpoint holds everything needed to continue an interrupted render: the accumulated image,
+// the per-pixel sample counts and the state of the camera's sampler.
+type Checkpoint struct {
+	Seed        uint64
+	NextPass    int // index of the next pass, which selects the sampler streams it draws from
+	PassSamples int // samples per pixel taken by each pass
+	Framebuffer *framebuffer.Framebuffer
+}
+
+// Builds a checkpoint of the current render state.
+func (c *Camera) checkpoint(nextPass int) *Checkpoint {
+	return &Checkpoint{
+		Seed:        c.Seed,
+		NextPass:    nextPass,
+		PassSamples: c.sppSqrt * c.sppSqrt,
+		Framebuffer: c.Framebuffer,
+	}
+}
+
+// Restores the render state from a checkpoint and limits the remaining passes to what is left of the sample budget.
+func (c *Camera) resume(cp *Checkpoint) error {
+	fb := cp.Framebuffer
+	if fb == nil {
+		return fmt.Errorf("checkpoint has no image data")
+	}
+	if fb.Bounds() != c.frame {
+		return fmt.Errorf("checkpoint is %dx%d but the camera renders %dx%d", fb.Width, fb.Height, c.frame.Dx(), c.frame.Dy())
+	}
+	// the sampler's streams are stratified for the samples of a pass, other pass sizes would repeat samples
+	if passSamples := c.sppSqrt * c.sppSqrt; cp.PassSamples != passSamples {
+		return fmt.Errorf("checkpoint takes %d samples per pixel in each pass but the camera takes %d", cp.PassSamples, passSamples)
+	}
+	c.Framebuffer = fb
+	c.Seed = cp.Seed
+	c.firstPass = cp.NextPass
+	remaining := c.SamplesPerPixel - fb.MinSamplesIn(c.region)
+	c.passes = max(0, remaining/(c.sppSqrt*c.sppSqrt))
+	return nil
+}
+
+// Save writes the checkpoint to a temporary file which then replaces the named file,
+// so a crash while saving never destroys the previous checkpoint.
+func (cp *Checkpoint) Save(filename string) error {
+	tmp := filename + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(cp)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// LoadCheckpoint reads a checkpoint previously written by Save.
+func LoadCheckpoint(filename string) (*Checkpoint, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cp := &Checkpoint{}
+	if err := gob.NewDecoder(file).Decode(cp); err != nil {
+		return nil, fmt.Errorf("could not decode checkpoint %s: %w", filename, err)
+	}
+	return cp, nil
+}
@@ -0,0 +1,56 @@
+package camera_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func TestResumeChecksPassSamples(t *testing.T) {
+	world := hittable.NewHittableList(1)
+	world.Add(glowing(vec.New(0, 0, -2), 0.5))
+	filename := filepath.Join(t.TempDir(), "render.ckpt")
+	c := &camera.Camera{Width: 8, SamplesPerPixel: 8, PassSamples: 4, CheckpointFile: filename, Background: vec.Empty()}
+	c.PositionCamera(nil, nil, nil)
+	if err := c.Render(context.Background(), world, hittable.NewHittableList(0)); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := camera.LoadCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for passSamples, ok := range map[int]bool{4: true, 9: false} {
+		r := &camera.Camera{Width: 8, SamplesPerPixel: 16, PassSamples: passSamples, Resume: cp, Background: vec.Empty()}
+		r.PositionCamera(nil, nil, nil)
+		err := r.Render(context.Background(), world, hittable.NewHittableList(0))
+		if ok && err != nil {
+			t.Errorf("Expected the render to resume with %d samples per pass, but got %v", passSamples, err)
+		} else if !ok && (err == nil || !strings.Contains(err.Error(), "samples per pixel in each pass")) {
+			t.Errorf("Expected an error resuming with %d samples per pass, but got %v", passSamples, err)
+		}
+	}
+}
+
+func TestCheckpointError(t *testing.T) {
+	world := hittable.NewHittableList(1)
+	world.Add(glowing(vec.New(0, 0, -2), 0.5))
+	filename := filepath.Join(t.TempDir(), "missing", "render.ckpt")
+	c := &camera.Camera{Width: 8, SamplesPerPixel: 8, PassSamples: 4, CheckpointFile: filename, Background: vec.Empty()}
+	c.PositionCamera(nil, nil, nil)
+	err := c.Render(context.Background(), world, hittable.NewHittableList(0))
+	if !errors.Is(err, camera.ErrCheckpoint) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a checkpoint error, but got %v", err)
+	}
+	// the render finishes regardless
+	if !c.Framebuffer.Covered() || c.Framebuffer.MinSamples() != 8 {
+		t.Errorf("Expected every pixel to take 8 samples, but got %d", c.Framebuffer.MinSamples())
+	}
+}
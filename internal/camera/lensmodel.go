@@ -0,0 +1,68 @@
+package camera
+
+import (
+	"math"
+
+	"github.com/nsp5488/go_raytracer/internal/util"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Distortion holds the coefficients of the Brown-Conrady model of a lens, in the convention of OpenCV's calibration:
+// radial K1, K2 and K3 and tangential (decentering) P1 and P2. They map the normalized coordinates of an undistorted
+// view, x across and y down from the optical axis in units of the focal length, to where the lens images them.
+type Distortion struct {
+	K1, K2, K3 float64
+	P1, P2     float64
+}
+
+// Returns the normalized point the lens images at (x, y). The lens images (x, y) at
+//
+//	x·(1 + K1·r² + K2·r⁴ + K3·r⁶) + 2·P1·x·y + P2·(r² + 2·x²)
+//	y·(1 + K1·r² + K2·r⁴ + K3·r⁶) + P1·(r² + 2·y²) + 2·P2·x·y
+//
+// which is inverted by fixed point iteration as OpenCV's undistortPoints does, converging for the moderate
+// distortion of calibrated lenses.
+func (d Distortion) undistort(x, y float64) (float64, float64) {
+	ux, uy := x, y
+	for range 20 {
+		r2 := ux*ux + uy*uy
+		radial := 1 + r2*(d.K1+r2*(d.K2+r2*d.K3))
+		dx := 2*d.P1*ux*uy + d.P2*(r2+2*ux*ux)
+		dy := d.P1*(r2+2*uy*uy) + 2*d.P2*ux*uy
+		ux, uy = (x-dx)/radial, (y-dy)/radial
+	}
+	return ux, uy
+}
+
+// Returns the point of the viewport the undistorted view sees through the point of the distorted image at p,
+// both on the plane at the focus distance.
+func (c *Camera) undistortViewport(p *vec.Vec3) *vec.Vec3 {
+	axis := c.center.Sub(c.w.Scale(c.FocusDistance))
+	rel := p.Sub(axis)
+	x, y := c.Distortion.undistort(rel.Dot(c.u)/c.FocusDistance, -rel.Dot(c.v)/c.FocusDistance)
+	return axis.Add(c.u.Scale(x * c.FocusDistance)).Sub(c.v.Scale(y * c.FocusDistance))
+}
+
+// Returns the normal of the plane in focus, turned from the view direction by the tilt of the lens.
+func (c *Camera) focusPlaneNormal() *vec.Vec3 {
+	tiltX := math.Tan(util.DegressToRadians(c.TiltX))
+	tiltY := math.Tan(util.DegressToRadians(c.TiltY))
+	// the plane's depth grows by tiltX for every unit up and by tiltY for every unit right
+	return c.w.Add(c.v.Scale(tiltX)).Add(c.u.Scale(tiltY)).UnitVector()
+}
+
+// Returns the point in focus along the ray from the eye at origin through the point p of the viewport: where it meets
+// the tilted plane in focus, which passes through the viewport's center. Rays running along the plane, or meeting it
+// behind the eye, keep the viewport's point.
+func (c *Camera) tiltedFocus(origin, p *vec.Vec3) *vec.Vec3 {
+	direction := p.Sub(origin)
+	denominator := direction.Dot(c.focusNormal)
+	if math.Abs(denominator) < 1e-9 {
+		return p
+	}
+	t := c.center.Sub(c.w.Scale(c.FocusDistance)).Sub(origin).Dot(c.focusNormal) / denominator
+	if t <= 0 {
+		return p
+	}
+	return origin.Add(direction.Scale(t))
+}
@@ -0,0 +1,101 @@
+package camera_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func TestLensShift(t *testing.T) {
+	sphere := glowing(vec.New(0, 0, -4), 0.5)
+	for _, tc := range []struct {
+		shiftX, shiftY float64
+		x, y           int // where the sphere is seen
+	}{
+		{0, 0, 15, 15},
+		{0.25, 0, 7, 15},   // the view moves right, the sphere left
+		{0, 0.25, 15, 23},  // the view moves up, the sphere down
+		{-0.25, 0, 23, 15}, // the view moves left, the sphere right
+	} {
+		c := &camera.Camera{Width: 32, ShiftX: tc.shiftX, ShiftY: tc.shiftY}
+		fb := renderSpheres(t, c, vec.Empty(), sphere)
+		if !lit(fb, tc.x, tc.y) || (tc.shiftX != 0 || tc.shiftY != 0) && lit(fb, 15, 15) {
+			t.Errorf("Expected a lens shifted by (%v, %v) to see the sphere at (%d, %d)", tc.shiftX, tc.shiftY, tc.x, tc.y)
+		}
+	}
+
+	// the view direction stays the same, so a sphere straight ahead keeps its size
+	plain := renderSpheres(t, &camera.Camera{Width: 32}, vec.Empty(), sphere)
+	shifted := renderSpheres(t, &camera.Camera{Width: 32, ShiftX: 0.25}, vec.Empty(), sphere)
+	if p, s := total(plain), total(shifted); math.Abs(p-s) > 0.1*p {
+		t.Errorf("Expected the shifted view to see as much of the sphere, %v, but got %v", p, s)
+	}
+}
+
+// Returns the sum of every pixel's red channel.
+func total(fb *framebuffer.Framebuffer) float64 {
+	sum := 0.0
+	for y := range fb.Height {
+		for x := range fb.Width {
+			sum += fb.Color(x, y).X()
+		}
+	}
+	return sum
+}
+
+// Returns the brightest pixel's red channel, which is lower the more a sphere is blurred.
+func peak(fb *framebuffer.Framebuffer) float64 {
+	brightest := 0.0
+	for y := range fb.Height {
+		for x := range fb.Width {
+			brightest = max(brightest, fb.Color(x, y).X())
+		}
+	}
+	return brightest
+}
+
+func TestTiltedFocus(t *testing.T) {
+	// a wide lens focused 4 units away, tilted so the plane in focus recedes by a unit for every unit up
+	for _, tc := range []struct {
+		center *vec.Vec3
+		tilt   float64
+		sharp  bool
+	}{
+		{vec.New(0, 1, -5), 0, false},
+		{vec.New(0, 1, -5), 45, true},
+		{vec.New(0, -1, -3), 45, true},
+		{vec.New(0, 0, -4), 45, true},
+		{vec.New(0, 1, -3), 45, false},
+	} {
+		c := &camera.Camera{Width: 64, FocusDistance: 4, DefocusAngle: 2 * math.Atan(0.5) * 180 / math.Pi, TiltX: tc.tilt}
+		fb := renderSpheres(t, c, vec.Empty(), glowing(tc.center, 0.3))
+		if p := peak(fb); (p > 0.9) != tc.sharp {
+			t.Errorf("Expected a sphere at %v to be sharp %v with the focus tilted by %v degrees, but its peak is %v", tc.center, tc.sharp, tc.tilt, p)
+		}
+	}
+}
+
+func TestDistortion(t *testing.T) {
+	// the sphere is seen half a focal length right of the center, 16 pixels
+	sphere := glowing(vec.New(2, 0, -4), 0.3)
+	view := image.Rect(0, 0, 64, 64)
+	plain := centroid(renderSpheres(t, &camera.Camera{Width: 64}, vec.Empty(), sphere), view)
+	for _, tc := range []struct {
+		distortion camera.Distortion
+		shift      float64
+	}{
+		{camera.Distortion{K1: 0.5}, 2},   // pincushion distortion images it at 0.5·(1 + 0.5·0.5²)
+		{camera.Distortion{K1: -0.5}, -2}, // barrel distortion
+		{camera.Distortion{P2: 0.1}, 2.4}, // decentered by 0.1·3·0.5²
+	} {
+		c := &camera.Camera{Width: 64, Distortion: tc.distortion}
+		distorted := centroid(renderSpheres(t, c, vec.Empty(), sphere), view)
+		if math.Abs(distorted-plain-tc.shift) > 0.5 {
+			t.Errorf("Expected %+v to move the sphere by %v pixels, but got %v", tc.distortion, tc.shift, distorted-plain)
+		}
+	}
+}
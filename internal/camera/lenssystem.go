@@ -0,0 +1,87 @@
+package camera
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+
+	"github.com/nsp5488/go_raytracer/internal/ray"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// The number of points of the exit pupil the brightness of the image's center is measured with.
+const lensCalibrationSamples = 4096
+
+// Focuses the lens system and bounds its exit pupil. The weights of its rays are scaled by the inverse of the average
+// weight of those reaching the image's center, so it keeps the scene's radiance and the lens only darkens the rest.
+func (c *Camera) initializeLens() error {
+	if c.Projection != PERSPECTIVE {
+		return errors.New("a lens system only renders the perspective projection")
+	}
+	filmDistance, err := c.Lens.FilmDistance(c.FocusDistance * 1000)
+	if err != nil {
+		return err
+	}
+	f, err := c.Lens.FocalLength()
+	if err != nil {
+		return err
+	}
+	sensorHeight := c.SensorWidth * float64(c.imageHeight) / float64(c.Width)
+	pupilRadius, err := c.Lens.ExitPupil(filmDistance, math.Hypot(c.SensorWidth, sensorHeight)/2)
+	if err != nil {
+		return err
+	}
+	c.filmDistance = filmDistance
+	c.pupilRadius = pupilRadius
+	c.fov = 2 * math.Atan(sensorHeight/(2*f))
+
+	// measured with a fixed set of points, so it is the same for every render
+	rng := rand.New(rand.NewPCG(1, 2))
+	film := vec.New(0, 0, -filmDistance)
+	total := 0.0
+	for range lensCalibrationSamples {
+		pupil := randomUnitDisk(rng).Scale(pupilRadius)
+		if _, _, ok := c.Lens.Trace(film, pupil.Sub(film)); ok {
+			total += c.lensWeight(film, pupil)
+		}
+	}
+	if total == 0 {
+		return errors.New("no light passes the lens to the center of the film")
+	}
+	c.lensScale = lensCalibrationSamples / total
+	return nil
+}
+
+// Returns a ray through the lens system from the point (x, y) of an eye's view, in pixels from its top left corner,
+// with its weight, or nil when the lens blocks it. The ray leaves the film towards a random point of the exit pupil
+// and is traced through the lens, whose space is turned into the camera's with the film at its center, in meters.
+func (c *Camera) getLensRay(rng *rand.Rand, eye, x, y float64) (*ray.Ray, float64) {
+	sensorHeight := c.SensorWidth * float64(c.imageHeight) / float64(c.Width)
+	// the lens turns the image upside down, so the film is too
+	film := vec.New(
+		-(x/float64(c.Width)-0.5)*c.SensorWidth,
+		-(0.5-y/float64(c.imageHeight))*sensorHeight,
+		-c.filmDistance)
+	pupil := randomUnitDisk(rng).Scale(c.pupilRadius)
+	origin, direction, ok := c.Lens.Trace(film, pupil.Sub(film))
+	if !ok {
+		return nil, 0
+	}
+	toCamera := func(p *vec.Vec3) *vec.Vec3 {
+		return c.u.Scale(p.X()).Add(c.v.Scale(p.Y())).Sub(c.w.Scale(p.Z()))
+	}
+	rayOrigin := c.center.Add(toCamera(origin.Add(vec.New(0, 0, c.filmDistance))).Scale(0.001))
+	if eye != 0 {
+		rayOrigin = rayOrigin.Add(c.u.Scale(eye * c.Interocular / 2))
+	}
+	return ray.NewWithTime(rayOrigin, toCamera(direction), c.shutterTime(rng)), c.lensWeight(film, pupil) * c.lensScale
+}
+
+// Returns the weight of a ray from the point film towards the point pupil of the exit pupil, as pbrt weighs it: the
+// irradiance it brings the film, cos⁴θ of its angle to the axis times the area of the pupil over the squared distance
+// to the film. The fall off of cos⁴θ towards the edges of the image is the lens' natural vignetting.
+func (c *Camera) lensWeight(film, pupil *vec.Vec3) float64 {
+	cosTheta := pupil.Sub(film).UnitVector().Z()
+	cos4Theta := cosTheta * cosTheta * cosTheta * cosTheta
+	return cos4Theta * math.Pi * c.pupilRadius * c.pupilRadius / (c.filmDistance * c.filmDistance)
+}
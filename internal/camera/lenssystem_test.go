@@ -0,0 +1,139 @@
+package camera_test
+
+import (
+	"context"
+	"image"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/lens"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// A 50mm f/2 double Gauss lens, from pbrt's lens collection.
+const doubleGauss = `29.475	3.76	1.67	25.2
+84.83	0.12	1	25.2
+19.275	4.025	1.67	23
+40.77	3.275	1.699	23
+12.75	5.705	1	18
+0	4.5	0	17.1
+-14.495	1.18	1.603	17
+40.77	6.065	1.658	20
+-20.385	0.19	1	20
+437.065	3.22	1.717	20
+-39.73	0	1	20
+`
+
+func lensCamera(t *testing.T, focus float64) *camera.Camera {
+	t.Helper()
+	system, err := lens.Parse(strings.NewReader(doubleGauss))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &camera.Camera{Width: 64, Lens: system, FocusDistance: focus}
+}
+
+// Returns the average red channel of the square of pixels of the given size at (x, y).
+func average(fb *framebuffer.Framebuffer, x, y, size int) float64 {
+	sum := 0.0
+	for j := y; j < y+size; j++ {
+		for i := x; i < x+size; i++ {
+			sum += fb.Color(i, j).X()
+		}
+	}
+	return sum / float64(size*size)
+}
+
+// Returns the irradiance pbrt's weighting gives the film at (x, y), in millimeters from its center, up to a constant:
+// the sum of cos⁴θ over the rays from a grid of points of the exit pupil which pass the lens.
+func irradiance(system *lens.System, filmDistance, pupilRadius, x, y float64) float64 {
+	const grid = 48
+	film := vec.New(x, y, -filmDistance)
+	sum := 0.0
+	for gy := range grid {
+		for gx := range grid {
+			px, py := (2*(float64(gx)+0.5)/grid-1)*pupilRadius, (2*(float64(gy)+0.5)/grid-1)*pupilRadius
+			if math.Hypot(px, py) > pupilRadius {
+				continue
+			}
+			direction := vec.New(px, py, 0).Sub(film)
+			if _, _, ok := system.Trace(film, direction); ok {
+				cosTheta := direction.UnitVector().Z()
+				sum += cosTheta * cosTheta * cosTheta * cosTheta
+			}
+		}
+	}
+	return sum
+}
+
+// Renders the lens camera's view of a white background within region at 256 samples per pixel and returns the average
+// of its red channel.
+func lensRegion(t *testing.T, region image.Rectangle) float64 {
+	t.Helper()
+	c := lensCamera(t, 2)
+	c.SamplesPerPixel = 256
+	c.Seed = 1
+	c.Background = vec.New(1, 1, 1)
+	c.Region = region
+	c.PositionCamera(vec.New(0, 0, 0), vec.New(0, 0, -1), vec.New(0, 1, 0))
+	if err := c.Render(context.Background(), hittable.NewHittableList(0), hittable.NewHittableList(0)); err != nil {
+		t.Fatal(err)
+	}
+	return average(c.Image(), region.Min.X, region.Min.Y, region.Dx())
+}
+
+func TestLensSystemVignetting(t *testing.T) {
+	center := lensRegion(t, image.Rect(28, 28, 36, 36))
+	if center < 0.95 || center > 1.05 {
+		t.Errorf("Expected the center of the image to keep the background's radiance, but got %v", center)
+	}
+
+	// towards the corner the image is darkened by the rays the lens blocks and by the cos⁴θ fall off of those it lets
+	// through, averaged over the pixels of a block of the 64 pixel wide image of a 36mm sensor
+	system := lensCamera(t, 2).Lens
+	filmDistance, err := system.FilmDistance(2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pupilRadius, err := system.ExitPupil(filmDistance, math.Hypot(36, 36)/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := image.Rect(8, 8, 16, 16)
+	exp := 0.0
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			exp += irradiance(system, filmDistance, pupilRadius, (0.5-(float64(x)+0.5)/64)*36, (0.5-(float64(y)+0.5)/64)*36)
+		}
+	}
+	exp /= float64(block.Dx()*block.Dy()) * irradiance(system, filmDistance, pupilRadius, 0, 0)
+	if act := lensRegion(t, block) / center; math.Abs(act-exp) > 0.05*exp {
+		t.Errorf("Expected the block to be %.3f times as bright as the center, but it is %.3f times", exp, act)
+	}
+}
+
+func TestLensSystemImage(t *testing.T) {
+	// the lens turns the image upside down on the film, which is turned back, so the sphere is seen up and to the right
+	sphere := glowing(vec.New(0.054, 0.054, -0.3), 0.006)
+	fb := renderSpheres(t, lensCamera(t, 0.3), vec.Empty(), sphere)
+	// away from the center the lens darkens the sphere a little
+	if p := peak(fb); p < 0.75 {
+		t.Errorf("Expected a sharp image of the sphere, but its peak is %v", p)
+	}
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if lit(fb, x, y) && (x < fb.Width/2 || y >= fb.Height/2) {
+				t.Fatalf("Expected the sphere up and to the right, but (%d, %d) is lit", x, y)
+			}
+		}
+	}
+
+	// focused far behind it, the sphere is blurred
+	if p := peak(renderSpheres(t, lensCamera(t, 5), vec.Empty(), sphere)); p > 0.6 {
+		t.Errorf("Expected the sphere to be out of focus, but its peak is %v", p)
+	}
+}
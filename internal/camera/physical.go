@@ -0,0 +1,168 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/util"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// How far the shutter is open over the time it is open, which weighs the moments motion blur is sampled at.
+type Shutter uint8
+
+const (
+	_        Shutter = iota
+	BOX              // opens and closes instantly, every moment weighs the same
+	TRIANGLE         // opens and closes steadily, fully open only halfway through
+	COSINE           // opens and closes smoothly along a raised cosine, like a leaf shutter
+)
+
+var shutterNames = map[Shutter]string{
+	BOX:      "box",
+	TRIANGLE: "triangle",
+	COSINE:   "cosine",
+}
+
+func (s Shutter) String() string {
+	if name, ok := shutterNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Shutter(%d)", s)
+}
+
+// ParseShutter returns the shutter curve with the given name.
+func ParseShutter(name string) (Shutter, error) {
+	for s, n := range shutterNames {
+		if strings.EqualFold(name, n) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown shutter curve %q (supported: box, triangle, cosine)", name)
+}
+
+// The width in millimeters of a full frame sensor.
+const defaultSensorWidth = 36
+
+// Resolution of the grid an aperture mask is sampled on.
+const apertureMaskResolution = 64
+
+// Returns the vertical field of view in radians, given by the focal length and sensor width of a physical camera
+// and by VerticalFOV otherwise.
+func (c *Camera) fieldOfView() float64 {
+	if c.FocalLength > 0 {
+		sensorHeight := c.SensorWidth * float64(c.imageHeight) / float64(c.Width)
+		return 2 * math.Atan(sensorHeight/(2*c.FocalLength))
+	}
+	return util.DegressToRadians(c.VerticalFOV)
+}
+
+// Returns the radius of the lens in world units, taken to be meters for a physical camera, where it is the radius
+// of the entrance pupil: the focal length over the f-number, halved. Otherwise it is given by DefocusAngle.
+func (c *Camera) lensRadius() float64 {
+	if c.FocalLength > 0 && c.FStop > 0 {
+		return c.FocalLength / (2 * c.FStop) / 1000
+	}
+	return c.FocusDistance * math.Tan(util.DegressToRadians(c.DefocusAngle/2.0))
+}
+
+// Returns the factor radiance is scaled by to expose it as a camera with the given ISO, exposure time and f-number
+// would. The saturation based sensitivity puts white at 1.2 times 2^EV100 nits, where EV100 = log2(N²/t · 100/ISO).
+// Without an ISO radiance is left as it is.
+func (c *Camera) exposureScale() float64 {
+	if c.ISO <= 0 || c.ExposureTime <= 0 || c.FStop <= 0 {
+		return 1
+	}
+	return c.ExposureTime * c.ISO / (120 * c.FStop * c.FStop)
+}
+
+// Samples the moment a ray is cast at, between ShutterOpen and ShutterClose, weighted by the shutter curve.
+func (c *Camera) shutterTime(rng *rand.Rand) float64 {
+	u := rng.Float64()
+	var t float64
+	switch c.ShutterCurve {
+	case TRIANGLE:
+		if u < 0.5 {
+			t = math.Sqrt(u / 2)
+		} else {
+			t = 1 - math.Sqrt((1-u)/2)
+		}
+	case COSINE:
+		// invert the integral t - sin(2πt)/2π of 1 - cos(2πt) with a few Newton steps from the straight line
+		t = u
+		for range 6 {
+			pdf := 1 - math.Cos(2*math.Pi*t)
+			if pdf < 1e-6 {
+				break
+			}
+			t -= (t - math.Sin(2*math.Pi*t)/(2*math.Pi) - u) / pdf
+			t = min(max(t, 0), 1)
+		}
+	default:
+		t = u
+	}
+	return c.ShutterOpen + t*(c.ShutterClose-c.ShutterOpen)
+}
+
+// Prepares the sampling of the aperture's shape, returning an error for a mask which lets no light through.
+func (c *Camera) initializeAperture() error {
+	c.apertureCDF = nil
+	if c.ApertureMask == nil {
+		return nil
+	}
+	// the mask is stretched over the square around the lens, with u across and v up, and solid textures are evaluated
+	// at the point on the lens in units of its radius
+	c.apertureCDF = make([]float64, apertureMaskResolution*apertureMaskResolution)
+	total := 0.0
+	for y := range apertureMaskResolution {
+		for x := range apertureMaskResolution {
+			u := (float64(x) + 0.5) / apertureMaskResolution
+			v := (float64(y) + 0.5) / apertureMaskResolution
+			color := c.ApertureMask.Value(u, v, vec.New(2*u-1, 2*v-1, 0))
+			total += max(0, (color.X()+color.Y()+color.Z())/3)
+			c.apertureCDF[y*apertureMaskResolution+x] = total
+		}
+	}
+	if total <= 0 {
+		return errors.New("the aperture mask is black everywhere, so no light passes the lens")
+	}
+	for k := range c.apertureCDF {
+		c.apertureCDF[k] /= total
+	}
+	return nil
+}
+
+// Returns a random point on the aperture, in units of the lens' radius. Points on a mask are drawn in proportion
+// to its brightness, points on a polygon or circle uniformly.
+func (c *Camera) apertureSample(rng *rand.Rand) *vec.Vec3 {
+	if c.apertureCDF != nil {
+		k := sort.SearchFloat64s(c.apertureCDF, rng.Float64())
+		k = min(k, len(c.apertureCDF)-1)
+		x := (float64(k%apertureMaskResolution)+rng.Float64())/apertureMaskResolution*2 - 1
+		y := (float64(k/apertureMaskResolution)+rng.Float64())/apertureMaskResolution*2 - 1
+		return vec.New(x, y, 0)
+	}
+	if c.ApertureBlades >= 3 {
+		return c.polygonSample(rng)
+	}
+	return randomUnitDisk(rng)
+}
+
+// Returns a uniformly random point on the regular polygon formed by the aperture's blades, inscribed in the unit
+// circle, by picking one of the triangles between its center and edges and a point on it.
+func (c *Camera) polygonSample(rng *rand.Rand) *vec.Vec3 {
+	n := float64(c.ApertureBlades)
+	side := math.Floor(rng.Float64() * n)
+	rotation := util.DegressToRadians(c.ApertureRotation) + math.Pi/2
+	a0 := rotation + 2*math.Pi*side/n
+	a1 := rotation + 2*math.Pi*(side+1)/n
+	s, t := rng.Float64(), rng.Float64()
+	if s+t > 1 {
+		s, t = 1-s, 1-t
+	}
+	return vec.New(s*math.Cos(a0)+t*math.Cos(a1), s*math.Sin(a0)+t*math.Sin(a1), 0)
+}
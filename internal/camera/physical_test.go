@@ -0,0 +1,142 @@
+package camera_test
+
+import (
+	"context"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func TestParseShutter(t *testing.T) {
+	for _, s := range []camera.Shutter{camera.BOX, camera.TRIANGLE, camera.COSINE} {
+		parsed, err := camera.ParseShutter(s.String())
+		if err != nil || parsed != s {
+			t.Errorf("Expected %v, but got %v (%v)", s, parsed, err)
+		}
+	}
+	if _, err := camera.ParseShutter("rolling"); err == nil {
+		t.Error("Expected an error for an unknown shutter curve")
+	}
+}
+
+func TestFocalLengthSetsFieldOfView(t *testing.T) {
+	// a 36mm wide sensor behind an 18mm lens sees 90 degrees across
+	physical := renderSpheres(t, &camera.Camera{Width: 32, FocalLength: 18}, vec.Empty(), glowing(vec.New(0, 0, -4), 1))
+	pinhole := renderSpheres(t, &camera.Camera{Width: 32, VerticalFOV: 90}, vec.Empty(), glowing(vec.New(0, 0, -4), 1))
+	for y := range 32 {
+		for x := range 32 {
+			if lit(physical, x, y) != lit(pinhole, x, y) {
+				t.Fatalf("Expected an 18mm lens to see what a 90 degree field of view does, but they differ at (%d, %d)", x, y)
+			}
+		}
+	}
+}
+
+func TestExposure(t *testing.T) {
+	// at ISO 100 and f/1, 1.2 seconds leaves radiance as it is
+	base := renderSpheres(t, &camera.Camera{Width: 8, FStop: 1, ISO: 100, ExposureTime: 1.2}, vec.New(1, 1, 1))
+	// two stops more sensitive and one stop narrower doubles it
+	brighter := renderSpheres(t, &camera.Camera{Width: 8, FStop: math.Sqrt2, ISO: 400, ExposureTime: 1.2}, vec.New(1, 1, 1))
+	if c := base.Color(4, 4); math.Abs(c.X()-1) > 1e-9 {
+		t.Errorf("Expected a radiance of 1, but got %v", c)
+	}
+	if c := brighter.Color(4, 4); math.Abs(c.X()-2) > 1e-9 {
+		t.Errorf("Expected a radiance of 2, but got %v", c)
+	}
+}
+
+type aperture struct {
+	blades   int
+	rotation float64
+	mask     hittable.Texture
+}
+
+// Reports whether the pixel at (x, y) sees the out of focus image of a small light far down the view direction,
+// through a lens half a unit wide focused one unit away. The light is spread over the shape of the aperture,
+// 38 pixels from the center of the 96 pixel wide image to its rim.
+func bokehCovers(t *testing.T, a aperture, x, y int) bool {
+	t.Helper()
+	c := &camera.Camera{ApertureBlades: a.blades, ApertureRotation: a.rotation, ApertureMask: a.mask}
+	c.Width = 96
+	c.VerticalFOV = 2 * math.Atan(0.6) * 180 / math.Pi
+	c.FocusDistance = 1
+	c.DefocusAngle = 2 * math.Atan(0.5) * 180 / math.Pi
+	c.SamplesPerPixel = 4096
+	c.Seed = 1
+	c.Region = image.Rect(x, y, x+1, y+1)
+	c.Background = vec.Empty()
+	c.PositionCamera(vec.New(0, 0, 0), vec.New(0, 0, -1), vec.New(0, 1, 0))
+	world := hittable.NewHittableList(1)
+	world.Add(hittable.NewSphere(vec.New(0, 0, -10), 0.4, hittable.NewDiffuseLight(vec.New(100, 100, 100))))
+	if err := c.Render(context.Background(), world, hittable.NewHittableList(0)); err != nil {
+		t.Fatal(err)
+	}
+	// inside the image of the aperture about 10 samples see the light
+	return c.Framebuffer.Color(x, y).X() > 0.05
+}
+
+func TestApertureShape(t *testing.T) {
+	// points on the axes, which a square misses, and on the diagonals, which a diamond misses
+	axes := [][2]int{{48, 14}, {82, 48}, {48, 82}, {14, 48}}
+	diagonals := [][2]int{{24, 24}, {72, 24}, {24, 72}, {72, 72}}
+	for _, tc := range []struct {
+		name            string
+		a               aperture
+		axes, diagonals bool
+	}{
+		{"circular", aperture{}, true, true},
+		{"diamond", aperture{blades: 4}, true, false},
+		{"square", aperture{blades: 4, rotation: 45}, false, true},
+		{"square mask", aperture{mask: hittable.NewSolidColorRGB(1, 1, 1)}, true, true},
+	} {
+		if !bokehCovers(t, tc.a, 48, 48) {
+			t.Errorf("Expected the light to be seen in the center through a %s aperture", tc.name)
+		}
+		for _, p := range axes {
+			if bokehCovers(t, tc.a, p[0], p[1]) != tc.axes {
+				t.Errorf("Expected %v to be lit %v through a %s aperture", p, tc.axes, tc.name)
+			}
+		}
+		for _, p := range diagonals {
+			if bokehCovers(t, tc.a, p[0], p[1]) != tc.diagonals {
+				t.Errorf("Expected %v to be lit %v through a %s aperture", p, tc.diagonals, tc.name)
+			}
+		}
+	}
+
+	// a checkerboard mask only lets light through the top right and bottom left quarters of the lens
+	quarters := aperture{mask: hittable.NewCheckerboardColors(1, vec.New(1, 1, 1), vec.New(0, 0, 0))}
+	if !bokehCovers(t, quarters, 72, 24) || !bokehCovers(t, quarters, 24, 72) || bokehCovers(t, quarters, 24, 24) || bokehCovers(t, quarters, 72, 72) {
+		t.Error("Expected the light to be spread over the quarters of the aperture the mask lets it through")
+	}
+}
+
+func TestBlackApertureMask(t *testing.T) {
+	c := &camera.Camera{Width: 8, DefocusAngle: 10, ApertureMask: hittable.NewSolidColorRGB(0, 0, 0)}
+	c.PositionCamera(nil, nil, nil)
+	c.Background = vec.Empty()
+	if err := c.Render(context.Background(), hittable.NewHittableList(0), hittable.NewHittableList(0)); err == nil {
+		t.Error("Expected an error for an aperture which lets no light through")
+	}
+}
+
+func TestShutterInterval(t *testing.T) {
+	// the sphere moves from left to right while the shutter may be open, but is only open for the first quarter
+	moving := hittable.NewMotionSphere(vec.New(-2, 0, -4), vec.New(2, 0, -4), 0.5, hittable.NewDiffuseLight(vec.New(1, 1, 1)))
+	for _, curve := range []camera.Shutter{camera.BOX, camera.TRIANGLE, camera.COSINE} {
+		c := &camera.Camera{Width: 32, ShutterOpen: 0, ShutterClose: 0.25, ShutterCurve: curve}
+		fb := renderSpheres(t, c, vec.Empty(), moving)
+		left, right := 0.0, 0.0
+		for x := range 16 {
+			left += fb.Color(x, 16).X()
+			right += fb.Color(31-x, 16).X()
+		}
+		if left == 0 || right != 0 {
+			t.Errorf("Expected a %v shutter to only see the sphere on the left, but got %v on the left and %v on the right", curve, left, right)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package camera
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// How the camera maps the pixels of the image to the directions of its rays.
+type Projection uint8
+
+const (
+	_               Projection = iota
+	PERSPECTIVE                // a pinhole or thin lens, VerticalFOV spans the height of the image
+	ORTHOGRAPHIC               // parallel rays from a view OrthoHeight units tall, sizes don't change with distance
+	FISHEYE                    // a circular equidistant fisheye, VerticalFOV spans the diameter of a circle filling the height
+	EQUIRECTANGULAR            // longitude across and latitude down, every direction in an image twice as wide as it is tall
+)
+
+var projectionNames = map[Projection]string{
+	PERSPECTIVE:     "perspective",
+	ORTHOGRAPHIC:    "orthographic",
+	FISHEYE:         "fisheye",
+	EQUIRECTANGULAR: "equirectangular",
+}
+
+func (p Projection) String() string {
+	if name, ok := projectionNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Projection(%d)", p)
+}
+
+// ParseProjection returns the projection with the given name.
+func ParseProjection(name string) (Projection, error) {
+	for p, n := range projectionNames {
+		if strings.EqualFold(name, n) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown projection %q (supported: perspective, orthographic, fisheye, equirectangular)", name)
+}
+
+// Reports whether the projection sends rays in every direction from the camera's center rather than through
+// the pixels of a viewport.
+func (p Projection) panoramic() bool {
+	return p == FISHEYE || p == EQUIRECTANGULAR
+}
+
+// Returns the unit direction seen at the point (x, y) of the image, in pixels from its top left corner,
+// by a panoramic projection. Fisheye points outside the image circle see nothing and give nil.
+func (c *Camera) panoramicDirection(x, y float64) *vec.Vec3 {
+	var theta, phi float64 // angle from the view direction and around it, counterclockwise from u
+	switch c.Projection {
+	case FISHEYE:
+		radiansPerPixel := c.fov / float64(c.imageHeight)
+		dx := (x - float64(c.Width)/2) * radiansPerPixel
+		dy := (float64(c.imageHeight)/2 - y) * radiansPerPixel
+		theta = math.Hypot(dx, dy)
+		if theta > c.fov/2 {
+			return nil
+		}
+		phi = math.Atan2(dy, dx)
+	case EQUIRECTANGULAR:
+		longitude := (x/float64(c.Width) - 0.5) * 2 * math.Pi
+		latitude := (0.5 - y/float64(c.imageHeight)) * math.Pi
+		// latitude rises towards v, longitude turns from the view direction towards u
+		return c.u.Scale(math.Cos(latitude) * math.Sin(longitude)).
+			Add(c.v.Scale(math.Sin(latitude))).
+			Sub(c.w.Scale(math.Cos(latitude) * math.Cos(longitude)))
+	}
+	sinTheta := math.Sin(theta)
+	return c.u.Scale(sinTheta * math.Cos(phi)).
+		Add(c.v.Scale(sinTheta * math.Sin(phi))).
+		Sub(c.w.Scale(math.Cos(theta)))
+}
@@ -0,0 +1,106 @@
+package camera_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func TestParseProjection(t *testing.T) {
+	for _, p := range []camera.Projection{camera.PERSPECTIVE, camera.ORTHOGRAPHIC, camera.FISHEYE, camera.EQUIRECTANGULAR} {
+		parsed, err := camera.ParseProjection(p.String())
+		if err != nil || parsed != p {
+			t.Errorf("Expected %v, but got %v (%v)", p, parsed, err)
+		}
+	}
+	if _, err := camera.ParseProjection("cylindrical"); err == nil {
+		t.Error("Expected an error for an unknown projection")
+	}
+}
+
+// Renders glowing spheres against the background with the camera at the origin looking down -z.
+func renderSpheres(t *testing.T, c *camera.Camera, background *vec.Vec3, spheres ...hittable.Hittable) *framebuffer.Framebuffer {
+	t.Helper()
+	world := hittable.NewHittableList(len(spheres))
+	for _, s := range spheres {
+		world.Add(s)
+	}
+	c.SamplesPerPixel = 4
+	c.Seed = 1
+	c.Background = background
+	c.PositionCamera(vec.New(0, 0, 0), vec.New(0, 0, -1), vec.New(0, 1, 0))
+	if err := c.Render(context.Background(), world, hittable.NewHittableList(0)); err != nil {
+		t.Fatal(err)
+	}
+	return c.Image()
+}
+
+func glowing(center *vec.Vec3, radius float64) hittable.Hittable {
+	return hittable.NewSphere(center, radius, hittable.NewDiffuseLight(vec.New(1, 1, 1)))
+}
+
+func lit(fb *framebuffer.Framebuffer, x, y int) bool {
+	return fb.Color(x, y).X() > 0.5
+}
+
+func TestOrthographicSizeIgnoresDistance(t *testing.T) {
+	for _, z := range []float64{-5, -50} {
+		c := &camera.Camera{Width: 32, Projection: camera.ORTHOGRAPHIC, OrthoHeight: 4}
+		fb := renderSpheres(t, c, vec.Empty(), glowing(vec.New(0, 0, z), 1))
+		// a pixel is an eighth of a unit wide, so the sphere spans the 16 pixels around the center
+		if !lit(fb, 16, 16) || !lit(fb, 21, 16) || lit(fb, 25, 16) || lit(fb, 16, 6) {
+			t.Errorf("Expected a sphere 16 pixels across at z=%v", z)
+		}
+	}
+}
+
+func TestFisheyeImageCircle(t *testing.T) {
+	c := &camera.Camera{Width: 32, Projection: camera.FISHEYE, VerticalFOV: 180}
+	fb := renderSpheres(t, c, vec.New(1, 1, 1))
+	if !lit(fb, 16, 16) || !lit(fb, 1, 16) || !lit(fb, 16, 30) {
+		t.Error("Expected the background to fill the image circle")
+	}
+	if fb.Color(0, 0).Length() != 0 || fb.Color(31, 31).Length() != 0 {
+		t.Error("Expected the corners outside the image circle to be black")
+	}
+
+	// at 180 degrees the edge of the circle looks sideways
+	c = &camera.Camera{Width: 32, Projection: camera.FISHEYE, VerticalFOV: 180}
+	fb = renderSpheres(t, c, vec.Empty(), glowing(vec.New(10, 0, 0), 2))
+	if !lit(fb, 31, 16) || lit(fb, 16, 16) || lit(fb, 0, 16) {
+		t.Error("Expected a sphere to the right to be seen at the right edge of the circle")
+	}
+}
+
+func TestEquirectangularSeesAllAround(t *testing.T) {
+	c := &camera.Camera{Width: 64, AspectRatio: 2, Projection: camera.EQUIRECTANGULAR}
+	fb := renderSpheres(t, c, vec.Empty(),
+		glowing(vec.New(0, 0, -10), 2), // ahead, in the center
+		glowing(vec.New(0, 0, 10), 2),  // behind, split across the left and right edges
+		glowing(vec.New(0, 10, 0), 2),  // above, along the top row
+		glowing(vec.New(10, 0, 0), 2),  // to the right, three quarters across
+	)
+	for _, p := range [][2]int{{32, 16}, {0, 16}, {63, 16}, {5, 0}, {40, 0}, {48, 16}} {
+		if !lit(fb, p[0], p[1]) {
+			t.Errorf("Expected a sphere to be seen at %v", p)
+		}
+	}
+	for _, p := range [][2]int{{16, 16}, {32, 31}} {
+		if lit(fb, p[0], p[1]) {
+			t.Errorf("Expected nothing to be seen at %v", p)
+		}
+	}
+}
+
+func TestPanoramicDefocus(t *testing.T) {
+	// a sphere at the focus distance stays sharp, where a view without defocus would see it
+	c := &camera.Camera{Width: 64, AspectRatio: 2, Projection: camera.EQUIRECTANGULAR, DefocusAngle: 20, FocusDistance: 10}
+	fb := renderSpheres(t, c, vec.Empty(), glowing(vec.New(0, 0, -10), 2))
+	if !lit(fb, 32, 16) || fb.Color(16, 16).Length() != 0 {
+		t.Error("Expected the sphere in focus to stay where it is")
+	}
+}
@@ -0,0 +1,129 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Renders one pass over every tile using a fixed pool of MaxThreads workers, until ctx is cancelled.
+func (c *Camera) renderPass(ctx context.Context, world, lights hittable.Hittable, pass int) {
+	if c.MaxThreads <= 1 {
+		// use a low-overhead synchronous renderer if we're only alloted one thread.
+		for _, tile := range c.tiles {
+			if ctx.Err() != nil {
+				return
+			}
+			c.renderTile(ctx, world, lights, pass, tile)
+		}
+		return
+	}
+
+	// a worker which panics stops the others and the panic is raised again here, where the caller can recover it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var panicked any
+	var once sync.Once
+	work := make(chan image.Rectangle)
+	wg := sync.WaitGroup{}
+	for range c.MaxThreads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicked = fmt.Sprintf("%v\n\n%s", r, debug.Stack()) })
+					cancel()
+					// keep taking tiles, so none are left waiting for a worker
+					for range work {
+					}
+				}
+			}()
+			for tile := range work {
+				c.renderTile(ctx, world, lights, pass, tile)
+			}
+		}()
+	}
+	for _, tile := range c.tiles {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- tile
+	}
+	close(work)
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// Calculates the pixel data for one tile of the image. Once the time budget is exceeded, tiles are skipped.
+// Once ctx is cancelled the rest of the tile is left unrendered and it isn't reported as done.
+func (c *Camera) renderTile(ctx context.Context, world, lights hittable.Hittable, pass int, tile image.Rectangle) {
+	if c.budgetExceeded() {
+		c.advanceProgress(1)
+		return
+	}
+	pcg := rand.NewPCG(0, 0)
+	rng := rand.New(pcg)
+	rays := 0
+	defer func() { c.rays.Add(uint64(rays)) }()
+	for j := tile.Min.Y; j < tile.Max.Y; j++ {
+		if ctx.Err() != nil {
+			return
+		}
+		for i := tile.Min.X; i < tile.Max.X; i++ {
+			c.seedSampler(pcg, pass, i, j)
+			c.samplePixel(rng, world, lights, i, j, &rays)
+		}
+	}
+	c.advanceProgress(1)
+}
+
+// Seeds the sampler for one pixel of one pass. Every (pass, pixel) pair draws its camera samples from its own stream,
+// so which positions a pixel is sampled at only depends on the seed and the pass, independent of tiling and thread
+// scheduling. The paths traced from them still scatter with the global random numbers and differ between runs.
+func (c *Camera) seedSampler(pcg *rand.PCG, pass, i, j int) {
+	pcg.Seed(c.Seed, uint64(pass)<<32|uint64(j*c.frame.Dx()+i))
+}
+
+// Traces the stratified samples of the pixel at (i, j) and accumulates them into the framebuffer.
+// The number of rays traced is added to rays.
+func (c *Camera) samplePixel(rng *rand.Rand, world, lights hittable.Hittable, i, j int, rays *int) {
+	pixelColor := vec.Empty()
+	var aovSums []*vec.Vec3
+	if c.aovs != nil {
+		aovSums = make([]*vec.Vec3, len(c.AOVs))
+		for k := range aovSums {
+			aovSums[k] = vec.Empty()
+		}
+	}
+
+	// Perform stratification
+	for s_i := range c.sppSqrt {
+		for s_j := range c.sppSqrt {
+			r, weight := c.getRay(rng, i, j, s_j, s_i)
+			if r == nil {
+				continue
+			}
+			if aovSums != nil {
+				c.sampleAOVs(r, world, aovSums, s_i == 0 && s_j == 0)
+			}
+			color := c.rayColor(r, world, lights, c.MaxDepth, rays)
+			if weight != 1 {
+				color = color.Scale(weight)
+			}
+			pixelColor.AddInplace(color)
+		}
+	}
+	c.Framebuffer.AddSamples(i, j, pixelColor.Scale(c.exposure), c.sppSqrt*c.sppSqrt)
+	if aovSums != nil {
+		c.addAOVs(i, j, aovSums, c.sppSqrt*c.sppSqrt)
+	}
+}
@@ -0,0 +1,41 @@
+package camera_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/interval"
+	"github.com/nsp5488/go_raytracer/internal/ray"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// A sphere which panics when it is hit.
+type brokenSphere struct {
+	hittable.Hittable
+}
+
+func (b brokenSphere) Hit(r *ray.Ray, rayT interval.Interval, record *hittable.HitRecord) bool {
+	if b.Hittable.Hit(r, rayT, record) {
+		panic("broken sphere")
+	}
+	return false
+}
+
+func TestWorkerPanic(t *testing.T) {
+	world := hittable.NewHittableList(1)
+	world.Add(brokenSphere{glowing(vec.New(0, 0, -2), 0.5)})
+	c := &camera.Camera{Width: 32, SamplesPerPixel: 1, MaxThreads: 4, TileSize: 8, Background: vec.Empty()}
+	c.PositionCamera(nil, nil, nil)
+
+	// the panic of a worker reaches the caller, which can recover it
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "broken sphere") {
+			t.Errorf("Expected the worker's panic, but got %v", r)
+		}
+	}()
+	c.Render(context.Background(), world, hittable.NewHittableList(0))
+	t.Error("Expected the render to panic")
+}
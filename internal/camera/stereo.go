@@ -0,0 +1,74 @@
+package camera
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// How the views of the two eyes are arranged in a stereo image.
+type Stereo uint8
+
+const (
+	_            Stereo = iota
+	MONO                // a single view from the camera's center
+	SIDE_BY_SIDE        // the left eye's view on the left, the right eye's on the right
+	TOP_BOTTOM          // the left eye's view on top, the right eye's below
+)
+
+var stereoNames = map[Stereo]string{
+	MONO:         "mono",
+	SIDE_BY_SIDE: "side-by-side",
+	TOP_BOTTOM:   "top-bottom",
+}
+
+func (s Stereo) String() string {
+	if name, ok := stereoNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Stereo(%d)", s)
+}
+
+// ParseStereo returns the stereo layout with the given name.
+func ParseStereo(name string) (Stereo, error) {
+	for s, n := range stereoNames {
+		if strings.EqualFold(name, n) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown stereo layout %q (supported: mono, side-by-side, top-bottom)", name)
+}
+
+// The average distance between the eyes of an adult, in meters.
+const defaultInterocular = 0.064
+
+// Returns the eye rendering the pixel at (i, j) of the image, -1 for the left one, 1 for the right one
+// and 0 for a mono image, along with the pixel's position in that eye's view.
+func (c *Camera) eye(i, j int) (eye float64, x, y int) {
+	switch c.Stereo {
+	case SIDE_BY_SIDE:
+		if i < c.Width {
+			return -1, i, j
+		}
+		return 1, i - c.Width, j
+	case TOP_BOTTOM:
+		if j < c.imageHeight {
+			return -1, i, j
+		}
+		return 1, i, j - c.imageHeight
+	}
+	return 0, i, j
+}
+
+// Returns the offset of an eye from the camera's center for a panoramic view in the given direction.
+// This is omni-directional stereo: the eyes turn with the view direction, so they lie on a circle
+// around the center, on either side of the horizontal part of the direction. Looking straight up or down
+// they meet in the center.
+func (c *Camera) panoramicEyeOffset(eye float64, direction *vec.Vec3) *vec.Vec3 {
+	right := direction.Cross(c.v)
+	if right.NearZero() {
+		return vec.Empty()
+	}
+	return right.UnitVector().Scale(eye * c.Interocular / 2)
+}
@@ -0,0 +1,75 @@
+package camera_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func TestStereoImageSize(t *testing.T) {
+	for stereo, expected := range map[camera.Stereo]image.Point{
+		camera.MONO:         image.Pt(40, 20),
+		camera.SIDE_BY_SIDE: image.Pt(80, 20),
+		camera.TOP_BOTTOM:   image.Pt(40, 40),
+	} {
+		c := camera.Camera{Width: 40, AspectRatio: 2, Stereo: stereo}
+		c.ApplyDefaults()
+		if w, h := c.ImageSize(); w != expected.X || h != expected.Y {
+			t.Errorf("Expected a %v image to be %v, but got %dx%d", stereo, expected, w, h)
+		}
+	}
+}
+
+// Returns the average column of the lit pixels of a view.
+func centroid(fb *framebuffer.Framebuffer, view image.Rectangle) float64 {
+	sum, n := 0.0, 0
+	for y := view.Min.Y; y < view.Max.Y; y++ {
+		for x := view.Min.X; x < view.Max.X; x++ {
+			if lit(fb, x, y) {
+				sum += float64(x - view.Min.X)
+				n++
+			}
+		}
+	}
+	return sum / float64(n)
+}
+
+func TestStereoParallax(t *testing.T) {
+	left, right := image.Rect(0, 0, 32, 32), image.Rect(32, 0, 64, 32)
+	sphere := glowing(vec.New(0, 0, -4), 0.5)
+
+	// parallel views see a nearby sphere shifted towards the other eye
+	c := &camera.Camera{Width: 32, Stereo: camera.SIDE_BY_SIDE, Interocular: 1, VerticalFOV: 40}
+	fb := renderSpheres(t, c, vec.Empty(), sphere)
+	if l, r := centroid(fb, left), centroid(fb, right); l <= 17 || r >= 15 {
+		t.Errorf("Expected the left eye to see the sphere right of center and the right eye left of it, but got %v and %v", l, r)
+	}
+
+	// views converging at the sphere both see it in the center
+	c = &camera.Camera{Width: 32, Stereo: camera.SIDE_BY_SIDE, Interocular: 1, VerticalFOV: 40, Convergence: 4}
+	fb = renderSpheres(t, c, vec.Empty(), sphere)
+	if l, r := centroid(fb, left), centroid(fb, right); math.Abs(l-15.5) > 0.5 || math.Abs(r-15.5) > 0.5 {
+		t.Errorf("Expected both eyes to see the sphere in the center, but got %v and %v", l, r)
+	}
+}
+
+func TestOmnidirectionalStereo(t *testing.T) {
+	c := &camera.Camera{Width: 64, AspectRatio: 2, Projection: camera.EQUIRECTANGULAR, Stereo: camera.TOP_BOTTOM, Interocular: 1}
+	fb := renderSpheres(t, c, vec.Empty(), glowing(vec.New(0, 0, -3), 0.5), glowing(vec.New(3, 0, 0), 0.5))
+	top, bottom := image.Rect(0, 0, 64, 32), image.Rect(0, 32, 64, 64)
+	// the eyes turn with the view, so both spheres are shifted towards the other eye in each view
+	ahead := func(view image.Rectangle) float64 {
+		return centroid(fb, image.Rect(16, view.Min.Y, 40, view.Max.Y)) + 16
+	}
+	aside := func(view image.Rectangle) float64 {
+		return centroid(fb, image.Rect(40, view.Min.Y, 64, view.Max.Y)) + 40
+	}
+	if ahead(top) <= ahead(bottom) || aside(top) <= aside(bottom) {
+		t.Errorf("Expected the left eye to see both spheres further right, but got %v and %v ahead, %v and %v aside",
+			ahead(top), ahead(bottom), aside(top), aside(bottom))
+	}
+}
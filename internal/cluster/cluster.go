@@ -0,0 +1,113 @@
+// Package cluster splits a render across worker processes connected over TCP.
+//
+// Workers connect to the coordinator, which sends each of them the Job: the scene document and the camera settings
+// every worker renders it with. It then hands out Tasks, each one pass of samples over one tile of the image,
+// and adds the radiance sums of their Results into its framebuffer. The camera's sample positions only depend on the
+// seed, the pass and the pixel, so no two tasks repeat each other's samples. Scattering, light sampling and media still
+// draw from each process's own random numbers, so the merged image is statistically equivalent to, not identical to,
+// the one a single machine would have rendered.
+// A task is re-issued when its worker disconnects or, with a timeout, stops answering.
+//
+// Messages are gob encoded, in this order:
+//
+//	worker → coordinator  Hello
+//	coordinator → worker  Job
+//	worker → coordinator  Ready
+//	coordinator → worker  Task, answered by a Result, until the coordinator closes the connection
+package cluster
+
+import (
+	"image"
+	"math/rand/v2"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+)
+
+// Version of the messages, workers speaking another version are turned away.
+const protocolVersion = 1
+
+// Hello introduces a worker to the coordinator.
+type Hello struct {
+	Version int
+	Name    string // identifies the worker in the coordinator's log
+	Threads int
+}
+
+// Job describes the render every worker takes part in.
+type Job struct {
+	Scene []byte // the scene document
+	Dir   string // directory the scene's relative file names are resolved against, unless the worker overrides it
+
+	// Settings overriding the scene's camera.
+	Width       int
+	AspectRatio float64
+	MaxDepth    int
+	Projection  camera.Projection
+	Stereo      camera.Stereo
+	Seed        uint64
+	Passes      int // passes over every tile
+	PassSamples int // samples per pixel taken by each pass
+}
+
+// Ready tells the coordinator whether the worker could load the scene.
+type Ready struct {
+	Err string // why the scene could not be loaded, empty if it was
+}
+
+// Task asks a worker to render one pass over a region of the image.
+type Task struct {
+	ID     int
+	Region image.Rectangle
+	Pass   int
+}
+
+// Result holds the radiance sums a worker accumulated for a task, cropped to the task's region.
+type Result struct {
+	Task        int
+	Framebuffer *framebuffer.Framebuffer
+	Rays        uint64
+}
+
+// Creates the job rendering a scene document with a camera configured by it, including any overrides.
+// The camera's unset settings are filled in and, unless it has one, it is given a seed all the workers share.
+func NewJob(c *camera.Camera, scene []byte, dir string) Job {
+	c.ApplyDefaults()
+	if c.Seed == 0 {
+		c.Seed = rand.Uint64()
+	}
+	passes, samples := c.Passes()
+	return Job{
+		Scene:       scene,
+		Dir:         dir,
+		Width:       c.Width,
+		AspectRatio: c.AspectRatio,
+		MaxDepth:    c.MaxDepth,
+		Projection:  c.Projection,
+		Stereo:      c.Stereo,
+		Seed:        c.Seed,
+		Passes:      passes,
+		PassSamples: samples,
+	}
+}
+
+// Loads the job's scene, resolving its files against dir, and returns a camera rendering one pass of it per call to
+// Render. Only the Region and FirstPass of the camera change between tasks.
+func (j Job) load(dir string) (*camera.Camera, *scene.Scene, error) {
+	c := &camera.Camera{}
+	s, err := scene.Parse(j.Scene, dir, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.Width = j.Width
+	c.AspectRatio = j.AspectRatio
+	c.MaxDepth = j.MaxDepth
+	c.Projection = j.Projection
+	c.Stereo = j.Stereo
+	c.Seed = j.Seed
+	c.SamplesPerPixel = j.PassSamples
+	c.PassSamples = 0
+	c.CropToRegion = true
+	return c, s, nil
+}
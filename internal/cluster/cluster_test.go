@@ -0,0 +1,173 @@
+package cluster_test
+
+import (
+	"context"
+	"encoding/gob"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/cluster"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+)
+
+// Only lights and the background are seen, so the image only depends on the camera's samples.
+const sceneJSON = `{
+	"camera": {"width": 40, "aspect_ratio": 1.25, "samples_per_pixel": 4, "vertical_fov": 40, "look_from": [0, 0, 5], "look_at": [0, 0, 0]},
+	"background": [0.1, 0.2, 0.3],
+	"objects": [
+		{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": {"type": "diffuse_light", "emit": [4, 2, 1]}},
+		{"type": "quad", "q": [-2, -2, -1], "u": [1.5, 0, 0], "v": [0, 1.5, 0], "material": {"type": "diffuse_light", "emit": [1, 3, 1]}}
+	]
+}`
+
+func newJob(t *testing.T) (cluster.Job, *camera.Camera, *scene.Scene) {
+	t.Helper()
+	c := &camera.Camera{}
+	s, err := scene.Parse([]byte(sceneJSON), ".", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PassSamples = 1
+	c.Seed = 7
+	return cluster.NewJob(c, []byte(sceneJSON), "."), c, s
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+// Connects a worker to the coordinator, which it serves until the job is done.
+func startWorker(t *testing.T, wg *sync.WaitGroup, ctx context.Context, addr string, w *cluster.Worker) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := w.Serve(ctx, conn); err != nil {
+			t.Errorf("Expected the worker to finish, but got %v", err)
+		}
+	}()
+}
+
+func TestDistributedRenderMatchesLocal(t *testing.T) {
+	job, c, s := newJob(t)
+	if job.Passes != 4 || job.PassSamples != 1 {
+		t.Fatalf("Expected 4 passes of 1 sample, but got %d of %d", job.Passes, job.PassSamples)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ln := listen(t)
+
+	// a worker which takes a task and dies without answering
+	dying, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	took := make(chan struct{})
+	go func() {
+		defer dying.Close()
+		enc, dec := gob.NewEncoder(dying), gob.NewDecoder(dying)
+		enc.Encode(cluster.Hello{Version: 1, Name: "dying"})
+		dec.Decode(&cluster.Job{})
+		enc.Encode(cluster.Ready{})
+		dec.Decode(&cluster.Task{})
+		close(took)
+	}()
+
+	co := &cluster.Coordinator{Job: job, TileSize: 16}
+	type result struct {
+		fb  *framebuffer.Framebuffer
+		err error
+	}
+	rendered := make(chan result)
+	go func() {
+		fb, err := co.Render(ctx, ln)
+		rendered <- result{fb, err}
+	}()
+	select {
+	case <-took:
+	case <-ctx.Done():
+		t.Fatal("Expected the dying worker to be given a task")
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "c"} {
+		startWorker(t, &wg, ctx, ln.Addr().String(), &cluster.Worker{Name: name, Threads: 2})
+	}
+	res := <-rendered
+	wg.Wait()
+	if res.err != nil {
+		t.Fatalf("Expected the render to finish, but got %v", res.err)
+	}
+	if co.Completion() != 1 || co.Rays() == 0 {
+		t.Errorf("Expected every task to be done, but got %v done and %d rays", co.Completion(), co.Rays())
+	}
+
+	if err := c.Render(context.Background(), s.World, s.Lights); err != nil {
+		t.Fatal(err)
+	}
+	local := c.Image()
+	if res.fb.Bounds() != local.Bounds() {
+		t.Fatalf("Expected a %v image, but got %v", local.Bounds(), res.fb.Bounds())
+	}
+	for y := range local.Height {
+		for x := range local.Width {
+			if n := res.fb.Samples(x, y); n != 4 {
+				t.Fatalf("Expected 4 samples at (%d, %d), but got %d", x, y, n)
+			}
+			act, exp := res.fb.Color(x, y), local.Color(x, y)
+			if math.Abs(act.X()-exp.X())+math.Abs(act.Y()-exp.Y())+math.Abs(act.Z()-exp.Z()) > 1e-9 {
+				t.Fatalf("Expected %v at (%d, %d) as rendered locally, but got %v", exp, x, y, act)
+			}
+		}
+	}
+}
+
+func TestCancelledRender(t *testing.T) {
+	job, _, _ := newJob(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	co := &cluster.Coordinator{Job: job}
+	fb, err := co.Render(ctx, listen(t))
+	if err != context.Canceled {
+		t.Errorf("Expected %v, but got %v", context.Canceled, err)
+	}
+	if fb == nil || fb.MinSamples() != 0 || co.Completion() != 0 {
+		t.Errorf("Expected an empty image, but got %v done", co.Completion())
+	}
+}
+
+func TestWorkerWithoutScene(t *testing.T) {
+	job, _, _ := newJob(t)
+	job.Scene = []byte(`{"objects": [{"type": "obj", "file": "missing.obj"}]}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ln := listen(t)
+	co := &cluster.Coordinator{Job: job}
+	go co.Render(ctx, ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &cluster.Worker{Threads: 1}
+	if err := w.Serve(ctx, conn); err == nil {
+		t.Error("Expected an error for a scene which can't be loaded")
+	}
+	if co.Completion() != 0 {
+		t.Errorf("Expected nothing to be rendered, but got %v", co.Completion())
+	}
+}
@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/progress"
+	"github.com/nsp5488/go_raytracer/internal/tiles"
+)
+
+// Coordinator hands out the tasks of a job to the workers which connect to it and merges their results.
+type Coordinator struct {
+	Job       Job
+	TileSize  int         // size in pixels of the square tiles tasks cover
+	TileOrder tiles.Order // order the tiles of each pass are handed out in
+	// A worker which takes longer than this over a task is dropped and its task re-issued.
+	// When 0, tasks are only re-issued once the worker's connection is lost.
+	TaskTimeout time.Duration
+	// Reports the progress of renders, in tasks. When nil, nothing is reported.
+	Progress progress.Reporter
+
+	mu          sync.Mutex
+	fb          *framebuffer.Framebuffer
+	done, total int
+	rays        uint64
+	finished    chan struct{} // closed once every task is done
+}
+
+// Render accepts workers on ln until every task is done or ctx is cancelled, and returns the merged image.
+// The listener is closed when Render returns. A cancelled render returns the tasks merged so far along with
+// the context's error, pixels which were never reached have no samples.
+func (co *Coordinator) Render(ctx context.Context, ln net.Listener) (*framebuffer.Framebuffer, error) {
+	defer ln.Close()
+	tasks := co.initialize()
+	queue := make(chan Task, len(tasks)) // never blocks, a task is either queued or with a single worker
+	for _, t := range tasks {
+		queue <- t
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if r, ok := co.Progress.(progress.Interruptible); ok {
+		r.OnInterrupt(cancel)
+	}
+	co.Progress.Start(len(tasks))
+	context.AfterFunc(ctx, func() { ln.Close() })
+
+	var workers sync.WaitGroup
+	accepted := make(chan struct{})
+	go func() {
+		defer close(accepted)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Stopped accepting workers: %v", err)
+				}
+				return
+			}
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				co.serve(ctx, conn, queue)
+			}()
+		}
+	}()
+
+	select {
+	case <-co.finished:
+	case <-ctx.Done():
+	}
+	err := ctx.Err()
+	cancel()
+	<-accepted
+	workers.Wait()
+	co.Progress.Finish()
+	if co.Completion() == 1 {
+		err = nil
+	}
+	return co.fb, err
+}
+
+// Splits the job into tasks, one per pass over each tile, and resets the merged image.
+func (co *Coordinator) initialize() []Task {
+	c := camera.Camera{Width: co.Job.Width, AspectRatio: co.Job.AspectRatio, Stereo: co.Job.Stereo}
+	c.ApplyDefaults()
+	width, height := c.ImageSize()
+	if co.TileSize <= 0 {
+		co.TileSize = 128
+	}
+	if co.TileOrder == 0 {
+		co.TileOrder = tiles.SCANLINE
+	}
+	if co.Progress == nil {
+		co.Progress = progress.Silent{}
+	}
+
+	var tasks []Task
+	regions := tiles.Split(image.Rect(0, 0, width, height), co.TileSize, co.TileOrder)
+	for pass := range max(co.Job.Passes, 1) {
+		for _, region := range regions {
+			tasks = append(tasks, Task{ID: len(tasks), Region: region, Pass: pass})
+		}
+	}
+	co.fb = framebuffer.New(width, height)
+	co.done, co.total, co.rays = 0, len(tasks), 0
+	co.finished = make(chan struct{})
+	return tasks
+}
+
+// Introduces a worker to the job and hands it tasks until they run out, ctx is cancelled or the worker fails,
+// in which case its task is put back in the queue.
+func (co *Coordinator) serve(ctx context.Context, conn net.Conn, queue chan Task) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+
+	name := conn.RemoteAddr().String()
+	var hello Hello
+	if err := dec.Decode(&hello); err != nil {
+		log.Printf("Worker %s did not introduce itself: %v", name, err)
+		return
+	}
+	if hello.Name != "" {
+		name = fmt.Sprintf("%s (%s)", hello.Name, name)
+	}
+	if hello.Version != protocolVersion {
+		log.Printf("Rejected worker %s: it speaks version %d of the protocol, not %d", name, hello.Version, protocolVersion)
+		return
+	}
+	if err := enc.Encode(co.Job); err != nil {
+		log.Printf("Error sending the job to worker %s: %v", name, err)
+		return
+	}
+	var ready Ready
+	if err := dec.Decode(&ready); err != nil || ready.Err != "" {
+		if err == nil {
+			err = errors.New(ready.Err)
+		}
+		log.Printf("Worker %s could not load the scene: %v", name, err)
+		return
+	}
+	log.Printf("Worker %s joined with %d threads", name, hello.Threads)
+
+	for {
+		var task Task
+		select {
+		case <-ctx.Done():
+			return
+		case task = <-queue:
+		}
+		result, err := co.run(conn, enc, dec, task)
+		if err != nil {
+			queue <- task
+			if ctx.Err() == nil {
+				log.Printf("Lost worker %s, re-issuing its task: %v", name, err)
+			}
+			return
+		}
+		co.merge(task, result)
+	}
+}
+
+// Sends a task to a worker and waits for its result.
+func (co *Coordinator) run(conn net.Conn, enc *gob.Encoder, dec *gob.Decoder, task Task) (Result, error) {
+	if co.TaskTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(co.TaskTimeout))
+	}
+	if err := enc.Encode(task); err != nil {
+		return Result{}, err
+	}
+	var result Result
+	if err := dec.Decode(&result); err != nil {
+		return Result{}, err
+	}
+	if result.Task != task.ID {
+		return Result{}, fmt.Errorf("expected the result of task %d, but got task %d", task.ID, result.Task)
+	}
+	if fb := result.Framebuffer; fb == nil || fb.Bounds() != task.Region.Sub(task.Region.Min) {
+		return Result{}, fmt.Errorf("the result of task %d does not match its %v region", task.ID, task.Region)
+	}
+	return result, nil
+}
+
+// Adds a task's result into the merged image.
+func (co *Coordinator) merge(task Task, result Result) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.fb.AddAt(result.Framebuffer, task.Region.Min)
+	co.rays += result.Rays
+	co.done++
+	co.Progress.Advance(1)
+	if co.done == co.total {
+		close(co.finished)
+	}
+}
+
+// Returns the fraction of the last render's tasks which were done, between 0 and 1.
+func (co *Coordinator) Completion() float64 {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	if co.total == 0 {
+		return 1
+	}
+	return float64(co.done) / float64(co.total)
+}
+
+// Returns the number of rays the workers traced for the last render.
+func (co *Coordinator) Rays() uint64 {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	return co.rays
+}
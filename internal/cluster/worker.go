@@ -0,0 +1,78 @@
+package cluster
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Worker renders the tasks a coordinator hands out.
+type Worker struct {
+	Name    string // identifies the worker in the coordinator's log
+	Threads int
+	// Directory the scene's relative file names are resolved against instead of the coordinator's,
+	// for workers on machines where the files are elsewhere.
+	Dir string
+}
+
+// Serve takes part in the coordinator's job over conn, rendering tasks until the coordinator closes the connection
+// once the job is done, which returns nil, or ctx is cancelled. The connection is closed when Serve returns.
+func (w *Worker) Serve(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+
+	if err := enc.Encode(Hello{Version: protocolVersion, Name: w.Name, Threads: w.Threads}); err != nil {
+		return w.failed(ctx, err)
+	}
+	var job Job
+	if err := dec.Decode(&job); err != nil {
+		return w.failed(ctx, fmt.Errorf("could not receive the job: %w", err))
+	}
+	dir := job.Dir
+	if w.Dir != "" {
+		dir = w.Dir
+	}
+	c, s, loadErr := job.load(dir)
+	ready := Ready{}
+	if loadErr != nil {
+		ready.Err = loadErr.Error()
+	}
+	if err := enc.Encode(ready); err != nil {
+		return w.failed(ctx, err)
+	}
+	if loadErr != nil {
+		return fmt.Errorf("could not load the scene: %w", loadErr)
+	}
+	c.MaxThreads = w.Threads
+
+	for {
+		var task Task
+		if err := dec.Decode(&task); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return w.failed(ctx, err)
+		}
+		c.Region = task.Region
+		c.FirstPass = task.Pass
+		if err := c.Render(ctx, s.World, s.Lights); err != nil {
+			return err
+		}
+		if err := enc.Encode(Result{Task: task.ID, Framebuffer: c.Image(), Rays: c.Rays()}); err != nil {
+			return w.failed(ctx, err)
+		}
+	}
+}
+
+// Returns the context's error instead of the one caused by closing the connection when ctx was cancelled.
+func (w *Worker) failed(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package denoise
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Options control the edge-avoiding à-trous wavelet filter.
+type Options struct {
+	Iterations  int     // number of filter passes, each one doubles the reach of the filter
+	ColorSigma  float64 // how different two colors may be before they stop being averaged, halved every pass
+	NormalSigma float64 // how different two normals may be before they stop being averaged
+	AlbedoSigma float64 // how different two albedos may be before they stop being averaged
+	Strength    float64 // blends between the noisy image (0) and the filtered image (1)
+}
+
+// Returns options which work well for low sample count previews.
+func DefaultOptions() Options {
+	return Options{Iterations: 5, ColorSigma: 1, NormalSigma: .3, AlbedoSigma: .1, Strength: 1}
+}
+
+// B3 spline kernel of the à-trous transform
+var kernel = [5]float64{1.0 / 16, 1.0 / 4, 3.0 / 8, 1.0 / 4, 1.0 / 16}
+
+// Don't divide by albedos darker than this when separating lighting from texture.
+const minAlbedo = 1e-3
+
+// Denoise filters the beauty image with an edge-avoiding à-trous wavelet transform (Dammertz et al. 2010),
+// using the albedo and normal buffers from the first hit to stop the filter from blurring across edges and textures.
+// Either guide may be nil. Pixels without samples are neither filtered nor used as neighbours.
+func Denoise(beauty, albedo, normal *framebuffer.Framebuffer, opts Options) (*framebuffer.Framebuffer, error) {
+	for _, guide := range []*framebuffer.Framebuffer{albedo, normal} {
+		if guide != nil && (guide.Width != beauty.Width || guide.Height != beauty.Height) {
+			return nil, fmt.Errorf("guide buffer is %dx%d, but the image is %dx%d", guide.Width, guide.Height, beauty.Width, beauty.Height)
+		}
+	}
+	width, height := beauty.Width, beauty.Height
+	n := width * height
+
+	valid := make([]bool, n)
+	color := make([][3]float64, n)
+	scale := make([][3]float64, n) // albedo factored out of the color, multiplied back in afterwards
+	normals := make([][3]float64, n)
+	albedos := make([][3]float64, n)
+	for y := range height {
+		for x := range width {
+			i := y*width + x
+			valid[i] = beauty.Samples(x, y) > 0
+			c := beauty.Color(x, y)
+			scale[i] = [3]float64{1, 1, 1}
+			if albedo != nil {
+				albedos[i] = components(albedo.Color(x, y))
+				for k, a := range albedos[i] {
+					if a > minAlbedo {
+						scale[i][k] = a
+					}
+				}
+			}
+			if normal != nil {
+				normals[i] = components(normal.Color(x, y))
+			}
+			for k := range 3 {
+				if v := c.Get(k); !math.IsNaN(v) {
+					color[i][k] = v / scale[i][k]
+				}
+			}
+		}
+	}
+
+	// Filter the lighting only, so texture detail carried by the albedo is preserved.
+	noisy := slices.Clone(color)
+	filtered := make([][3]float64, n)
+	colorSigma := opts.ColorSigma
+	for iteration := range opts.Iterations {
+		step := 1 << iteration
+		for y := range height {
+			for x := range width {
+				i := y*width + x
+				if !valid[i] {
+					continue
+				}
+				var sum [3]float64
+				weights := 0.0
+				for dy := -2; dy <= 2; dy++ {
+					qy := y + dy*step
+					if qy < 0 || qy >= height {
+						continue
+					}
+					for dx := -2; dx <= 2; dx++ {
+						qx := x + dx*step
+						if qx < 0 || qx >= width {
+							continue
+						}
+						j := qy*width + qx
+						if !valid[j] {
+							continue
+						}
+						w := kernel[dx+2] * kernel[dy+2] * edgeWeight(color[i], color[j], colorSigma)
+						if normal != nil {
+							w *= edgeWeight(normals[i], normals[j], opts.NormalSigma)
+						}
+						if albedo != nil {
+							w *= edgeWeight(albedos[i], albedos[j], opts.AlbedoSigma)
+						}
+						for k := range 3 {
+							sum[k] += w * color[j][k]
+						}
+						weights += w
+					}
+				}
+				for k := range 3 {
+					filtered[i][k] = sum[k] / weights
+				}
+			}
+		}
+		color, filtered = filtered, color
+		colorSigma /= 2
+	}
+
+	out := framebuffer.New(width, height)
+	for y := range height {
+		for x := range width {
+			i := y*width + x
+			if !valid[i] {
+				continue
+			}
+			var c [3]float64
+			for k := range 3 {
+				c[k] = (noisy[i][k]*(1-opts.Strength) + color[i][k]*opts.Strength) * scale[i][k]
+			}
+			out.Set(x, y, vec.New(c[0], c[1], c[2]), beauty.Samples(x, y))
+		}
+	}
+	return out, nil
+}
+
+// Weighs a neighbour by how similar its guide value is, a sigma of 0 disables the guide.
+func edgeWeight(p, q [3]float64, sigma float64) float64 {
+	if sigma <= 0 {
+		return 1
+	}
+	d := 0.0
+	for k := range 3 {
+		d += (p[k] - q[k]) * (p[k] - q[k])
+	}
+	return math.Exp(-d / (sigma * sigma))
+}
+
+func components(v *vec.Vec3) [3]float64 {
+	return [3]float64{v.X(), v.Y(), v.Z()}
+}
@@ -0,0 +1,113 @@
+package denoise_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/denoise"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Builds a gray image with uniform noise around the given brightness.
+func noisyImage(width, height int, brightness float64) *framebuffer.Framebuffer {
+	rng := rand.New(rand.NewSource(1))
+	fb := framebuffer.New(width, height)
+	for y := range height {
+		for x := range width {
+			v := brightness + (rng.Float64()-.5)*.4
+			fb.Set(x, y, vec.New(v, v, v), 1)
+		}
+	}
+	return fb
+}
+
+// Returns the variance of the red channel over the given columns.
+func variance(fb *framebuffer.Framebuffer, x0, x1 int) float64 {
+	sum, sumSq, n := 0.0, 0.0, 0.0
+	for y := range fb.Height {
+		for x := x0; x < x1; x++ {
+			v := fb.Color(x, y).X()
+			sum += v
+			sumSq += v * v
+			n++
+		}
+	}
+	mean := sum / n
+	return sumSq/n - mean*mean
+}
+
+func TestDenoiseReducesNoise(t *testing.T) {
+	fb := noisyImage(32, 32, .5)
+	out, err := denoise.Denoise(fb, nil, nil, denoise.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, after := variance(fb, 0, 32), variance(out, 0, 32)
+	if after > before/10 {
+		t.Errorf("Expected the variance to drop below %v, but got %v", before/10, after)
+	}
+}
+
+func TestDenoisePreservesNormalEdges(t *testing.T) {
+	fb := noisyImage(32, 32, .5)
+	normals := framebuffer.New(32, 32)
+	for y := range 32 {
+		for x := range 32 {
+			// the left half faces the camera and is darker than the right half, which faces up
+			if x < 16 {
+				normals.Set(x, y, vec.New(0, 0, 1), 1)
+				fb.Set(x, y, fb.Color(x, y).Scale(.2), 1)
+			} else {
+				normals.Set(x, y, vec.New(0, 1, 0), 1)
+			}
+		}
+	}
+	out, err := denoise.Denoise(fb, nil, normals, denoise.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := out.Color(15, 16).X(), out.Color(16, 16).X()
+	if left > .15 || right < .4 {
+		t.Errorf("Expected the edge to be preserved, but got %v and %v on either side", left, right)
+	}
+}
+
+func TestDenoiseStrength(t *testing.T) {
+	fb := noisyImage(8, 8, .5)
+	opts := denoise.DefaultOptions()
+	opts.Strength = 0
+	out, err := denoise.Denoise(fb, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range 8 {
+		for x := range 8 {
+			if exp, act := fb.Color(x, y), out.Color(x, y); !exp.Equals(act) {
+				t.Errorf("Expected %v, but got %v", exp, act)
+			}
+		}
+	}
+}
+
+func TestDenoiseSkipsUnsampledPixels(t *testing.T) {
+	fb := framebuffer.New(4, 1)
+	fb.Set(0, 0, vec.New(1, 1, 1), 1)
+	fb.Set(1, 0, vec.New(1, 1, 1), 1)
+	out, err := denoise.Denoise(fb, nil, nil, denoise.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := out.Color(0, 0); !c.Equals(vec.New(1, 1, 1)) {
+		t.Errorf("Expected %v, but got %v", vec.New(1, 1, 1), c)
+	}
+	if out.Samples(2, 0) != 0 {
+		t.Errorf("Expected %d, but got %d", 0, out.Samples(2, 0))
+	}
+}
+
+func TestDenoiseGuideSize(t *testing.T) {
+	if _, err := denoise.Denoise(framebuffer.New(4, 4), framebuffer.New(2, 2), nil, denoise.DefaultOptions()); err == nil {
+		t.Error("Expected an error for a guide of a different size")
+	}
+}
@@ -0,0 +1,485 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Maps a format name to the function reading it. Every format that can be written can also be read back.
+var decoders = map[string]func(io.Reader) (*framebuffer.Framebuffer, error){
+	"ppm":   decodePPM,
+	"png":   decodeImage,
+	"png16": decodeImage,
+	"jpeg":  decodeImage,
+	"exr":   decodeEXR,
+	"hdr":   decodeHDR,
+}
+
+// The largest image the decoders allocate a framebuffer for, 8192x8192. Sizes are read from the header before any
+// pixel data, so a corrupt or hostile header could otherwise ask for any amount of memory.
+const maxDecodePixels = 1 << 26
+
+// Checks the size of an image read from its header.
+func checkDecodeSize(width, height int) error {
+	if width > maxDecodePixels/height {
+		return fmt.Errorf("the %dx%d image is larger than the %d pixels that can be read", width, height, maxDecodePixels)
+	}
+	return nil
+}
+
+// Decode reads an image in the named format into a framebuffer of linear radiance.
+// 8 and 16-bit formats are assumed to be sRGB encoded, transparent pixels are left without samples.
+func Decode(in io.Reader, format string) (*framebuffer.Framebuffer, error) {
+	decode, ok := decoders[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown image format %q (supported: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return decode(in)
+}
+
+// DecodeFile reads an image, choosing the format by the extension of the file name.
+func DecodeFile(filename string) (*framebuffer.Framebuffer, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	format, ok := extensions[ext]
+	if !ok {
+		return nil, fmt.Errorf("cannot infer an image format from extension %q", ext)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fb, err := Decode(bufio.NewReader(file), format)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+	return fb, nil
+}
+
+// Reads PNG and JPEG images through the standard library.
+func decodeImage(in io.Reader) (*framebuffer.Framebuffer, error) {
+	img, _, err := image.Decode(in)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	fb := framebuffer.New(bounds.Dx(), bounds.Dy())
+	for y := range fb.Height {
+		for x := range fb.Width {
+			c := color.NRGBA64Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA64)
+			if c.A == 0 {
+				continue
+			}
+			fb.Set(x, y, linear(float64(c.R)/0xffff, float64(c.G)/0xffff, float64(c.B)/0xffff), 1)
+		}
+	}
+	return fb, nil
+}
+
+// Decodes sRGB encoded components in [0, 1] to a linear color.
+func linear(r, g, b float64) *vec.Vec3 {
+	return vec.New(tonemap.InverseSRGB(r), tonemap.InverseSRGB(g), tonemap.InverseSRGB(b))
+}
+
+// Reads ASCII (P3) and binary (P6) portable pixmaps.
+func decodePPM(in io.Reader) (*framebuffer.Framebuffer, error) {
+	r := bufio.NewReader(in)
+	magic, err := ppmToken(r)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "P3" && magic != "P6" {
+		return nil, fmt.Errorf("unsupported PPM type %q, expected P3 or P6", magic)
+	}
+	// width, height and the maximum sample value
+	var header [3]int
+	for i := range header {
+		token, err := ppmToken(r)
+		if err != nil {
+			return nil, fmt.Errorf("truncated PPM header: %w", err)
+		}
+		if _, err := fmt.Sscanf(token, "%d", &header[i]); err != nil || header[i] <= 0 {
+			return nil, fmt.Errorf("invalid PPM header value %q", token)
+		}
+	}
+	width, height, maxValue := header[0], header[1], header[2]
+	if maxValue > 0xffff {
+		return nil, fmt.Errorf("invalid PPM maximum value %d", maxValue)
+	}
+	if err := checkDecodeSize(width, height); err != nil {
+		return nil, err
+	}
+
+	fb := framebuffer.New(width, height)
+	sample := func() (float64, error) {
+		if magic == "P3" {
+			token, err := ppmToken(r)
+			if err != nil {
+				return 0, err
+			}
+			var v int
+			if _, err := fmt.Sscanf(token, "%d", &v); err != nil {
+				return 0, fmt.Errorf("invalid PPM sample %q", token)
+			}
+			return float64(v) / float64(maxValue), nil
+		}
+		// binary samples take 2 big-endian bytes when the maximum value doesn't fit in one
+		if maxValue > 0xff {
+			var v uint16
+			err := binary.Read(r, binary.BigEndian, &v)
+			return float64(v) / float64(maxValue), err
+		}
+		v, err := r.ReadByte()
+		return float64(v) / float64(maxValue), err
+	}
+	for y := range height {
+		for x := range width {
+			var rgb [3]float64
+			for i := range rgb {
+				if rgb[i], err = sample(); err != nil {
+					return nil, fmt.Errorf("truncated PPM data at pixel (%d, %d): %w", x, y, err)
+				}
+			}
+			fb.Set(x, y, linear(rgb[0], rgb[1], rgb[2]), 1)
+		}
+	}
+	return fb, nil
+}
+
+// Reads the next whitespace separated token of a PPM header, skipping comments.
+// The single whitespace character after the token is consumed, as required before binary data.
+func ppmToken(r *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return string(token), nil
+			}
+			return "", err
+		}
+		switch {
+		case b == '#' && len(token) == 0:
+			if _, err := r.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case b == ' ' || b == '\t' || b == '\r' || b == '\n':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, b)
+		}
+	}
+}
+
+// Reads Radiance RGBE images, both flat and run length encoded. Only the standard -Y H +X W orientation is supported.
+func decodeHDR(in io.Reader) (*framebuffer.Framebuffer, error) {
+	r := bufio.NewReader(in)
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "#?") {
+		return nil, fmt.Errorf("missing Radiance header")
+	}
+	for {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("truncated Radiance header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if format, ok := strings.CutPrefix(line, "FORMAT="); ok && format != "32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("unsupported Radiance pixel format %q", format)
+		}
+	}
+	var width, height int
+	line, err = r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if _, err := fmt.Sscanf(line, "-Y %d +X %d", &height, &width); err != nil || width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("unsupported Radiance resolution %q, expected -Y height +X width", strings.TrimSpace(line))
+	}
+	if err := checkDecodeSize(width, height); err != nil {
+		return nil, err
+	}
+
+	fb := framebuffer.New(width, height)
+	scanline := make([]byte, 4*width)
+	for y := range height {
+		if err := readRGBEScanline(r, scanline); err != nil {
+			return nil, fmt.Errorf("truncated Radiance data on scanline %d: %w", y, err)
+		}
+		for x := range width {
+			fb.Set(x, y, fromRGBE([4]byte(scanline[4*x:4*x+4])), 1)
+		}
+	}
+	return fb, nil
+}
+
+// Reads one scanline of RGBE pixels into line. Run length encoded scanlines start with 2, 2 and the width,
+// followed by the runs of each component in turn.
+func readRGBEScanline(r *bufio.Reader, line []byte) error {
+	width := len(line) / 4
+	start, err := r.Peek(4)
+	if err != nil {
+		return err
+	}
+	if width < 8 || width > 0x7fff || start[0] != 2 || start[1] != 2 || int(start[2])<<8|int(start[3]) != width {
+		_, err := io.ReadFull(r, line)
+		return err
+	}
+	r.Discard(4)
+	for c := range 4 {
+		for x := 0; x < width; {
+			count, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			run := count > 128
+			if run {
+				count -= 128
+			}
+			if count == 0 || x+int(count) > width {
+				return fmt.Errorf("invalid run length %d", count)
+			}
+			var value byte
+			if run {
+				if value, err = r.ReadByte(); err != nil {
+					return err
+				}
+			}
+			for range count {
+				if !run {
+					if value, err = r.ReadByte(); err != nil {
+						return err
+					}
+				}
+				line[4*x+c] = value
+				x++
+			}
+		}
+	}
+	return nil
+}
+
+// Converts a shared exponent RGBE pixel to a linear color.
+func fromRGBE(rgbe [4]byte) *vec.Vec3 {
+	if rgbe[3] == 0 {
+		return vec.Empty()
+	}
+	scale := math.Ldexp(1, int(rgbe[3])-(128+8))
+	return vec.New((float64(rgbe[0])+.5)*scale, (float64(rgbe[1])+.5)*scale, (float64(rgbe[2])+.5)*scale)
+}
+
+// OpenEXR pixel types and version flags understood by the reader.
+const (
+	exrPixelUint  = 0
+	exrPixelHalf  = 1
+	exrTiledFlag  = 0x200
+	exrMultiFlag  = 0x1000
+	exrDeepFlag   = 0x800
+	exrPixelBytes = 4
+)
+
+// The component of a decoded pixel each channel is read into: red, green, blue, luminance and alpha.
+var exrComponents = map[string]int{"R": 0, "G": 1, "B": 2, "Y": 3, "A": 4}
+
+// A channel as listed in the header of an OpenEXR file.
+type exrChannelInfo struct {
+	name      string
+	pixelType int32
+}
+
+// Reads uncompressed, single part, scanline OpenEXR images such as those written by EXR. The R, G and B channels
+// are read, or Y for grayscale images, and pixels with an alpha of 0 are left without samples. Other layers are ignored.
+func decodeEXR(in io.Reader) (*framebuffer.Framebuffer, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	le := binary.LittleEndian
+	if len(data) < 8 || le.Uint32(data) != exrMagic {
+		return nil, fmt.Errorf("not an OpenEXR file")
+	}
+	version := le.Uint32(data[4:])
+	if version&0xff != exrVersion || version&(exrTiledFlag|exrMultiFlag|exrDeepFlag) != 0 {
+		return nil, fmt.Errorf("unsupported OpenEXR file, only single part scanline images can be read")
+	}
+
+	// attributes: name, type, size and value, until an empty name
+	var channels []exrChannelInfo
+	var window [4]int32
+	hasWindow := false
+	pos := 8
+	cstring := func() (string, error) {
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			return "", fmt.Errorf("truncated OpenEXR header")
+		}
+		s := string(data[pos : pos+end])
+		pos += end + 1
+		return s, nil
+	}
+	for {
+		name, err := cstring()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			break
+		}
+		if _, err := cstring(); err != nil {
+			return nil, err
+		}
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("truncated OpenEXR header")
+		}
+		size := int(le.Uint32(data[pos:]))
+		pos += 4
+		if size < 0 || pos+size > len(data) {
+			return nil, fmt.Errorf("truncated OpenEXR attribute %s", name)
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		switch name {
+		case "channels":
+			for len(value) > 1 {
+				end := bytes.IndexByte(value, 0)
+				if end < 0 || len(value) < end+17 {
+					return nil, fmt.Errorf("invalid OpenEXR channel list")
+				}
+				c := exrChannelInfo{name: string(value[:end]), pixelType: int32(le.Uint32(value[end+1:]))}
+				if le.Uint32(value[end+9:]) != 1 || le.Uint32(value[end+13:]) != 1 {
+					return nil, fmt.Errorf("unsupported subsampled OpenEXR channel %s", c.name)
+				}
+				channels = append(channels, c)
+				value = value[end+17:]
+			}
+		case "compression":
+			if len(value) != 1 || value[0] != exrNoCompression {
+				return nil, fmt.Errorf("unsupported OpenEXR compression, only uncompressed images can be read")
+			}
+		case "dataWindow":
+			if len(value) != 16 {
+				return nil, fmt.Errorf("invalid OpenEXR data window")
+			}
+			binary.Read(bytes.NewReader(value), le, &window)
+			hasWindow = true
+		}
+	}
+	width, height := int(window[2]-window[0])+1, int(window[3]-window[1])+1
+	if !hasWindow || width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid OpenEXR data window")
+	}
+	if err := checkDecodeSize(width, height); err != nil {
+		return nil, err
+	}
+
+	// which component of a pixel each channel is stored in, -1 to skip it
+	lineSize := 0
+	targets := make([]int, len(channels))
+	for i, c := range channels {
+		targets[i] = -1
+		if target, ok := exrComponents[c.name]; ok {
+			targets[i] = target
+		}
+		lineSize += width * exrSampleSize(c.pixelType)
+	}
+
+	fb := framebuffer.New(width, height)
+	offsets := data[pos:]
+	if len(offsets) < 8*height {
+		return nil, fmt.Errorf("truncated OpenEXR offset table")
+	}
+	pixel := make([][5]float64, width)
+	for line := range height {
+		offset := le.Uint64(offsets[8*line:])
+		if offset+8 > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid OpenEXR chunk offset %d", offset)
+		}
+		chunk := data[offset:]
+		y := int(int32(le.Uint32(chunk))) - int(window[1])
+		if y < 0 || y >= height || int(le.Uint32(chunk[4:])) != lineSize || len(chunk) < 8+lineSize {
+			return nil, fmt.Errorf("invalid OpenEXR scanline %d", line)
+		}
+		chunk = chunk[8:]
+		for x := range pixel {
+			pixel[x] = [5]float64{0, 0, 0, -1, 1}
+		}
+		for i, c := range channels {
+			size := exrSampleSize(c.pixelType)
+			for x := range width {
+				if targets[i] >= 0 {
+					pixel[x][targets[i]] = exrSample(chunk[x*size:], c.pixelType)
+				}
+			}
+			chunk = chunk[width*size:]
+		}
+		for x, p := range pixel {
+			if p[4] == 0 {
+				continue
+			}
+			if p[3] >= 0 {
+				p[0], p[1], p[2] = p[3], p[3], p[3]
+			}
+			fb.Set(x, y, vec.New(p[0], p[1], p[2]), 1)
+		}
+	}
+	return fb, nil
+}
+
+// Returns the size in bytes of one sample of an OpenEXR pixel type.
+func exrSampleSize(pixelType int32) int {
+	if pixelType == exrPixelHalf {
+		return 2
+	}
+	return exrPixelBytes
+}
+
+// Reads one sample of an OpenEXR pixel type.
+func exrSample(b []byte, pixelType int32) float64 {
+	le := binary.LittleEndian
+	switch pixelType {
+	case exrPixelUint:
+		return float64(le.Uint32(b))
+	case exrPixelHalf:
+		return halfToFloat(le.Uint16(b))
+	}
+	return float64(math.Float32frombits(le.Uint32(b)))
+}
+
+// Converts an IEEE 754 half precision float to a float64.
+func halfToFloat(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exponent := int(h>>10) & 0x1f
+	mantissa := float64(h & 0x3ff)
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if mantissa != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(1024+mantissa, exponent-25)
+}
@@ -0,0 +1,121 @@
+package encoder_test
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Compares two framebuffers pixel by pixel, allowing each component to differ by tolerance relative to the pixel's
+// brightest component, as the shared exponent of RGBE pixels limits the precision of their darker components.
+func compareImages(t *testing.T, name string, exp, act *framebuffer.Framebuffer, tolerance float64) {
+	t.Helper()
+	if exp.Width != act.Width || exp.Height != act.Height {
+		t.Fatalf("%s: expected a %dx%d image, but got %dx%d", name, exp.Width, exp.Height, act.Width, act.Height)
+	}
+	for y := range exp.Height {
+		for x := range exp.Width {
+			e, a := exp.Color(x, y), act.Color(x, y)
+			scale := max(1, e.X(), e.Y(), e.Z())
+			for i := range 3 {
+				if math.Abs(e.Get(i)-a.Get(i)) > tolerance*scale {
+					t.Errorf("%s: expected %v, but got %v at (%d, %d)", name, e, a, x, y)
+				}
+			}
+			if (exp.Samples(x, y) == 0) != (act.Samples(x, y) == 0) {
+				t.Errorf("%s: expected %d samples, but got %d at (%d, %d)", name, exp.Samples(x, y), act.Samples(x, y), x, y)
+			}
+		}
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	ldr := newImage(2, 2, vec.New(0, .2, .5), vec.New(.9, .01, 1), vec.New(.25, .25, .25), vec.New(1, 1, 0))
+	hdr := newImage(2, 2, vec.New(0, .2, .5), vec.New(9, .01, 1), vec.New(.25, 120, .25), vec.New(1, 1, 0))
+	// the second pixel has no samples
+	partial := newImage(2, 1, vec.New(.5, .2, .1))
+	cases := []struct {
+		format    string
+		image     *framebuffer.Framebuffer
+		tolerance float64
+	}{
+		{"ppm", ldr, .01},
+		{"png", ldr, .01},
+		{"png16", ldr, .0001},
+		{"png", partial, .01},
+		{"exr", hdr, 1e-6},
+		{"exr", partial, 1e-6},
+		{"hdr", hdr, .01},
+	}
+	for _, c := range cases {
+		enc, err := encoder.ByName(c.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := &bytes.Buffer{}
+		if err := enc.Encode(b, c.image); err != nil {
+			t.Fatalf("%s: %v", c.format, err)
+		}
+		act, err := encoder.Decode(b, c.format)
+		if err != nil {
+			t.Fatalf("%s: expected the image to decode, but got %v", c.format, err)
+		}
+		compareImages(t, c.format, c.image, act, c.tolerance)
+	}
+}
+
+func TestDecodeBinaryPPM(t *testing.T) {
+	data := "P6\n# a comment\n2 1\n255\n\xff\x00\x00\x00\x00\xff"
+	act, err := encoder.Decode(strings.NewReader(data), "ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareImages(t, "P6", newImage(2, 1, vec.New(1, 0, 0), vec.New(0, 0, 1)), act, 0)
+}
+
+func TestDecodeRunLengthHDR(t *testing.T) {
+	// 8 pixels of (128, 64, 0, 129) stored as one run per component
+	data := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 8\n\x02\x02\x00\x08" +
+		"\x88\x80" + "\x88\x40" + "\x88\x00" + "\x88\x81"
+	act, err := encoder.Decode(strings.NewReader(data), "hdr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := framebuffer.New(8, 1)
+	for x := range 8 {
+		exp.Set(x, 0, vec.New(128.5/128, 64.5/128, .5/128), 1)
+	}
+	compareImages(t, "RLE", exp, act, 1e-12)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	cases := map[string]string{
+		"ppm": "P5\n1 1\n255\n\x00",
+		"exr": "not an exr file",
+		"hdr": "#?RADIANCE\n\n+Y 1 +X 1\n\x00\x00\x00\x00",
+	}
+	for format, data := range cases {
+		if _, err := encoder.Decode(strings.NewReader(data), format); err == nil {
+			t.Errorf("Expected an error decoding %s", format)
+		}
+	}
+	// the size in the header is checked before a framebuffer is allocated for it
+	oversized := map[string]string{
+		"P6\n100000 100000\n255\n":            "ppm",
+		"P3\n9223372036854775807 2\n255\n":    "ppm",
+		"#?RADIANCE\n\n-Y 100000 +X 100000\n": "hdr",
+	}
+	for data, format := range oversized {
+		if _, err := encoder.Decode(strings.NewReader(data), format); err == nil || !strings.Contains(err.Error(), "larger than") {
+			t.Errorf("Expected a size error decoding %q, but got %v", data, err)
+		}
+	}
+	if _, err := encoder.Decode(strings.NewReader(""), "bmp"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,125 @@
+package encoder
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/interval"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Encoder writes the linear radiance stored in a framebuffer to an output stream in a specific file format.
+type Encoder interface {
+	Encode(out io.Writer, fb *framebuffer.Framebuffer) error
+}
+
+// Maps a format name to the encoder that produces it.
+var formats = map[string]Encoder{
+	"ppm":   PPM{},
+	"png":   PNG{},
+	"png16": PNG{Depth16: true},
+	"jpeg":  JPEG{Quality: 95},
+	"exr":   EXR{},
+	"hdr":   HDR{},
+}
+
+// Maps a file extension to the name of the format it is written in by default.
+var extensions = map[string]string{
+	".ppm":  "ppm",
+	".png":  "png",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".exr":  "exr",
+	".hdr":  "hdr",
+}
+
+// ByName returns the encoder registered under the given format name.
+func ByName(name string) (Encoder, error) {
+	enc, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q (supported: %s)", name, strings.Join(Formats(), ", "))
+	}
+	return enc, nil
+}
+
+// ForFile returns the encoder matching the extension of the given filename.
+func ForFile(filename string) (Encoder, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	name, ok := extensions[ext]
+	if !ok {
+		return nil, fmt.Errorf("cannot infer an output format from extension %q", ext)
+	}
+	return formats[name], nil
+}
+
+// WithTonemap returns a copy of a low dynamic range encoder which tone maps with m.
+// Floating point formats store radiance as is and are returned unchanged.
+func WithTonemap(enc Encoder, m tonemap.Mapper) Encoder {
+	switch e := enc.(type) {
+	case PPM:
+		e.Tonemap = m
+		return e
+	case PNG:
+		e.Tonemap = m
+		return e
+	case JPEG:
+		e.Tonemap = m
+		return e
+	}
+	return enc
+}
+
+// Formats returns the sorted list of supported format names.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var intensity = interval.New(0, 0.99999)
+
+// Replaces NaN components, which can be produced by degenerate samples, with 0.
+func sanitize(x float64) float64 {
+	if math.IsNaN(x) {
+		return 0
+	}
+	return x
+}
+
+// Tone maps a linear color to sRGB encoded components clamped to [0, 1) for low dynamic range formats.
+func displayColor(m tonemap.Mapper, c *vec.Vec3) [3]float64 {
+	d := m.Display(c)
+	return [3]float64{intensity.Clamp(d[0]), intensity.Clamp(d[1]), intensity.Clamp(d[2])}
+}
+
+// Quantizes a linear color to 8 bits per channel.
+func toRGB8(m tonemap.Mapper, c *vec.Vec3) [3]uint8 {
+	d := displayColor(m, c)
+	return [3]uint8{uint8(d[0] * 256), uint8(d[1] * 256), uint8(d[2] * 256)}
+}
+
+// Quantizes a linear color to 16 bits per channel.
+func toRGB16(m tonemap.Mapper, c *vec.Vec3) [3]uint16 {
+	d := displayColor(m, c)
+	return [3]uint16{uint16(d[0] * 65536), uint16(d[1] * 65536), uint16(d[2] * 65536)}
+}
+
+// Checks that the framebuffer holds an image that can be encoded.
+func checkSize(fb *framebuffer.Framebuffer) error {
+	if fb == nil {
+		return fmt.Errorf("no framebuffer to encode")
+	}
+	if fb.Width <= 0 || fb.Height <= 0 {
+		return fmt.Errorf("invalid image size %dx%d", fb.Width, fb.Height)
+	}
+	return nil
+}
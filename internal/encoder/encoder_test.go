@@ -0,0 +1,204 @@
+package encoder_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Builds a framebuffer holding the given colors in row-major order.
+func newImage(width, height int, colors ...*vec.Vec3) *framebuffer.Framebuffer {
+	fb := framebuffer.New(width, height)
+	for i, c := range colors {
+		fb.Set(i%width, i/width, c, 1)
+	}
+	return fb
+}
+
+func TestWriteColor(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := encoder.PPM{}.Encode(b, newImage(1, 1, vec.New(0, 128, 255)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := fmt.Sprintf("P3\n1 1\n255\n%d %d %d\n", 0, 255, 255)
+	if act := b.String(); act != exp {
+		t.Errorf("Expected %s, but got %s", exp, act)
+	}
+}
+
+func TestWriteColorNaN(t *testing.T) {
+	b := &bytes.Buffer{}
+	encoder.PPM{}.Encode(b, newImage(1, 1, vec.New(math.NaN(), 0.25, -1)))
+	// sRGB encodes 0.25 as 0.537
+	exp := "P3\n1 1\n255\n0 137 0\n"
+	if act := b.String(); act != exp {
+		t.Errorf("Expected %s, but got %s", exp, act)
+	}
+}
+
+func TestEmptyImage(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := (encoder.PNG{}).Encode(b, framebuffer.New(0, 0)); err == nil {
+		t.Error("Expected an error for an empty image")
+	}
+	if err := (encoder.PNG{}).Encode(b, nil); err == nil {
+		t.Error("Expected an error for a missing framebuffer")
+	}
+}
+
+func TestForFile(t *testing.T) {
+	cases := map[string]encoder.Encoder{
+		"image.ppm":  encoder.PPM{},
+		"image.PNG":  encoder.PNG{},
+		"image.jpg":  encoder.JPEG{Quality: 95},
+		"image.jpeg": encoder.JPEG{Quality: 95},
+		"image.exr":  encoder.EXR{},
+		"image.hdr":  encoder.HDR{},
+	}
+	for name, exp := range cases {
+		act, err := encoder.ForFile(name)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", name, err)
+		}
+		if act != exp {
+			t.Errorf("Expected %T, but got %T for %s", exp, act, name)
+		}
+	}
+	if _, err := encoder.ForFile("image.bmp"); err == nil {
+		t.Error("Expected an error for an unsupported extension")
+	}
+}
+
+func TestPNG16(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := encoder.PNG{Depth16: true}.Encode(b, newImage(2, 1, vec.New(0.25, 0.25, 0.25), vec.New(1, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, _, _ := img.At(0, 0).RGBA()
+	if r != 35199 {
+		t.Errorf("Expected %d, but got %d", 35199, r)
+	}
+	r, _, _, _ = img.At(1, 0).RGBA()
+	if r != 65535 {
+		t.Errorf("Expected %d, but got %d", 65535, r)
+	}
+}
+
+func TestEXRLayout(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := encoder.EXR{}.Encode(b, newImage(2, 2, vec.New(1, 2, 3), vec.New(4, 5, 6), vec.New(7, 8, 9), vec.New(10, 11, 12)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if magic := binary.LittleEndian.Uint32(data); magic != 20000630 {
+		t.Fatalf("Expected magic %d, but got %d", 20000630, magic)
+	}
+
+	// The header is followed by one offset per scanline, pointing at the (y, size, data) chunk.
+	headerEnd := bytes.Index(data, []byte("screenWindowWidth\x00float\x00")) + len("screenWindowWidth\x00float\x00") + 4 + 4 + 1
+	offset := binary.LittleEndian.Uint64(data[headerEnd+8:])
+	chunk := data[offset:]
+	if y := binary.LittleEndian.Uint32(chunk); y != 1 {
+		t.Errorf("Expected scanline %d, but got %d", 1, y)
+	}
+	// channels are stored alphabetically, so the first value of row 1 is B of pixel (0, 1)
+	blue := math.Float32frombits(binary.LittleEndian.Uint32(chunk[8:]))
+	if blue != 9 {
+		t.Errorf("Expected %f, but got %f", 9.0, blue)
+	}
+}
+
+func TestHDRHeader(t *testing.T) {
+	b := &bytes.Buffer{}
+	encoder.HDR{}.Encode(b, newImage(1, 1, vec.New(1, 0.5, 0)))
+	exp := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 1\n\x80\x40\x00\x81"
+	if act := b.String(); act != exp {
+		t.Errorf("Expected %q, but got %q", exp, act)
+	}
+}
+
+func TestUnsampledPixelsAreTransparent(t *testing.T) {
+	fb := framebuffer.New(2, 1)
+	fb.Set(1, 0, vec.New(1, 1, 1), 1)
+	b := &bytes.Buffer{}
+	if err := (encoder.PNG{}).Encode(b, fb); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("Expected %d, but got %d", 0, a)
+	}
+	if _, _, _, a := img.At(1, 0).RGBA(); a != 0xffff {
+		t.Errorf("Expected %d, but got %d", 0xffff, a)
+	}
+
+	b.Reset()
+	if err := (encoder.EXR{}).Encode(b, fb); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("A\x00\x02\x00\x00\x00")) {
+		t.Error("Expected an alpha channel in the EXR channel list")
+	}
+}
+
+func TestLayeredEXR(t *testing.T) {
+	fb := newImage(1, 1, vec.New(1, 2, 3))
+	depth := newImage(1, 1, vec.New(4, 4, 4))
+	b := &bytes.Buffer{}
+	err := encoder.LayeredEXR{Layers: []encoder.Layer{{Name: "depth", Channels: []string{"Z"}, Image: depth}}}.Encode(b, fb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("depth.Z\x00")) {
+		t.Error("Expected a depth.Z channel in the EXR channel list")
+	}
+	// channels are stored alphabetically: B, G, R, depth.Z
+	pixels := b.Bytes()[b.Len()-16:]
+	if v := math.Float32frombits(binary.LittleEndian.Uint32(pixels[12:])); v != 4 {
+		t.Errorf("Expected %v, but got %v", 4, v)
+	}
+
+	err = encoder.LayeredEXR{Layers: []encoder.Layer{{Name: "depth", Channels: []string{"Z"}, Image: newImage(2, 1)}}}.Encode(b, fb)
+	if err == nil {
+		t.Error("Expected an error for a layer of a different size")
+	}
+	err = encoder.LayeredEXR{Layers: []encoder.Layer{{Name: "depth", Channels: []string{"Z"}}}}.Encode(b, fb)
+	if err == nil || !strings.Contains(err.Error(), "depth") {
+		t.Errorf("Expected an error naming the layer without an image, but got %v", err)
+	}
+}
+
+func TestWithTonemap(t *testing.T) {
+	enc := encoder.WithTonemap(encoder.PPM{}, tonemap.Mapper{Exposure: -2})
+	b := &bytes.Buffer{}
+	if err := enc.Encode(b, newImage(1, 1, vec.New(1, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// two stops under 1 is 0.25, which sRGB encodes as 0.537
+	exp := "P3\n1 1\n255\n137 137 137\n"
+	if act := b.String(); act != exp {
+		t.Errorf("Expected %s, but got %s", exp, act)
+	}
+	if enc := encoder.WithTonemap(encoder.EXR{}, tonemap.Mapper{Exposure: -2}); enc != (encoder.EXR{}) {
+		t.Errorf("Expected %v, but got %v", encoder.EXR{}, enc)
+	}
+}
@@ -0,0 +1,166 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+)
+
+// EXR writes uncompressed, single part, scanline OpenEXR images with 32-bit float channels.
+// No tone mapping or clamping is applied, so the full dynamic range of the render is preserved.
+// If some pixels have no samples, an alpha channel marks them as transparent.
+type EXR struct{}
+
+// A single named channel of float data stored in row-major order.
+type exrChannel struct {
+	name string
+	data []float32
+}
+
+func (EXR) Encode(out io.Writer, fb *framebuffer.Framebuffer) error {
+	return LayeredEXR{}.Encode(out, fb)
+}
+
+// A named group of channels stored in the X, Y and Z components of a framebuffer, e.g. "normal" with X, Y and Z.
+type Layer struct {
+	Name     string
+	Channels []string
+	Image    *framebuffer.Framebuffer
+}
+
+// LayeredEXR writes an OpenEXR image like EXR, with additional layers whose channels are named "<layer>.<channel>".
+type LayeredEXR struct {
+	Layers []Layer
+}
+
+func (e LayeredEXR) Encode(out io.Writer, fb *framebuffer.Framebuffer) error {
+	if err := checkSize(fb); err != nil {
+		return err
+	}
+	n := fb.Width * fb.Height
+	r := make([]float32, n)
+	g := make([]float32, n)
+	b := make([]float32, n)
+	a := make([]float32, n)
+	for y := range fb.Height {
+		for x := range fb.Width {
+			i := y*fb.Width + x
+			p := fb.Color(x, y)
+			r[i] = float32(sanitize(p.X()))
+			g[i] = float32(sanitize(p.Y()))
+			b[i] = float32(sanitize(p.Z()))
+			if fb.Samples(x, y) > 0 {
+				a[i] = 1
+			}
+		}
+	}
+	channels := []exrChannel{{"R", r}, {"G", g}, {"B", b}}
+	if !fb.Covered() {
+		channels = append(channels, exrChannel{"A", a})
+	}
+	for _, layer := range e.Layers {
+		if layer.Image == nil {
+			return fmt.Errorf("layer %s has no image", layer.Name)
+		}
+		if layer.Image.Width != fb.Width || layer.Image.Height != fb.Height {
+			return fmt.Errorf("layer %s is %dx%d, but the image is %dx%d", layer.Name, layer.Image.Width, layer.Image.Height, fb.Width, fb.Height)
+		}
+		if len(layer.Channels) > 3 {
+			return fmt.Errorf("layer %s has %d channels, at most 3 are supported", layer.Name, len(layer.Channels))
+		}
+		for c, name := range layer.Channels {
+			data := make([]float32, n)
+			for y := range fb.Height {
+				for x := range fb.Width {
+					data[y*fb.Width+x] = float32(sanitize(layer.Image.Color(x, y).Get(c)))
+				}
+			}
+			channels = append(channels, exrChannel{layer.Name + "." + name, data})
+		}
+	}
+	return writeEXR(out, fb.Width, fb.Height, channels)
+}
+
+// OpenEXR constants used by the writer.
+const (
+	exrMagic         = 20000630
+	exrVersion       = 2
+	exrPixelFloat    = 2
+	exrNoCompression = 0
+	exrIncreasingY   = 0
+)
+
+// Builds the OpenEXR header: magic number, version and the required attributes.
+func exrHeader(width, height int, channels []exrChannel) []byte {
+	h := &bytes.Buffer{}
+	le := binary.LittleEndian
+	attribute := func(name, kind string, value any) {
+		h.WriteString(name)
+		h.WriteByte(0)
+		h.WriteString(kind)
+		h.WriteByte(0)
+		binary.Write(h, le, int32(binary.Size(value)))
+		binary.Write(h, le, value)
+	}
+	window := [4]int32{0, 0, int32(width - 1), int32(height - 1)}
+
+	binary.Write(h, le, int32(exrMagic))
+	binary.Write(h, le, int32(exrVersion))
+
+	// channel list: name, pixel type, pLinear + 3 reserved bytes, x and y sampling
+	chlist := &bytes.Buffer{}
+	for _, c := range channels {
+		chlist.WriteString(c.name)
+		chlist.WriteByte(0)
+		binary.Write(chlist, le, [4]int32{exrPixelFloat, 0, 1, 1})
+	}
+	chlist.WriteByte(0)
+	attribute("channels", "chlist", chlist.Bytes())
+
+	attribute("compression", "compression", uint8(exrNoCompression))
+	attribute("dataWindow", "box2i", window)
+	attribute("displayWindow", "box2i", window)
+	attribute("lineOrder", "lineOrder", uint8(exrIncreasingY))
+	attribute("pixelAspectRatio", "float", float32(1))
+	attribute("screenWindowCenter", "v2f", [2]float32{0, 0})
+	attribute("screenWindowWidth", "float", float32(1))
+	h.WriteByte(0)
+	return h.Bytes()
+}
+
+// Writes the given channels as an OpenEXR file.
+func writeEXR(out io.Writer, width, height int, channels []exrChannel) error {
+	// channels must be stored in alphabetical order
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].name < channels[j].name
+	})
+	header := exrHeader(width, height, channels)
+
+	w := bufio.NewWriter(out)
+	le := binary.LittleEndian
+	w.Write(header)
+
+	// Each scanline is its own chunk: y coordinate, data size, then each channel's row of pixels.
+	lineSize := len(channels) * width * 4
+	chunkStart := uint64(len(header) + 8*height)
+	for y := range height {
+		binary.Write(w, le, chunkStart+uint64(y*(8+lineSize)))
+	}
+	row := make([]byte, 4)
+	for y := range height {
+		binary.Write(w, le, [2]int32{int32(y), int32(lineSize)})
+		for _, c := range channels {
+			for x := range width {
+				le.PutUint32(row, math.Float32bits(c.data[y*width+x]))
+				w.Write(row)
+			}
+		}
+	}
+	return w.Flush()
+}
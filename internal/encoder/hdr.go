@@ -0,0 +1,44 @@
+package encoder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// HDR writes Radiance RGBE (.hdr) images without run length encoding.
+type HDR struct{}
+
+func (HDR) Encode(out io.Writer, fb *framebuffer.Framebuffer) error {
+	if err := checkSize(fb); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", fb.Height, fb.Width)
+	for y := range fb.Height {
+		for x := range fb.Width {
+			rgbe := toRGBE(fb.Color(x, y))
+			w.Write(rgbe[:])
+		}
+	}
+	return w.Flush()
+}
+
+// Converts a linear color to a shared exponent RGBE pixel. Negative components are clamped to 0.
+func toRGBE(c *vec.Vec3) [4]byte {
+	r := max(sanitize(c.X()), 0)
+	g := max(sanitize(c.Y()), 0)
+	b := max(sanitize(c.Z()), 0)
+
+	v := max(r, g, b)
+	if v < 1e-32 {
+		return [4]byte{}
+	}
+	mantissa, exponent := math.Frexp(v)
+	scale := mantissa * 256 / v
+	return [4]byte{byte(r * scale), byte(g * scale), byte(b * scale), byte(exponent + 128)}
+}
@@ -0,0 +1,71 @@
+package encoder
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+)
+
+// PNG writes lossless PNG images with either 8 or 16 bits per channel.
+type PNG struct {
+	Depth16 bool
+	Tonemap tonemap.Mapper
+}
+
+func (p PNG) Encode(out io.Writer, fb *framebuffer.Framebuffer) error {
+	if err := checkSize(fb); err != nil {
+		return err
+	}
+	if p.Depth16 {
+		return png.Encode(out, toRGBA64(fb, p.Tonemap))
+	}
+	return png.Encode(out, ToRGBA(fb, p.Tonemap))
+}
+
+// JPEG writes lossy JPEG images at the given quality (1-100).
+type JPEG struct {
+	Quality int
+	Tonemap tonemap.Mapper
+}
+
+func (j JPEG) Encode(out io.Writer, fb *framebuffer.Framebuffer) error {
+	if err := checkSize(fb); err != nil {
+		return err
+	}
+	return jpeg.Encode(out, ToRGBA(fb, j.Tonemap), &jpeg.Options{Quality: j.Quality})
+}
+
+// ToRGBA builds an 8-bit sRGB image from a framebuffer. Pixels without samples are left transparent.
+func ToRGBA(fb *framebuffer.Framebuffer, m tonemap.Mapper) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, fb.Width, fb.Height))
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if fb.Samples(x, y) == 0 {
+				continue
+			}
+			c := toRGB8(m, fb.Color(x, y))
+			img.SetRGBA(x, y, color.RGBA{R: c[0], G: c[1], B: c[2], A: 255})
+		}
+	}
+	return img
+}
+
+// Builds a 16-bit image from a framebuffer. Pixels without samples are left transparent.
+func toRGBA64(fb *framebuffer.Framebuffer, m tonemap.Mapper) *image.RGBA64 {
+	img := image.NewRGBA64(image.Rect(0, 0, fb.Width, fb.Height))
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if fb.Samples(x, y) == 0 {
+				continue
+			}
+			c := toRGB16(m, fb.Color(x, y))
+			img.SetRGBA64(x, y, color.RGBA64{R: c[0], G: c[1], B: c[2], A: 0xffff})
+		}
+	}
+	return img
+}
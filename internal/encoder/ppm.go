@@ -0,0 +1,30 @@
+package encoder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+)
+
+// PPM writes ASCII (P3) portable pixmaps, the raytracer's original output format.
+type PPM struct {
+	Tonemap tonemap.Mapper
+}
+
+func (p PPM) Encode(out io.Writer, fb *framebuffer.Framebuffer) error {
+	if err := checkSize(fb); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "P3\n%d %d\n255\n", fb.Width, fb.Height)
+	for y := range fb.Height {
+		for x := range fb.Width {
+			c := toRGB8(p.Tonemap, fb.Color(x, y))
+			fmt.Fprintf(w, "%d %d %d\n", c[0], c[1], c[2])
+		}
+	}
+	return w.Flush()
+}
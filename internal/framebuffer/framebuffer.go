@@ -0,0 +1,209 @@
+package framebuffer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Framebuffer accumulates linear RGB radiance for every pixel of an image along with the number of samples taken.
+// Pixels are stored in row-major order with (0, 0) in the top left corner.
+type Framebuffer struct {
+	Width  int
+	Height int
+
+	sum     []float64 // running sum of samples, 3 components per pixel
+	samples []int     // number of samples accumulated per pixel
+}
+
+// Creates a new, empty framebuffer with the given dimensions.
+func New(width, height int) *Framebuffer {
+	fb := &Framebuffer{}
+	fb.Reset(width, height)
+	return fb
+}
+
+// Reset clears the framebuffer and resizes it to the given dimensions, reusing its storage when possible.
+func (fb *Framebuffer) Reset(width, height int) {
+	fb.Width = width
+	fb.Height = height
+	n := width * height
+	if cap(fb.samples) >= n && cap(fb.sum) >= 3*n {
+		fb.sum = fb.sum[:3*n]
+		fb.samples = fb.samples[:n]
+		clear(fb.sum)
+		clear(fb.samples)
+		return
+	}
+	fb.sum = make([]float64, 3*n)
+	fb.samples = make([]int, n)
+}
+
+// Returns the index of the pixel at (x, y).
+func (fb *Framebuffer) index(x, y int) int {
+	return y*fb.Width + x
+}
+
+// AddSamples accumulates the sum of n radiance samples into the pixel at (x, y).
+// Distinct pixels may be written concurrently, but a single pixel must only be written by one goroutine at a time.
+func (fb *Framebuffer) AddSamples(x, y int, sum *vec.Vec3, n int) {
+	idx := fb.index(x, y)
+	fb.sum[3*idx] += sum.X()
+	fb.sum[3*idx+1] += sum.Y()
+	fb.sum[3*idx+2] += sum.Z()
+	fb.samples[idx] += n
+}
+
+// Set replaces the pixel at (x, y) with the given color, treating it as the average of n samples.
+func (fb *Framebuffer) Set(x, y int, color *vec.Vec3, n int) {
+	idx := fb.index(x, y)
+	n = max(n, 1)
+	fb.sum[3*idx] = color.X() * float64(n)
+	fb.sum[3*idx+1] = color.Y() * float64(n)
+	fb.sum[3*idx+2] = color.Z() * float64(n)
+	fb.samples[idx] = n
+}
+
+// Color returns the average radiance of the pixel at (x, y), or black if it has no samples.
+func (fb *Framebuffer) Color(x, y int) *vec.Vec3 {
+	idx := fb.index(x, y)
+	n := fb.samples[idx]
+	if n == 0 {
+		return vec.Empty()
+	}
+	scale := 1.0 / float64(n)
+	return vec.New(fb.sum[3*idx]*scale, fb.sum[3*idx+1]*scale, fb.sum[3*idx+2]*scale)
+}
+
+// Samples returns the number of samples accumulated into the pixel at (x, y).
+func (fb *Framebuffer) Samples(x, y int) int {
+	return fb.samples[fb.index(x, y)]
+}
+
+// Add accumulates the samples of another framebuffer of the same size into this one.
+func (fb *Framebuffer) Add(other *Framebuffer) {
+	for i := range fb.sum {
+		fb.sum[i] += other.sum[i]
+	}
+	for i := range fb.samples {
+		fb.samples[i] += other.samples[i]
+	}
+}
+
+// AddAt accumulates the samples of a smaller framebuffer, such as one returned by Crop, into this one
+// with its top left corner at the given point. Pixels falling outside of this framebuffer are dropped.
+func (fb *Framebuffer) AddAt(other *Framebuffer, at image.Point) {
+	region := other.Bounds().Add(at).Intersect(fb.Bounds())
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			dst, src := fb.index(x, y), other.index(x-at.X, y-at.Y)
+			fb.sum[3*dst] += other.sum[3*src]
+			fb.sum[3*dst+1] += other.sum[3*src+1]
+			fb.sum[3*dst+2] += other.sum[3*src+2]
+			fb.samples[dst] += other.samples[src]
+		}
+	}
+}
+
+// Clone returns a deep copy of the framebuffer.
+func (fb *Framebuffer) Clone() *Framebuffer {
+	return &Framebuffer{
+		Width:   fb.Width,
+		Height:  fb.Height,
+		sum:     append([]float64(nil), fb.sum...),
+		samples: append([]int(nil), fb.samples...),
+	}
+}
+
+// Bounds returns the rectangle covered by the framebuffer.
+func (fb *Framebuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, fb.Width, fb.Height)
+}
+
+// MinSamples returns the smallest number of samples accumulated into any pixel.
+func (fb *Framebuffer) MinSamples() int {
+	return fb.MinSamplesIn(fb.Bounds())
+}
+
+// MinSamplesIn returns the smallest number of samples accumulated into any pixel within the region.
+func (fb *Framebuffer) MinSamplesIn(region image.Rectangle) int {
+	region = region.Intersect(fb.Bounds())
+	if region.Empty() {
+		return 0
+	}
+	least := math.MaxInt
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		least = min(least, slices.Min(fb.samples[fb.index(region.Min.X, y):fb.index(region.Max.X, y)]))
+	}
+	return least
+}
+
+// MarshalBinary encodes the framebuffer's dimensions, accumulated radiance and sample counts.
+func (fb *Framebuffer) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	header := [2]int64{int64(fb.Width), int64(fb.Height)}
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, fb.sum); err != nil {
+		return nil, err
+	}
+	samples := make([]int64, len(fb.samples))
+	for i, n := range fb.samples {
+		samples[i] = int64(n)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, samples); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a framebuffer written by MarshalBinary.
+func (fb *Framebuffer) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewReader(data)
+	header := [2]int64{}
+	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	// the height is bounded by the data before multiplying, so a corrupt header can't overflow the product
+	width, height := header[0], header[1]
+	pixels := int64(buf.Len()) / (4 * 8)
+	if width < 0 || height < 0 || width > 0 && height > pixels/width || width*height*4*8 != int64(buf.Len()) {
+		return fmt.Errorf("framebuffer data does not match its %dx%d size", width, height)
+	}
+	fb.Reset(int(width), int(height))
+	if err := binary.Read(buf, binary.LittleEndian, fb.sum); err != nil {
+		return err
+	}
+	samples := make([]int64, len(fb.samples))
+	if err := binary.Read(buf, binary.LittleEndian, samples); err != nil {
+		return err
+	}
+	for i, n := range samples {
+		fb.samples[i] = int(n)
+	}
+	return nil
+}
+
+// Covered reports whether every pixel has at least one sample.
+func (fb *Framebuffer) Covered() bool {
+	return !slices.Contains(fb.samples, 0)
+}
+
+// Crop returns a new framebuffer holding a copy of the given region, with the region's top left corner at (0, 0).
+func (fb *Framebuffer) Crop(region image.Rectangle) *Framebuffer {
+	region = region.Intersect(fb.Bounds())
+	cropped := New(region.Dx(), region.Dy())
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		src := fb.index(region.Min.X, y)
+		dst := cropped.index(0, y-region.Min.Y)
+		copy(cropped.sum[3*dst:3*(dst+region.Dx())], fb.sum[3*src:3*(src+region.Dx())])
+		copy(cropped.samples[dst:dst+region.Dx()], fb.samples[src:src+region.Dx()])
+	}
+	return cropped
+}
@@ -0,0 +1,137 @@
+package framebuffer_test
+
+import (
+	"encoding/binary"
+	"image"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func checkVec(t *testing.T, v *vec.Vec3, expected *vec.Vec3) {
+	if !v.Equals(expected) {
+		t.Errorf("Expected %v, got %v", expected, v)
+	}
+}
+
+func TestAddSamplesAverages(t *testing.T) {
+	fb := framebuffer.New(2, 2)
+	fb.AddSamples(1, 0, vec.New(2, 4, 6), 2)
+	fb.AddSamples(1, 0, vec.New(1, 2, 3), 1)
+	checkVec(t, fb.Color(1, 0), vec.New(1, 2, 3))
+	if fb.Samples(1, 0) != 3 {
+		t.Errorf("Expected %d, got %d", 3, fb.Samples(1, 0))
+	}
+}
+
+func TestEmptyPixelIsBlack(t *testing.T) {
+	fb := framebuffer.New(2, 2)
+	checkVec(t, fb.Color(0, 1), vec.Empty())
+	if fb.Samples(0, 1) != 0 {
+		t.Errorf("Expected %d, got %d", 0, fb.Samples(0, 1))
+	}
+}
+
+func TestSet(t *testing.T) {
+	fb := framebuffer.New(1, 1)
+	fb.AddSamples(0, 0, vec.New(9, 9, 9), 1)
+	fb.Set(0, 0, vec.New(0.5, 0.25, 1), 4)
+	checkVec(t, fb.Color(0, 0), vec.New(0.5, 0.25, 1))
+	if fb.Samples(0, 0) != 4 {
+		t.Errorf("Expected %d, got %d", 4, fb.Samples(0, 0))
+	}
+}
+
+func TestResetClears(t *testing.T) {
+	fb := framebuffer.New(3, 3)
+	fb.AddSamples(2, 2, vec.New(1, 1, 1), 1)
+	fb.Reset(2, 2)
+	if fb.Width != 2 || fb.Height != 2 {
+		t.Errorf("Expected 2x2, got %dx%d", fb.Width, fb.Height)
+	}
+	checkVec(t, fb.Color(1, 1), vec.Empty())
+}
+
+func TestResetClone(t *testing.T) {
+	// the sums and sample counts of a clone may have grown to different capacities
+	fb := framebuffer.New(5, 1).Clone()
+	fb.Reset(6, 1)
+	fb.AddSamples(5, 0, vec.New(1, 1, 1), 1)
+	checkVec(t, fb.Color(5, 0), vec.New(1, 1, 1))
+}
+
+func TestAddAndClone(t *testing.T) {
+	a := framebuffer.New(1, 1)
+	a.AddSamples(0, 0, vec.New(1, 1, 1), 1)
+	b := a.Clone()
+	b.AddSamples(0, 0, vec.New(3, 3, 3), 1)
+	a.Add(b)
+	checkVec(t, a.Color(0, 0), vec.New(5, 5, 5).Scale(1.0/3))
+	checkVec(t, b.Color(0, 0), vec.New(2, 2, 2))
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	fb := framebuffer.New(2, 1)
+	fb.AddSamples(0, 0, vec.New(1, 2, 3), 2)
+	fb.AddSamples(1, 0, vec.New(4, 5, 6), 3)
+	data, err := fb.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	act := &framebuffer.Framebuffer{}
+	if err := act.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if act.Width != 2 || act.Height != 1 {
+		t.Errorf("Expected 2x1, got %dx%d", act.Width, act.Height)
+	}
+	checkVec(t, act.Color(0, 0), fb.Color(0, 0))
+	checkVec(t, act.Color(1, 0), fb.Color(1, 0))
+	if act.MinSamples() != 2 {
+		t.Errorf("Expected %d, got %d", 2, act.MinSamples())
+	}
+	if err := act.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("Expected an error for truncated data")
+	}
+	// 2^31 x 2^31 pixels of 32 bytes wrap around to no data at all
+	header := binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, 1<<31), 1<<31)
+	if err := act.UnmarshalBinary(header); err == nil {
+		t.Error("Expected an error for a size which overflows")
+	}
+}
+
+func TestCrop(t *testing.T) {
+	fb := framebuffer.New(4, 3)
+	fb.AddSamples(2, 1, vec.New(1, 2, 3), 1)
+	fb.AddSamples(3, 2, vec.New(4, 5, 6), 2)
+	cropped := fb.Crop(image.Rect(2, 1, 4, 3))
+	if cropped.Width != 2 || cropped.Height != 2 {
+		t.Errorf("Expected 2x2, got %dx%d", cropped.Width, cropped.Height)
+	}
+	checkVec(t, cropped.Color(0, 0), vec.New(1, 2, 3))
+	checkVec(t, cropped.Color(1, 1), vec.New(2, 2.5, 3))
+	if cropped.Covered() {
+		t.Error("Expected the cropped image to have unsampled pixels")
+	}
+	if fb.MinSamplesIn(image.Rect(2, 1, 3, 2)) != 1 {
+		t.Errorf("Expected %d, got %d", 1, fb.MinSamplesIn(image.Rect(2, 1, 3, 2)))
+	}
+}
+
+func TestAddAt(t *testing.T) {
+	fb := framebuffer.New(4, 3)
+	fb.AddSamples(2, 1, vec.New(1, 1, 1), 1)
+	tile := framebuffer.New(3, 2)
+	tile.AddSamples(0, 0, vec.New(3, 3, 3), 1)
+	tile.AddSamples(2, 1, vec.New(4, 5, 6), 2)
+	fb.AddAt(tile, image.Pt(2, 1))
+	checkVec(t, fb.Color(2, 1), vec.New(2, 2, 2))
+	if fb.Samples(2, 1) != 2 {
+		t.Errorf("Expected %d, got %d", 2, fb.Samples(2, 1))
+	}
+	// the tile's last column falls outside of the framebuffer
+	if fb.Samples(3, 2) != 0 || fb.MinSamplesIn(image.Rect(0, 0, 2, 3)) != 0 {
+		t.Error("Expected the samples outside of the tile to be unchanged")
+	}
+}
@@ -1,22 +1,34 @@
 package hittable
 
 import (
+	"math/rand"
 	"sort"
 
 	"github.com/nsp5488/go_raytracer/internal/aabb"
 	"github.com/nsp5488/go_raytracer/internal/interval"
 	"github.com/nsp5488/go_raytracer/internal/ray"
+	"github.com/nsp5488/go_raytracer/internal/vec"
 )
 
 // Bounded Volume Hierarchy
 type BVHNode struct {
-	defaultPdfImpl
 	left  Hittable
 	right Hittable
 
 	bbox *aabb.AABB
 }
 
+// Averages the densities of both halves, whose directions Random picks between evenly.
+func (node *BVHNode) PdfValue(origin, direction *vec.Vec3) float64 {
+	return 0.5*node.left.PdfValue(origin, direction) + 0.5*node.right.PdfValue(origin, direction)
+}
+func (node *BVHNode) Random(origin *vec.Vec3) *vec.Vec3 {
+	if rand.Float64() < 0.5 {
+		return node.left.Random(origin)
+	}
+	return node.right.Random(origin)
+}
+
 // Builds a BVH out of a list of hittable objects
 func BuildBVH(list *HittableList) *BVHNode {
 	return bvhHelper(list, 0, len(list.objects))
@@ -60,6 +72,26 @@ func bvhHelper(list *HittableList, start, end int) *BVHNode {
 	return &BVHNode{left: l, right: r, bbox: bbox}
 }
 
+// Returns the number of BVH nodes on the longest path from h down to a primitive, including the BVHs nested inside
+// lists, transforms and boxes. Hittables without a BVH have a depth of 0.
+func BVHDepth(h Hittable) int {
+	depth := 0
+	if c, ok := h.(composite); ok {
+		for _, child := range c.children() {
+			depth = max(depth, BVHDepth(child))
+		}
+	}
+	switch h.(type) {
+	case *BVHNode, *box:
+		depth++
+	}
+	return depth
+}
+
+func (bvh *BVHNode) children() []Hittable {
+	return []Hittable{bvh.left, bvh.right}
+}
+
 func (bvh *BVHNode) BBox() *aabb.AABB {
 	return bvh.bbox
 }
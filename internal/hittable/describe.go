@@ -0,0 +1,176 @@
+package hittable
+
+import (
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// The kind of object a Description describes.
+type Shape uint8
+
+const (
+	_ Shape = iota
+	SPHERE
+	QUAD
+	BOX
+	TRIANGLE
+	MEDIUM
+	LIST
+	BVH
+	TRANSLATE
+	ROTATE_Y
+)
+
+// Description holds the arguments an object was constructed with, so tools can write a world back out.
+// Only the fields used by its shape are set.
+type Description struct {
+	Shape    Shape
+	Material Material // sphere, quad, box, triangle and the phase function of a medium
+
+	Center  *vec.Vec3 // sphere
+	Center2 *vec.Vec3 // the second center of a moving sphere, nil if it does not move
+	Radius  float64
+
+	Q, U, V *vec.Vec3 // quad
+
+	Min, Max *vec.Vec3 // box
+
+	Vertices []*vec.Vec3  // triangle
+	Normals  []*vec.Vec3  // vertex normals of a triangle, nil if it uses its face normal
+	UVs      [][2]float64 // texture coordinates of a triangle, nil if it has none
+
+	Density float64   // medium
+	Offset  *vec.Vec3 // translate
+	Angle   float64   // rotate_y, in degrees
+
+	// the members of a list or BVH, the transformed object or the boundary of a medium
+	Children []Hittable
+}
+
+// Describe returns how an object was constructed, or false for hittables defined outside this package.
+// The leaves of a BVH are returned as its children, the inner nodes are left out.
+func Describe(h Hittable) (Description, bool) {
+	switch o := h.(type) {
+	case *sphere:
+		d := Description{Shape: SPHERE, Material: o.Material, Center: o.Center.At(0), Radius: o.Radius}
+		if !o.Center.Direction().NearZero() {
+			d.Center2 = o.Center.At(1)
+		}
+		return d, true
+	case *quad:
+		return Description{Shape: QUAD, Material: o.material, Q: o.Q, U: o.u, V: o.v}, true
+	case *box:
+		return Description{Shape: BOX, Material: o.material, Min: o.min, Max: o.max}, true
+	case *Triangle:
+		d := Description{Shape: TRIANGLE, Material: o.Material, Vertices: o.Vertices[:]}
+		if o.hasVertexNormals {
+			d.Normals = o.Normals[:]
+		}
+		if o.hasUV {
+			d.UVs = o.texCoords[:]
+		}
+		return d, true
+	case *constantMedium:
+		return Description{Shape: MEDIUM, Material: o.phaseFunction, Density: -1 / o.negativeInverseDensity, Children: []Hittable{o.boundary}}, true
+	case *HittableList:
+		return Description{Shape: LIST, Children: o.objects}, true
+	case *BVHNode:
+		return Description{Shape: BVH, Children: o.leaves(nil)}, true
+	case *translate:
+		return Description{Shape: TRANSLATE, Offset: o.offset, Children: []Hittable{o.object}}, true
+	case *rotateY:
+		return Description{Shape: ROTATE_Y, Angle: o.angle, Children: []Hittable{o.object}}, true
+	}
+	return Description{}, false
+}
+
+// Appends the objects the BVH was built from, skipping the duplicated leaves of single object nodes.
+func (bvh *BVHNode) leaves(objects []Hittable) []Hittable {
+	for i, child := range []Hittable{bvh.left, bvh.right} {
+		if i == 1 && child == bvh.left {
+			break
+		}
+		if node, ok := child.(*BVHNode); ok {
+			objects = node.leaves(objects)
+		} else {
+			objects = append(objects, child)
+		}
+	}
+	return objects
+}
+
+// The kind of material a MaterialDescription describes.
+type MaterialKind uint8
+
+const (
+	_ MaterialKind = iota
+	LAMBERTIAN
+	METAL
+	DIELECTRIC
+	DIFFUSE_LIGHT
+	ISOTROPIC
+)
+
+// MaterialDescription holds the arguments a material was constructed with.
+type MaterialDescription struct {
+	Kind            MaterialKind
+	Texture         Texture   // lambertian, diffuse light and isotropic
+	Albedo          *vec.Vec3 // metal
+	Fuzz            float64   // metal
+	RefractionIndex float64   // dielectric
+}
+
+// DescribeMaterial returns how a material was constructed, or false for materials defined outside this package.
+func DescribeMaterial(m Material) (MaterialDescription, bool) {
+	switch o := m.(type) {
+	case *lambertian:
+		return MaterialDescription{Kind: LAMBERTIAN, Texture: o.tex}, true
+	case *metal:
+		return MaterialDescription{Kind: METAL, Albedo: o.Albedo, Fuzz: o.Fuzz}, true
+	case *Dielectric:
+		return MaterialDescription{Kind: DIELECTRIC, RefractionIndex: o.RefractionIndex}, true
+	case Dielectric:
+		return MaterialDescription{Kind: DIELECTRIC, RefractionIndex: o.RefractionIndex}, true
+	case *diffuseLight:
+		return MaterialDescription{Kind: DIFFUSE_LIGHT, Texture: o.tex}, true
+	case *isotropic:
+		return MaterialDescription{Kind: ISOTROPIC, Texture: o.tex}, true
+	}
+	return MaterialDescription{}, false
+}
+
+// The kind of texture a TextureDescription describes.
+type TextureKind uint8
+
+const (
+	_ TextureKind = iota
+	SOLID
+	CHECKER
+	IMAGE
+	NOISE
+)
+
+// TextureDescription holds the arguments a texture was constructed with.
+type TextureDescription struct {
+	Kind    TextureKind
+	Color   *vec.Vec3  // solid
+	Scale   float64    // checker and noise
+	Even    Texture    // checker
+	Odd     Texture    // checker
+	File    string     // image, as it was passed to NewImageTexture
+	Variant perlinType // noise: PERLIN, MARBLE or TURBULENT
+}
+
+// DescribeTexture returns how a texture was constructed, or false for textures defined outside this package.
+func DescribeTexture(t Texture) (TextureDescription, bool) {
+	switch o := t.(type) {
+	case *solidColor:
+		return TextureDescription{Kind: SOLID, Color: o.Albedo}, true
+	case *checkerboard:
+		return TextureDescription{Kind: CHECKER, Scale: 1 / o.inv_scale, Even: o.even, Odd: o.odd}, true
+	case *imageTexture:
+		return TextureDescription{Kind: IMAGE, File: o.filename}, true
+	case *noiseTexture:
+		return TextureDescription{Kind: NOISE, Scale: o.scale, Variant: o.variant}, true
+	}
+	return TextureDescription{}, false
+}
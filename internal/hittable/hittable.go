@@ -1,7 +1,6 @@
 package hittable
 
 import (
-	"log"
 	"math/rand"
 
 	"github.com/nsp5488/go_raytracer/internal/aabb"
@@ -12,19 +11,22 @@ import (
 
 // Records information about a ray hitting a surface (hittable)
 type HitRecord struct {
-	p         *vec.Vec3
-	normal    *vec.Vec3
-	t         float64
-	frontFace bool
+	p               *vec.Vec3
+	normal          *vec.Vec3
+	geometricNormal *vec.Vec3 // outward surface normal, before interpolation and facing the ray
+	t               float64
+	frontFace       bool
 
 	u float64
 	v float64
 
 	Material Material
+	object   Hittable
 }
 
 // Sets the face normal based on the ray direction and the normal vector
 func (hr *HitRecord) setFaceNormal(r *ray.Ray, normal *vec.Vec3) {
+	hr.geometricNormal = normal
 	hr.frontFace = r.Direction().Dot(normal) < 0
 	if hr.frontFace {
 		hr.normal = normal
@@ -38,6 +40,11 @@ func (hr *HitRecord) Normal() *vec.Vec3 {
 	return hr.normal
 }
 
+// Returns the outward normal of the hit surface's geometry, ignoring interpolated vertex normals
+func (hr *HitRecord) GeometricNormal() *vec.Vec3 {
+	return hr.geometricNormal
+}
+
 func (hr *HitRecord) U() float64 {
 	return hr.u
 }
@@ -56,6 +63,26 @@ func (hr *HitRecord) FrontFace() bool {
 	return hr.frontFace
 }
 
+// Returns the primitive that was hit
+func (hr *HitRecord) Object() Hittable {
+	return hr.object
+}
+
+// Returns the color of the hit surface's material, without any lighting
+func (hr *HitRecord) Albedo() *vec.Vec3 {
+	switch m := hr.Material.(type) {
+	case *lambertian:
+		return m.tex.Value(hr.u, hr.v, hr.p)
+	case *metal:
+		return m.Albedo
+	case *diffuseLight:
+		return m.tex.Value(hr.u, hr.v, hr.p)
+	case *isotropic:
+		return m.tex.Value(hr.u, hr.v, hr.p)
+	}
+	return vec.New(1, 1, 1)
+}
+
 // Defines the behavior of a hittable object
 type Hittable interface {
 	Hit(r *ray.Ray, rayT interval.Interval, record *HitRecord) bool
@@ -64,14 +91,43 @@ type Hittable interface {
 	Random(origin *vec.Vec3) *vec.Vec3
 }
 
-type defaultPdfImpl struct{}
+// Implemented by hittables that are built out of other hittables.
+type composite interface {
+	children() []Hittable
+}
 
-func (d defaultPdfImpl) PdfValue(origin, direction *vec.Vec3) float64 {
-	log.Fatal("hit an invalid PDF function")
-	return 0.0
+// Calls fn for h and every hittable it is built from, parents before their children.
+// Hittables shared within the graph, such as the duplicated leaves of a BVH, are only visited once.
+func Walk(h Hittable, fn func(Hittable)) {
+	walk(h, fn, map[Hittable]bool{})
 }
-func (d defaultPdfImpl) Random(origin *vec.Vec3) *vec.Vec3 {
-	return vec.New(1, 0, 0)
+
+func walk(h Hittable, fn func(Hittable), seen map[Hittable]bool) {
+	if h == nil || seen[h] {
+		return
+	}
+	seen[h] = true
+	fn(h)
+	if c, ok := h.(composite); ok {
+		for _, child := range c.children() {
+			walk(child, fn, seen)
+		}
+	}
+}
+
+// Returns the material of a primitive, or nil for hittables which group or transform other hittables.
+func MaterialOf(h Hittable) Material {
+	switch o := h.(type) {
+	case *sphere:
+		return o.Material
+	case *quad:
+		return o.material
+	case *Triangle:
+		return o.Material
+	case *constantMedium:
+		return o.phaseFunction
+	}
+	return nil
 }
 
 // A container struct for a list of hittable objects. Effectively a scene.
@@ -114,6 +170,15 @@ func (hl *HittableList) Add(obj Hittable) {
 	hl.objects = append(hl.objects, obj)
 	hl.bbox = aabb.FromBBoxes(hl.bbox, obj.BBox())
 }
+
+// Returns the number of objects in the list
+func (hl *HittableList) Len() int {
+	return len(hl.objects)
+}
+
+func (hl *HittableList) children() []Hittable {
+	return hl.objects
+}
 func (hl *HittableList) BBox() *aabb.AABB {
 	return hl.bbox
 }
@@ -11,7 +11,6 @@ import (
 )
 
 type constantMedium struct {
-	defaultPdfImpl
 	boundary               Hittable
 	negativeInverseDensity float64
 	phaseFunction          Material
@@ -52,11 +51,25 @@ func (cm *constantMedium) Hit(r *ray.Ray, rayT interval.Interval, record *HitRec
 	record.t = hr1.t + hitDistance/rayLength
 	record.p = r.At(record.t)
 	record.normal = vec.New(1, 0, 0)
+	record.geometricNormal = record.normal
 	record.frontFace = true
 	record.Material = cm.phaseFunction
+	record.object = cm
 	return true
 }
 
+// A glowing medium is sampled through its boundary.
+func (cm *constantMedium) PdfValue(origin, direction *vec.Vec3) float64 {
+	return cm.boundary.PdfValue(origin, direction)
+}
+func (cm *constantMedium) Random(origin *vec.Vec3) *vec.Vec3 {
+	return cm.boundary.Random(origin)
+}
+
+func (cm *constantMedium) children() []Hittable {
+	return []Hittable{cm.boundary}
+}
+
 func (cm *constantMedium) BBox() *aabb.AABB {
 	return cm.boundary.BBox()
 }
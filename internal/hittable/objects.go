@@ -110,6 +110,7 @@ func (s *sphere) Hit(r *ray.Ray, rayT interval.Interval, record *HitRecord) bool
 	outward_normal := record.p.Sub(curCenter).Scale(1 / s.Radius)
 	record.setFaceNormal(r, outward_normal)
 	record.Material = s.Material
+	record.object = s
 	calculateSphereUV(outward_normal, &record.u, &record.v)
 	return true
 }
@@ -191,6 +192,7 @@ func (q *quad) Hit(r *ray.Ray, rayT interval.Interval, record *HitRecord) bool {
 	record.t = t
 	record.p = intersection
 	record.Material = q.material
+	record.object = q
 	record.setFaceNormal(r, q.normal)
 	return true
 }
@@ -205,6 +207,13 @@ func isInterior(alpha, beta float64, record *HitRecord) bool {
 	return true
 }
 
+// An axis aligned box made of six quads, which remembers its corners so it can be described.
+type box struct {
+	*BVHNode
+	min, max *vec.Vec3
+	material Material
+}
+
 func NewBox(a, b *vec.Vec3, mat Material) Hittable {
 	sides := NewHittableList(6)
 
@@ -236,11 +245,10 @@ func NewBox(a, b *vec.Vec3, mat Material) Hittable {
 	// bottom
 	sides.Add(NewQuad(vec.New(minVec.X(), minVec.Y(), minVec.Z()), dx, dz, mat))
 
-	return BuildBVH(sides)
+	return &box{BVHNode: BuildBVH(sides), min: minVec, max: maxVec, material: mat}
 }
 
 type Triangle struct {
-	defaultPdfImpl
 	Vertices [3]*vec.Vec3
 	Normals  [3]*vec.Vec3 // Vertex normals from the OBJ file
 	normal   *vec.Vec3    // Face normal (calculated from vertices)
@@ -451,11 +459,13 @@ func (t *Triangle) Hit(r *ray.Ray, rayT interval.Interval, record *HitRecord) bo
 	if t.hasVertexNormals {
 		interpolatedNormal := t.interpolateNormal(u, v)
 		record.setFaceNormal(r, interpolatedNormal)
+		record.geometricNormal = t.normal
 	} else {
 		record.setFaceNormal(r, t.normal)
 	}
 
 	record.Material = t.Material
+	record.object = t
 
 	return true
 }
@@ -60,11 +60,22 @@ func (cb *checkerboard) Value(u, v float64, point *vec.Vec3) *vec.Vec3 {
 }
 
 type imageTexture struct {
-	img *ImageLoader.RTImage
+	img      *ImageLoader.RTImage
+	filename string
 }
 
-func NewImageTexture(filename string) *imageTexture {
-	return &imageTexture{img: ImageLoader.LoadImage(filename)}
+// Loads an image file as a texture, see ImageLoader.LoadImage for the errors returned.
+func NewImageTexture(filename string) (*imageTexture, error) {
+	img, err := ImageLoader.LoadImage(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &imageTexture{img: img, filename: filename}, nil
+}
+
+// Returns the magenta and black checkerboard used in place of textures which could not be loaded.
+func NewMissingTexture() *checkerboard {
+	return NewCheckerboardColors(0.5, vec.New(1, 0, 1), vec.New(0, 0, 0))
 }
 
 func (it *imageTexture) Value(u, v float64, point *vec.Vec3) *vec.Vec3 {
@@ -11,7 +11,6 @@ import (
 )
 
 type translate struct {
-	defaultPdfImpl
 	object Hittable
 	offset *vec.Vec3
 	bbox   *aabb.AABB
@@ -33,20 +32,32 @@ func (t *translate) Hit(r *ray.Ray, rayT interval.Interval, record *HitRecord) b
 	return true
 }
 
+// Samples the object as seen from the origin moved into its own space.
+func (t *translate) PdfValue(origin, direction *vec.Vec3) float64 {
+	return t.object.PdfValue(origin.Sub(t.offset), direction)
+}
+func (t *translate) Random(origin *vec.Vec3) *vec.Vec3 {
+	return t.object.Random(origin.Sub(t.offset))
+}
+
+func (t *translate) children() []Hittable {
+	return []Hittable{t.object}
+}
+
 func (t *translate) BBox() *aabb.AABB {
 	return t.bbox
 }
 
 type rotateY struct {
-	defaultPdfImpl
 	object   Hittable
+	angle    float64 // in degrees
 	sinTheta float64
 	cosTheta float64
 	bbox     *aabb.AABB
 }
 
 func RotateY(object Hittable, theta float64) *rotateY {
-	ry := &rotateY{object: object}
+	ry := &rotateY{object: object, angle: theta}
 	radians := util.DegressToRadians(theta)
 	ry.sinTheta = math.Sin(radians)
 	ry.cosTheta = math.Cos(radians)
@@ -102,9 +113,22 @@ func (ry *rotateY) Hit(r *ray.Ray, rayT interval.Interval, record *HitRecord) bo
 	}
 	record.p = ry.recordTranslationHelper(record.p)
 	record.normal = ry.recordTranslationHelper(record.normal)
+	record.geometricNormal = ry.recordTranslationHelper(record.geometricNormal)
 
 	return true
 }
+
+// Samples the object in its own space, turning the directions back into the world's.
+func (ry *rotateY) PdfValue(origin, direction *vec.Vec3) float64 {
+	return ry.object.PdfValue(ry.rayTranslationHelper(origin), ry.rayTranslationHelper(direction))
+}
+func (ry *rotateY) Random(origin *vec.Vec3) *vec.Vec3 {
+	return ry.recordTranslationHelper(ry.object.Random(ry.rayTranslationHelper(origin)))
+}
+
+func (ry *rotateY) children() []Hittable {
+	return []Hittable{ry.object}
+}
 func (ry *rotateY) BBox() *aabb.AABB {
 	return ry.bbox
 }
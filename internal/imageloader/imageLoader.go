@@ -6,7 +6,6 @@ import (
 	"image/color"
 	_ "image/jpeg"
 	_ "image/png"
-	"log"
 	"os"
 )
 
@@ -25,15 +24,31 @@ type RTImage struct {
 	bdata []*pixel
 }
 
-// Loads the specified filename as an RTImage
-func LoadImage(filename string) *RTImage {
+// DecodeError is returned for image files which can't be decoded, because they are corrupt or in an unsupported format.
+type DecodeError struct {
+	File string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("could not decode image %s: %v", e.File, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Loads the specified filename as an RTImage. A missing file gives an error matching fs.ErrNotExist,
+// one which can't be decoded a *DecodeError.
+func LoadImage(filename string) (*RTImage, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		log.Fatalf("Could not open %s", filename)
+		return nil, fmt.Errorf("could not open image: %w", err)
 	}
+	defer file.Close()
 	img, format, err := image.Decode(file)
 	if err != nil {
-		log.Fatalf("Error while decoding %s: %s", filename, err.Error())
+		return nil, &DecodeError{File: filename, Err: err}
 	}
 	i := &RTImage{img: img, format: format}
 	i.Width = i.img.Bounds().Dx()
@@ -42,7 +57,7 @@ func LoadImage(filename string) *RTImage {
 	i.bdata = make([]*pixel, i.Width*i.Height, i.Width*i.Height)
 	i.convertToBytes()
 
-	return i
+	return i, nil
 }
 
 // Magenta to return whenever there's no valid data
@@ -54,7 +69,6 @@ func (rti *RTImage) PixelData(x, y int) *pixel {
 	idx := y*rti.Width + x
 
 	if idx >= len(rti.bdata) || rti.bdata[idx] == nil {
-		fmt.Println(idx)
 		return magenta
 	}
 	return rti.bdata[idx]
@@ -1,6 +1,10 @@
 package ImageLoader_test
 
 import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 
 	ImageLoader "github.com/nsp5488/go_raytracer/internal/imageloader"
@@ -9,7 +13,10 @@ import (
 func TestReadPNG(t *testing.T) {
 	// test.png was generated as a simple 5x5 image in ppm format then converted to png
 
-	rti := ImageLoader.LoadImage("test.png")
+	rti, err := ImageLoader.LoadImage("test.png")
+	if err != nil {
+		t.Fatalf("Error loading image: %v", err)
+	}
 	exp := "5, 5, png, 25"
 	act := rti.String()
 	if exp != act {
@@ -20,7 +27,10 @@ func TestReadPNG(t *testing.T) {
 }
 func TestReadJPG(t *testing.T) {
 	// test.jpg was generated as a simple 5x5 image in ppm format then converted to JPEG
-	rti := ImageLoader.LoadImage("test.jpg")
+	rti, err := ImageLoader.LoadImage("test.jpg")
+	if err != nil {
+		t.Fatalf("Error loading image: %v", err)
+	}
 	exp := "5, 5, jpeg, 25"
 	act := rti.String()
 	if exp != act {
@@ -29,6 +39,22 @@ func TestReadJPG(t *testing.T) {
 	testImageData(rti, LOSSY_IMG_DATA, t)
 }
 
+func TestLoadErrors(t *testing.T) {
+	if _, err := ImageLoader.LoadImage("missing.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not found error, but got %v", err)
+	}
+
+	corrupt := filepath.Join(t.TempDir(), "corrupt.png")
+	if err := os.WriteFile(corrupt, []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ImageLoader.LoadImage(corrupt)
+	var decodeErr *ImageLoader.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.File != corrupt {
+		t.Errorf("Expected a decode error for %s, but got %v", corrupt, err)
+	}
+}
+
 // Slightly lossy img data from conversion to JPEG
 var LOSSY_IMG_DATA = [25][3]uint8{
 	{216, 226, 255},
@@ -0,0 +1,303 @@
+// Package lens traces rays through systems of spherical lens elements, as described by lens prescriptions.
+//
+// A prescription lists the surfaces of a lens from the front, facing the scene, to the rear, facing the film, one per
+// line as in pbrt's lens files: the radius of curvature, the thickness to the next surface, the index of refraction
+// behind the surface and the aperture diameter, all lengths in millimeters. A radius of 0 marks the aperture stop.
+// Lines starting with # are comments.
+//
+// Rays are traced in the lens' own space, with the optical axis along z, the rear surface's vertex at the origin and
+// the scene towards +z. The film lies behind the rear surface, at the distance which focuses the lens.
+package lens
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Element is one surface of a lens system.
+type Element struct {
+	Radius    float64 // of curvature, positive when the center of curvature lies behind the surface, 0 for the aperture stop
+	Thickness float64 // distance along the axis to the next surface, ignored for the rear surface
+	IOR       float64 // index of refraction of the medium behind the surface, 0 for air
+	Aperture  float64 // diameter of the surface
+}
+
+// System is a lens made of spherical elements, read with Load or Parse.
+type System struct {
+	Elements []Element
+	File     string // the prescription the lens was loaded from, empty for one which was parsed
+
+	vertices []float64 // z of every surface's vertex
+}
+
+// ParseError reports a malformed line of a lens prescription.
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: line %d: %s", e.File, e.Line, e.Msg)
+}
+
+// Loads the lens prescription in the given file. A missing file gives an error matching fs.ErrNotExist,
+// a malformed one a *ParseError.
+func Load(filename string) (*System, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lens: %w", err)
+	}
+	defer file.Close()
+	s, err := parse(file, filename)
+	if err != nil {
+		return nil, err
+	}
+	s.File = filename
+	return s, nil
+}
+
+// Parse reads a lens prescription. A malformed one gives a *ParseError.
+func Parse(r io.Reader) (*System, error) {
+	return parse(r, "lens")
+}
+
+func parse(r io.Reader, name string) (*System, error) {
+	s := &System{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, &ParseError{name, lineNum, fmt.Sprintf("expected radius, thickness, index of refraction and aperture, but got %d values", len(fields))}
+		}
+		var values [4]float64
+		for k, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, &ParseError{name, lineNum, fmt.Sprintf("invalid number %q", field)}
+			}
+			values[k] = v
+		}
+		e := Element{Radius: values[0], Thickness: values[1], IOR: values[2], Aperture: values[3]}
+		switch {
+		case e.Thickness < 0:
+			return nil, &ParseError{name, lineNum, fmt.Sprintf("thickness must not be negative, but got %v", e.Thickness)}
+		case e.IOR < 0:
+			return nil, &ParseError{name, lineNum, fmt.Sprintf("index of refraction must not be negative, but got %v", e.IOR)}
+		case e.Aperture <= 0:
+			return nil, &ParseError{name, lineNum, fmt.Sprintf("aperture must be positive, but got %v", e.Aperture)}
+		case e.Radius != 0 && math.Abs(e.Radius) < e.Aperture/2:
+			return nil, &ParseError{name, lineNum, fmt.Sprintf("a surface of radius %v can't be %v wide", e.Radius, e.Aperture)}
+		}
+		s.Elements = append(s.Elements, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.Elements) == 0 {
+		return nil, &ParseError{name, lineNum, "no lens elements found"}
+	}
+	s.initialize()
+	return s, nil
+}
+
+// Places the surfaces along the axis, the rear one at the origin.
+func (s *System) initialize() {
+	s.vertices = make([]float64, len(s.Elements))
+	z := 0.0
+	for i := len(s.Elements) - 1; i >= 0; i-- {
+		s.vertices[i] = z
+		if i > 0 {
+			z += s.Elements[i-1].Thickness
+		}
+	}
+}
+
+// Returns the index of refraction behind surface i, where -1 stands for the scene in front of the lens.
+func (s *System) ior(i int) float64 {
+	if i < 0 || s.Elements[i].IOR == 0 {
+		return 1
+	}
+	return s.Elements[i].IOR
+}
+
+// Returns the radius of the rear surface.
+func (s *System) RearRadius() float64 {
+	return s.Elements[len(s.Elements)-1].Aperture / 2
+}
+
+// Trace follows a ray from the film side of the lens through every surface, returning it as it leaves the front
+// surface. Rays which miss a surface's aperture or are reflected inside the glass give false.
+func (s *System) Trace(origin, direction *vec.Vec3) (*vec.Vec3, *vec.Vec3, bool) {
+	for i := len(s.Elements) - 1; i >= 0; i-- {
+		var ok bool
+		if origin, direction, ok = s.refract(i, origin, direction, s.ior(i), s.ior(i-1)); !ok {
+			return nil, nil, false
+		}
+	}
+	return origin, direction, true
+}
+
+// Follows a ray from the scene side of the lens through every surface, returning it as it leaves the rear surface.
+func (s *System) traceFromScene(origin, direction *vec.Vec3) (*vec.Vec3, *vec.Vec3, bool) {
+	for i := range s.Elements {
+		var ok bool
+		if origin, direction, ok = s.refract(i, origin, direction, s.ior(i-1), s.ior(i)); !ok {
+			return nil, nil, false
+		}
+	}
+	return origin, direction, true
+}
+
+// Intersects a ray with surface i and refracts it from a medium of index etaI into one of etaT,
+// returning the point it passes the surface at and its new unit direction.
+func (s *System) refract(i int, origin, direction *vec.Vec3, etaI, etaT float64) (*vec.Vec3, *vec.Vec3, bool) {
+	e := s.Elements[i]
+	direction = direction.UnitVector()
+	var t float64
+	var normal *vec.Vec3
+	if e.Radius == 0 {
+		if direction.Z() == 0 {
+			return nil, nil, false
+		}
+		t = (s.vertices[i] - origin.Z()) / direction.Z()
+	} else {
+		center := vec.New(0, 0, s.vertices[i]-e.Radius)
+		oc := origin.Sub(center)
+		b := oc.Dot(direction)
+		discriminant := b*b - (oc.LengthSquared() - e.Radius*e.Radius)
+		if discriminant < 0 {
+			return nil, nil, false
+		}
+		// of the two points on the sphere, the surface is the cap around the vertex
+		root := math.Sqrt(discriminant)
+		t = -b - root
+		if far := -b + root; math.Abs(origin.Z()+far*direction.Z()-s.vertices[i]) < math.Abs(origin.Z()+t*direction.Z()-s.vertices[i]) {
+			t = far
+		}
+		normal = origin.Add(direction.Scale(t)).Sub(center).Scale(1 / math.Abs(e.Radius))
+	}
+	if t <= 0 {
+		return nil, nil, false
+	}
+	p := origin.Add(direction.Scale(t))
+	if p.X()*p.X()+p.Y()*p.Y() > e.Aperture*e.Aperture/4 {
+		return nil, nil, false
+	}
+	if normal == nil {
+		return p, direction, true
+	}
+	if normal.Dot(direction) > 0 {
+		normal = normal.Negate()
+	}
+	// beyond the critical angle the ray is reflected back into the lens, where it is absorbed
+	cosI := -direction.Dot(normal)
+	ratio := etaI / etaT
+	if ratio*ratio*(1-cosI*cosI) > 1 {
+		return nil, nil, false
+	}
+	return p, direction.Refract(normal, ratio).UnitVector(), true
+}
+
+// Cardinal points of the lens, along the axis.
+type cardinal struct {
+	focalLength float64 // effective focal length
+	front, rear float64 // z of the principal planes of the scene and film sides
+}
+
+// Finds the lens' cardinal points from rays close and parallel to the axis, bent by the lens towards its focal points.
+func (s *System) cardinalPoints() (cardinal, error) {
+	h := 0.001 * s.Elements[0].Aperture
+	front := s.vertices[0] + 1
+	o, d, ok := s.traceFromScene(vec.New(h, 0, front), vec.New(0, 0, -1))
+	if !ok || d.X() == 0 {
+		return cardinal{}, errors.New("the lens does not focus rays from the scene")
+	}
+	// where the ray crosses the axis and where it is as high as it entered, extended back into the lens
+	rearFocus := o.Z() - o.X()/d.X()*d.Z()
+	rearPrincipal := o.Z() + (h-o.X())/d.X()*d.Z()
+
+	h = 0.001 * s.Elements[len(s.Elements)-1].Aperture
+	o, d, ok = s.Trace(vec.New(h, 0, -1), vec.New(0, 0, 1))
+	if !ok || d.X() == 0 {
+		return cardinal{}, errors.New("the lens does not focus rays from the film")
+	}
+	frontPrincipal := o.Z() + (h-o.X())/d.X()*d.Z()
+
+	f := rearPrincipal - rearFocus
+	if f <= 0 {
+		return cardinal{}, fmt.Errorf("the lens diverges light, with a focal length of %.4gmm", f)
+	}
+	return cardinal{focalLength: f, front: frontPrincipal, rear: rearPrincipal}, nil
+}
+
+// FocalLength returns the effective focal length of the lens.
+func (s *System) FocalLength() (float64, error) {
+	c, err := s.cardinalPoints()
+	return c.focalLength, err
+}
+
+// FilmDistance returns how far behind the rear surface the film must be for the lens to focus at the given distance
+// from the film, treating the lens as a thick lens.
+func (s *System) FilmDistance(focus float64) (float64, error) {
+	c, err := s.cardinalPoints()
+	if err != nil {
+		return 0, err
+	}
+	// With the film g behind the rear surface, the object lies x = focus - g - front in front of the front
+	// principal plane and must be imaged g + rear behind the rear one, at x·f / (x - f). Their sum k is fixed,
+	// so x solves x² - k·x + k·f = 0, the far root being the focused lens.
+	f := c.focalLength
+	k := focus + c.rear - c.front
+	discriminant := k*k - 4*k*f
+	if k <= 0 || discriminant < 0 {
+		return 0, fmt.Errorf("the lens can't focus as close as %.4gmm", focus)
+	}
+	x := (k + math.Sqrt(discriminant)) / 2
+	g := focus - c.front - x
+	if g <= 0 {
+		return 0, fmt.Errorf("the lens can't focus at %.4gmm, it would need the film inside the lens", focus)
+	}
+	return g, nil
+}
+
+// ExitPupil bounds the part of the rear surface light from the scene reaches the film through. It returns the radius
+// of the disk around the axis, in the plane of the rear vertex, holding every point through which a ray from the film
+// within filmRadius of its center leaves the lens.
+func (s *System) ExitPupil(filmDistance, filmRadius float64) (float64, error) {
+	const filmSteps, grid = 16, 64
+	rear := s.RearRadius()
+	cell := 2 * rear / grid
+	radius := 0.0
+	for k := range filmSteps + 1 {
+		film := vec.New(filmRadius*float64(k)/filmSteps, 0, -filmDistance)
+		for gy := range grid {
+			for gx := range grid {
+				x, y := -rear+(float64(gx)+0.5)*cell, -rear+(float64(gy)+0.5)*cell
+				if r := math.Hypot(x, y); r+cell > radius {
+					if _, _, ok := s.Trace(film, vec.New(x, y, 0).Sub(film)); ok {
+						radius = min(r+cell, rear)
+					}
+				}
+			}
+		}
+	}
+	if radius == 0 {
+		return 0, errors.New("no light passes the lens")
+	}
+	return radius, nil
+}
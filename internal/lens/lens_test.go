@@ -0,0 +1,108 @@
+package lens_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/lens"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// A biconvex lens of crown glass, 2mm thick, with its stop right behind it.
+const singlet = `# radius thickness ior aperture
+50	2	1.5	20
+-50	1	1	20
+0	0	0	10
+`
+
+func parse(t *testing.T, prescription string) *lens.System {
+	t.Helper()
+	s, err := lens.Parse(strings.NewReader(prescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestParse(t *testing.T) {
+	s := parse(t, singlet)
+	exp := []lens.Element{{50, 2, 1.5, 20}, {-50, 1, 1, 20}, {0, 0, 0, 10}}
+	if len(s.Elements) != len(exp) {
+		t.Fatalf("Expected %d elements, but got %d", len(exp), len(s.Elements))
+	}
+	for i, e := range exp {
+		if s.Elements[i] != e {
+			t.Errorf("Expected %+v, but got %+v", e, s.Elements[i])
+		}
+	}
+
+	for prescription, line := range map[string]int{
+		"50 2 1.5":               1,
+		"# a comment\n50 2 x 20": 2,
+		"50 2 1.5 -20":           1,
+		"5 2 1.5 20":             1,
+		"# nothing but comments": 1,
+	} {
+		_, err := lens.Parse(strings.NewReader(prescription))
+		var parseErr *lens.ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != line {
+			t.Errorf("Expected an error on line %d of %q, but got %v", line, prescription, err)
+		}
+	}
+}
+
+func TestFocalLength(t *testing.T) {
+	// the lensmaker's equation for a thick lens
+	n, r1, r2, d := 1.5, 50.0, -50.0, 2.0
+	exp := 1 / ((n - 1) * (1/r1 - 1/r2 + (n-1)*d/(n*r1*r2)))
+	f, err := parse(t, singlet).FocalLength()
+	if err != nil || math.Abs(f-exp) > 0.01 {
+		t.Errorf("Expected a focal length of %v, but got %v (%v)", exp, f, err)
+	}
+}
+
+func TestFilmDistance(t *testing.T) {
+	s := parse(t, singlet)
+	far, err := s.FilmDistance(1e7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, focus := range []float64{2000, 500, 250} {
+		g, err := s.FilmDistance(focus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g <= far {
+			t.Errorf("Expected the film to move away from the lens to focus at %vmm, but got %v", focus, g)
+		}
+		// a ray from the center of the film meets the axis again at the distance in focus
+		o, d, ok := s.Trace(vec.New(0, 0, -g), vec.New(0.5, 0, g))
+		if !ok {
+			t.Fatal("Expected the ray to pass the lens")
+		}
+		if z := o.Z() - o.X()/d.X()*d.Z() + g; math.Abs(z-focus)/focus > 0.01 {
+			t.Errorf("Expected the lens to focus at %vmm, but it focuses at %v", focus, z)
+		}
+	}
+	if _, err := s.FilmDistance(100); err == nil {
+		t.Error("Expected an error focusing closer than four focal lengths")
+	}
+}
+
+func TestTraceBlocked(t *testing.T) {
+	s := parse(t, singlet)
+	g, _ := s.FilmDistance(1e7)
+	if _, _, ok := s.Trace(vec.New(0, 0, -g), vec.New(0, 0, 1)); !ok {
+		t.Error("Expected a ray along the axis to pass the lens")
+	}
+	// the stop is half as wide as the lens
+	if _, _, ok := s.Trace(vec.New(7, 0, -g), vec.New(0, 0, 1)); ok {
+		t.Error("Expected the stop to block a ray outside of it")
+	}
+	// the stop is the rear of the lens, so it is the exit pupil, give or take the grid it is measured on
+	if radius, err := s.ExitPupil(g, 5); err != nil || radius < 5 || radius > 5.5 {
+		t.Errorf("Expected the exit pupil to be the stop, but got a radius of %v (%v)", radius, err)
+	}
+}
@@ -0,0 +1,105 @@
+package mesh
+
+import (
+	"fmt"
+
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Mesh is an indexed triangle mesh, the form models take when converting them between file formats.
+type Mesh struct {
+	Positions []*vec.Vec3
+	Normals   []*vec.Vec3  // one per position, nil if the mesh has no vertex normals
+	UVs       [][2]float64 // one per position, nil if the mesh has no texture coordinates
+	Faces     [][3]int     // indices into the vertex attributes, counter-clockwise when viewed from the front
+}
+
+// The attributes of a vertex, used to merge the vertices shared by triangles.
+type vertex struct {
+	p, n [3]float64
+	uv   [2]float64
+}
+
+// FromHittable collects the triangles of h, such as a model loaded by the OBJ loader, into a mesh.
+// Vertices with the same attributes are merged. Triangles without vertex normals or texture coordinates get their
+// face normal and (0, 0) if other triangles of the mesh have them. Other shapes and transforms can't be converted.
+func FromHittable(h hittable.Hittable) (*Mesh, error) {
+	var triangles []hittable.Description
+	var err error
+	hasNormals, hasUVs := false, false
+	hittable.Walk(h, func(o hittable.Hittable) {
+		d, ok := hittable.Describe(o)
+		switch {
+		case err != nil:
+		case !ok:
+			err = fmt.Errorf("cannot convert objects of type %T to a mesh", o)
+		case d.Shape == hittable.TRIANGLE:
+			triangles = append(triangles, d)
+			hasNormals = hasNormals || d.Normals != nil
+			hasUVs = hasUVs || d.UVs != nil
+		case d.Shape != hittable.LIST && d.Shape != hittable.BVH:
+			err = fmt.Errorf("cannot convert objects of type %T to a mesh, only triangles are supported", o)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mesh{}
+	indices := map[vertex]int{}
+	for _, d := range triangles {
+		face := [3]int{}
+		normal := d.Vertices[1].Sub(d.Vertices[0]).Cross(d.Vertices[2].Sub(d.Vertices[0])).UnitVector()
+		for i, p := range d.Vertices {
+			v := vertex{p: toArray(p)}
+			if hasNormals {
+				n := normal
+				if d.Normals != nil {
+					n = d.Normals[i]
+				}
+				v.n = toArray(n)
+			}
+			if d.UVs != nil {
+				v.uv = d.UVs[i]
+			}
+			index, ok := indices[v]
+			if !ok {
+				index = len(m.Positions)
+				indices[v] = index
+				m.Positions = append(m.Positions, vec.New(v.p[0], v.p[1], v.p[2]))
+				if hasNormals {
+					m.Normals = append(m.Normals, vec.New(v.n[0], v.n[1], v.n[2]))
+				}
+				if hasUVs {
+					m.UVs = append(m.UVs, v.uv)
+				}
+			}
+			face[i] = index
+		}
+		m.Faces = append(m.Faces, face)
+	}
+	return m, nil
+}
+
+func toArray(v *vec.Vec3) [3]float64 {
+	return [3]float64{v.X(), v.Y(), v.Z()}
+}
+
+// Checks that every face refers to existing vertices and every vertex has all of the mesh's attributes.
+func (m *Mesh) validate() error {
+	if m.Normals != nil && len(m.Normals) != len(m.Positions) {
+		return fmt.Errorf("mesh has %d normals for %d vertices", len(m.Normals), len(m.Positions))
+	}
+	if m.UVs != nil && len(m.UVs) != len(m.Positions) {
+		return fmt.Errorf("mesh has %d texture coordinates for %d vertices", len(m.UVs), len(m.Positions))
+	}
+	for i, face := range m.Faces {
+		for _, index := range face {
+			if index < 0 || index >= len(m.Positions) {
+				return fmt.Errorf("face %d refers to vertex %d, but the mesh has %d vertices", i, index, len(m.Positions))
+			}
+		}
+	}
+	return nil
+}
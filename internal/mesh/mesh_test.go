@@ -0,0 +1,118 @@
+package mesh_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/mesh"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Builds a unit square in the xy plane out of two textured triangles sharing an edge.
+func square() hittable.Hittable {
+	mat := hittable.NewLambertian(vec.New(1, 1, 1))
+	p := []*vec.Vec3{vec.New(0, 0, 0), vec.New(1, 0, 0), vec.New(1, 1, 0), vec.New(0, 1, 0)}
+	uv := [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	list := hittable.NewHittableList(2)
+	list.Add(hittable.NewTexturedTriangle([3]*vec.Vec3{p[0], p[1], p[2]}, [3][2]float64{uv[0], uv[1], uv[2]}, mat))
+	list.Add(hittable.NewTexturedTriangle([3]*vec.Vec3{p[0], p[2], p[3]}, [3][2]float64{uv[0], uv[2], uv[3]}, mat))
+	return hittable.BuildBVH(list)
+}
+
+func TestFromHittable(t *testing.T) {
+	m, err := mesh.FromHittable(square())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Positions) != 4 || len(m.UVs) != 4 || m.Normals != nil {
+		t.Errorf("Expected 4 shared vertices with texture coordinates, but got %d positions, %d uvs and %d normals", len(m.Positions), len(m.UVs), len(m.Normals))
+	}
+	if len(m.Faces) != 2 {
+		t.Errorf("Expected 2 faces, but got %d", len(m.Faces))
+	}
+
+	sphere := hittable.NewSphere(vec.New(0, 0, 0), 1, hittable.NewLambertian(vec.New(1, 1, 1)))
+	if _, err := mesh.FromHittable(sphere); err == nil {
+		t.Error("Expected an error converting a sphere")
+	}
+}
+
+func TestWriteOBJ(t *testing.T) {
+	m := &mesh.Mesh{
+		Positions: []*vec.Vec3{vec.New(0, 0, 0), vec.New(1, 0, 0), vec.New(0, 1.5, 0)},
+		Normals:   []*vec.Vec3{vec.New(0, 0, 1), vec.New(0, 0, 1), vec.New(0, 0, 1)},
+		Faces:     [][3]int{{0, 1, 2}},
+	}
+	b := &bytes.Buffer{}
+	if err := mesh.WriteOBJ(b, m); err != nil {
+		t.Fatal(err)
+	}
+	exp := "v 0 0 0\nv 1 0 0\nv 0 1.5 0\nvn 0 0 1\nvn 0 0 1\nvn 0 0 1\nf 1//1 2//2 3//3\n"
+	if act := b.String(); act != exp {
+		t.Errorf("Expected %q, but got %q", exp, act)
+	}
+
+	m.Faces[0][2] = 3
+	if err := mesh.WriteOBJ(b, m); err == nil {
+		t.Error("Expected an error for a face referring to a missing vertex")
+	}
+}
+
+func TestPLYRoundTrip(t *testing.T) {
+	exp, err := mesh.FromHittable(square())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, binary := range []bool{false, true} {
+		b := &bytes.Buffer{}
+		if err := mesh.WritePLY(b, exp, binary); err != nil {
+			t.Fatal(err)
+		}
+		act, err := mesh.ReadPLY(b)
+		if err != nil {
+			t.Fatalf("Expected the PLY file to be read, but got %v", err)
+		}
+		if len(act.Positions) != len(exp.Positions) || len(act.UVs) != len(exp.UVs) || len(act.Faces) != len(exp.Faces) {
+			t.Fatalf("Expected %d vertices and %d faces, but got %d and %d", len(exp.Positions), len(exp.Faces), len(act.Positions), len(act.Faces))
+		}
+		for i, p := range exp.Positions {
+			if !act.Positions[i].Equals(p) || act.UVs[i] != exp.UVs[i] {
+				t.Errorf("Expected vertex %v %v, but got %v %v", p, exp.UVs[i], act.Positions[i], act.UVs[i])
+			}
+		}
+		for i, f := range exp.Faces {
+			if act.Faces[i] != f {
+				t.Errorf("Expected face %v, but got %v", f, act.Faces[i])
+			}
+		}
+	}
+}
+
+func TestReadPLY(t *testing.T) {
+	// a big-endian quad with an extra vertex property and a custom element, stored with ushort coordinates
+	header := "ply\nformat binary_big_endian 1.0\ncomment made by hand\nelement vertex 4\nproperty ushort x\nproperty ushort y\n" +
+		"property ushort z\nproperty uchar red\nelement face 1\nproperty list uchar uint vertex_index\nelement camera 1\nproperty float fov\nend_header\n"
+	data := header +
+		"\x00\x00\x00\x00\x00\x00\xff" + "\x00\x02\x00\x00\x00\x00\xff" + "\x00\x02\x00\x02\x00\x00\xff" + "\x00\x00\x00\x02\x00\x00\xff" +
+		"\x04\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x02\x00\x00\x00\x03" +
+		"\x42\x20\x00\x00"
+	m, err := mesh.ReadPLY(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Positions) != 4 || !m.Positions[2].Equals(vec.New(2, 2, 0)) {
+		t.Errorf("Expected 4 vertices with (2, 2, 0) third, but got %v", m.Positions)
+	}
+	exp := [][3]int{{0, 1, 2}, {0, 2, 3}}
+	if len(m.Faces) != 2 || m.Faces[0] != exp[0] || m.Faces[1] != exp[1] {
+		t.Errorf("Expected %v, but got %v", exp, m.Faces)
+	}
+
+	bad := "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\nelement face 1\n" +
+		"property list uchar int vertex_indices\nend_header\n0 0 0\n3 0 1 2\n"
+	if _, err := mesh.ReadPLY(strings.NewReader(bad)); err == nil {
+		t.Error("Expected an error for a face referring to missing vertices")
+	}
+}
@@ -0,0 +1,49 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteOBJ writes a mesh as a Wavefront OBJ file without materials.
+func WriteOBJ(out io.Writer, m *Mesh) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	for _, p := range m.Positions {
+		fmt.Fprintf(w, "v %s %s %s\n", formatFloat(p.X()), formatFloat(p.Y()), formatFloat(p.Z()))
+	}
+	for _, uv := range m.UVs {
+		fmt.Fprintf(w, "vt %s %s\n", formatFloat(uv[0]), formatFloat(uv[1]))
+	}
+	for _, n := range m.Normals {
+		fmt.Fprintf(w, "vn %s %s %s\n", formatFloat(n.X()), formatFloat(n.Y()), formatFloat(n.Z()))
+	}
+	for _, face := range m.Faces {
+		w.WriteString("f")
+		for _, index := range face {
+			// OBJ indices are 1-based and every attribute uses the vertex's index
+			i := strconv.Itoa(index + 1)
+			switch {
+			case m.UVs != nil && m.Normals != nil:
+				w.WriteString(" " + i + "/" + i + "/" + i)
+			case m.UVs != nil:
+				w.WriteString(" " + i + "/" + i)
+			case m.Normals != nil:
+				w.WriteString(" " + i + "//" + i)
+			default:
+				w.WriteString(" " + i)
+			}
+		}
+		w.WriteString("\n")
+	}
+	return w.Flush()
+}
+
+// Formats a coordinate with as few digits as needed to read it back exactly.
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
@@ -0,0 +1,337 @@
+package mesh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// WritePLY writes a mesh as a Stanford PLY file, either as ASCII or as little-endian binary.
+// Attributes are stored as 32-bit floats, which every PLY reader supports.
+func WritePLY(out io.Writer, m *Mesh, binaryFormat bool) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	format := "ascii"
+	if binaryFormat {
+		format = "binary_little_endian"
+	}
+	fmt.Fprintf(w, "ply\nformat %s 1.0\nelement vertex %d\n", format, len(m.Positions))
+	properties := []string{"x", "y", "z"}
+	if m.Normals != nil {
+		properties = append(properties, "nx", "ny", "nz")
+	}
+	if m.UVs != nil {
+		properties = append(properties, "u", "v")
+	}
+	for _, p := range properties {
+		fmt.Fprintf(w, "property float %s\n", p)
+	}
+	fmt.Fprintf(w, "element face %d\nproperty list uchar int vertex_indices\nend_header\n", len(m.Faces))
+
+	values := make([]float64, 0, len(properties))
+	for i, p := range m.Positions {
+		values = append(values[:0], p.X(), p.Y(), p.Z())
+		if m.Normals != nil {
+			values = append(values, m.Normals[i].X(), m.Normals[i].Y(), m.Normals[i].Z())
+		}
+		if m.UVs != nil {
+			values = append(values, m.UVs[i][0], m.UVs[i][1])
+		}
+		if binaryFormat {
+			for _, v := range values {
+				binary.Write(w, binary.LittleEndian, float32(v))
+			}
+			continue
+		}
+		for j, v := range values {
+			if j > 0 {
+				w.WriteByte(' ')
+			}
+			w.WriteString(strconv.FormatFloat(v, 'g', -1, 32))
+		}
+		w.WriteByte('\n')
+	}
+	for _, face := range m.Faces {
+		if binaryFormat {
+			w.WriteByte(3)
+			binary.Write(w, binary.LittleEndian, [3]int32{int32(face[0]), int32(face[1]), int32(face[2])})
+			continue
+		}
+		fmt.Fprintf(w, "3 %d %d %d\n", face[0], face[1], face[2])
+	}
+	return w.Flush()
+}
+
+// An element declared in a PLY header, e.g. 100 vertices with their properties.
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// A property of a PLY element. Lists are stored as a count followed by that many items.
+type plyProperty struct {
+	name      string
+	kind      string // the type of a scalar or of the items of a list
+	countKind string // the type of the count of a list, empty for scalars
+}
+
+// The size in bytes of each PLY type, by both its old and its sized name.
+var plyTypeSizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+// The names texture coordinates are stored under by different exporters.
+var plyUVNames = [][2]string{{"u", "v"}, {"s", "t"}, {"texture_u", "texture_v"}, {"texture_s", "texture_t"}}
+
+// ReadPLY reads the vertices and faces of a Stanford PLY file in any of its ASCII and binary formats.
+// Vertex normals and texture coordinates are read if present, polygons are split into triangles
+// and other elements and properties are ignored.
+func ReadPLY(in io.Reader) (*Mesh, error) {
+	r := bufio.NewReader(in)
+	elements, order, err := readPLYHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	var read func(kind string) (float64, error)
+	switch {
+	case order == nil:
+		words := bufio.NewScanner(r)
+		words.Split(bufio.ScanWords)
+		read = func(string) (float64, error) {
+			if !words.Scan() {
+				if err := words.Err(); err != nil {
+					return 0, err
+				}
+				return 0, io.ErrUnexpectedEOF
+			}
+			return strconv.ParseFloat(words.Text(), 64)
+		}
+	default:
+		buf := make([]byte, 8)
+		read = func(kind string) (float64, error) {
+			b := buf[:plyTypeSizes[kind]]
+			if _, err := io.ReadFull(r, b); err != nil {
+				return 0, err
+			}
+			return plyValue(b, kind, order), nil
+		}
+	}
+
+	m := &Mesh{}
+	for _, e := range elements {
+		switch e.name {
+		case "vertex":
+			err = m.readVertices(e, read)
+		case "face":
+			err = m.readFaces(e, read)
+		default:
+			err = skipElement(e, read)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading PLY %s data: %w", e.name, err)
+		}
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reads the header of a PLY file, returning its elements and the byte order of binary data, nil for ASCII files.
+func readPLYHeader(r *bufio.Reader) ([]plyElement, binary.ByteOrder, error) {
+	line, err := r.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "ply" {
+		return nil, nil, fmt.Errorf("not a PLY file")
+	}
+	var elements []plyElement
+	var order binary.ByteOrder
+	hasFormat := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("truncated PLY header: %w", err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 {
+				return nil, nil, fmt.Errorf("invalid PLY format %q", strings.TrimSpace(line))
+			}
+			switch fields[1] {
+			case "ascii":
+			case "binary_little_endian":
+				order = binary.LittleEndian
+			case "binary_big_endian":
+				order = binary.BigEndian
+			default:
+				return nil, nil, fmt.Errorf("unknown PLY format %q", fields[1])
+			}
+			hasFormat = true
+		case "element":
+			if len(fields) != 3 {
+				return nil, nil, fmt.Errorf("invalid PLY element %q", strings.TrimSpace(line))
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return nil, nil, fmt.Errorf("invalid PLY element count %q", fields[2])
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return nil, nil, fmt.Errorf("PLY property %q is declared before any element", strings.TrimSpace(line))
+			}
+			p := plyProperty{}
+			switch {
+			case len(fields) == 3:
+				p.kind, p.name = fields[1], fields[2]
+			case len(fields) == 5 && fields[1] == "list":
+				p.countKind, p.kind, p.name = fields[2], fields[3], fields[4]
+			default:
+				return nil, nil, fmt.Errorf("invalid PLY property %q", strings.TrimSpace(line))
+			}
+			for _, kind := range []string{p.kind, p.countKind} {
+				if _, ok := plyTypeSizes[kind]; !ok && kind != "" {
+					return nil, nil, fmt.Errorf("unknown PLY type %q", kind)
+				}
+			}
+			e := &elements[len(elements)-1]
+			e.properties = append(e.properties, p)
+		case "end_header":
+			if !hasFormat {
+				return nil, nil, fmt.Errorf("PLY header does not declare a format")
+			}
+			return elements, order, nil
+		}
+	}
+}
+
+// Decodes a binary PLY value of the given type.
+func plyValue(b []byte, kind string, order binary.ByteOrder) float64 {
+	switch kind {
+	case "char", "int8":
+		return float64(int8(b[0]))
+	case "uchar", "uint8":
+		return float64(b[0])
+	case "short", "int16":
+		return float64(int16(order.Uint16(b)))
+	case "ushort", "uint16":
+		return float64(order.Uint16(b))
+	case "int", "int32":
+		return float64(int32(order.Uint32(b)))
+	case "uint", "uint32":
+		return float64(order.Uint32(b))
+	case "float", "float32":
+		return float64(math.Float32frombits(order.Uint32(b)))
+	}
+	return math.Float64frombits(order.Uint64(b))
+}
+
+// Reads the scalar properties of one element into values, keyed by name. Returns the vertex indices of a face,
+// other lists are skipped.
+func readProperties(e plyElement, read func(string) (float64, error), values map[string]float64) ([]float64, error) {
+	var list []float64
+	for _, p := range e.properties {
+		if p.countKind == "" {
+			v, err := read(p.kind)
+			if err != nil {
+				return nil, err
+			}
+			values[p.name] = v
+			continue
+		}
+		n, err := read(p.countKind)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid list length %v", n)
+		}
+		items := make([]float64, int(n))
+		for i := range items {
+			if items[i], err = read(p.kind); err != nil {
+				return nil, err
+			}
+		}
+		if p.name == "vertex_indices" || p.name == "vertex_index" {
+			list = items
+		}
+	}
+	return list, nil
+}
+
+func (m *Mesh) readVertices(e plyElement, read func(string) (float64, error)) error {
+	has := map[string]bool{}
+	for _, p := range e.properties {
+		has[p.name] = true
+	}
+	for _, name := range []string{"x", "y", "z"} {
+		if !has[name] {
+			return fmt.Errorf("vertices have no %s coordinate", name)
+		}
+	}
+	hasNormals := has["nx"] && has["ny"] && has["nz"]
+	uv := [2]string{}
+	for _, names := range plyUVNames {
+		if has[names[0]] && has[names[1]] {
+			uv = names
+			break
+		}
+	}
+	values := map[string]float64{}
+	for range e.count {
+		if _, err := readProperties(e, read, values); err != nil {
+			return err
+		}
+		m.Positions = append(m.Positions, vec.New(values["x"], values["y"], values["z"]))
+		if hasNormals {
+			m.Normals = append(m.Normals, vec.New(values["nx"], values["ny"], values["nz"]))
+		}
+		if uv[0] != "" {
+			m.UVs = append(m.UVs, [2]float64{values[uv[0]], values[uv[1]]})
+		}
+	}
+	return nil
+}
+
+func (m *Mesh) readFaces(e plyElement, read func(string) (float64, error)) error {
+	values := map[string]float64{}
+	for i := range e.count {
+		indices, err := readProperties(e, read, values)
+		if err != nil {
+			return err
+		}
+		if len(indices) < 3 {
+			return fmt.Errorf("face %d has %d vertices, at least 3 are required", i, len(indices))
+		}
+		// polygons are split into a fan of triangles around their first vertex
+		for j := 2; j < len(indices); j++ {
+			m.Faces = append(m.Faces, [3]int{int(indices[0]), int(indices[j-1]), int(indices[j])})
+		}
+	}
+	return nil
+}
+
+func skipElement(e plyElement, read func(string) (float64, error)) error {
+	values := map[string]float64{}
+	for range e.count {
+		if _, err := readProperties(e, read, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,7 +3,7 @@ package objLoader
 import (
 	"bufio"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
@@ -37,12 +37,13 @@ type MtlMaterial struct {
 // MaterialLibrary stores materials loaded from an MTL file
 type MaterialLibrary struct {
 	Materials map[string]*MtlMaterial
-	BaseDir   string // Directory of MTL file for resolving texture paths
-	Debug     bool
+	BaseDir   string    // Directory of MTL file for resolving texture paths
+	Debug     io.Writer // receives information about the materials as they load, nil for none
+	Warnings  []error   // texture maps which couldn't be loaded and use the missing texture pattern instead
 }
 
 // NewMaterialLibrary creates a new empty material library
-func NewMaterialLibrary(debug bool) *MaterialLibrary {
+func NewMaterialLibrary(debug io.Writer) *MaterialLibrary {
 	return &MaterialLibrary{
 		Materials: make(map[string]*MtlMaterial),
 		Debug:     debug,
@@ -50,13 +51,13 @@ func NewMaterialLibrary(debug bool) *MaterialLibrary {
 }
 
 // LoadMTL loads an MTL file and parses its materials
-func LoadMTL(mtlPath string, debug bool) (*MaterialLibrary, error) {
+func LoadMTL(mtlPath string, debug io.Writer) (*MaterialLibrary, error) {
 	lib := NewMaterialLibrary(debug)
 	lib.BaseDir = filepath.Dir(mtlPath)
 
 	file, err := os.Open(mtlPath)
 	if err != nil {
-		return nil, fmt.Errorf("could not open MTL file %s: %v", mtlPath, err)
+		return nil, fmt.Errorf("could not open MTL file: %w", err)
 	}
 	defer file.Close()
 
@@ -79,8 +80,7 @@ func LoadMTL(mtlPath string, debug bool) (*MaterialLibrary, error) {
 		switch parts[0] {
 		case "newmtl":
 			if len(parts) < 2 {
-				log.Printf("Line %d: Malformed newmtl directive: %s", lineNum, line)
-				continue
+				return nil, &ParseError{File: mtlPath, Line: lineNum, Msg: "malformed newmtl directive, expected a name"}
 			}
 
 			name := parts[1]
@@ -203,25 +203,29 @@ func LoadMTL(mtlPath string, debug bool) (*MaterialLibrary, error) {
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", mtlPath, err)
+	}
+
 	// Convert all materials to raytracer materials
 	for _, mtl := range lib.Materials {
-		mtl.Material = ConvertToRaytracerMaterial(mtl)
+		mtl.Material = lib.ConvertToRaytracerMaterial(mtl)
 	}
 
-	if lib.Debug {
-		fmt.Printf("=== MTL SUMMARY ===\n")
-		fmt.Printf("Loaded %d materials from %s\n", len(lib.Materials), mtlPath)
+	if lib.Debug != nil {
+		fmt.Fprintf(lib.Debug, "=== MTL SUMMARY ===\n")
+		fmt.Fprintf(lib.Debug, "Loaded %d materials from %s\n", len(lib.Materials), mtlPath)
 		for name, mtl := range lib.Materials {
-			fmt.Printf("  Material '%s':\n", name)
-			fmt.Printf("    Diffuse: [%f, %f, %f]\n", mtl.Diffuse.X(), mtl.Diffuse.Y(), mtl.Diffuse.Z())
+			fmt.Fprintf(lib.Debug, "  Material '%s':\n", name)
+			fmt.Fprintf(lib.Debug, "    Diffuse: [%f, %f, %f]\n", mtl.Diffuse.X(), mtl.Diffuse.Y(), mtl.Diffuse.Z())
 			if mtl.MapKd != "" {
-				fmt.Printf("    Diffuse Map: %s\n", mtl.MapKd)
+				fmt.Fprintf(lib.Debug, "    Diffuse Map: %s\n", mtl.MapKd)
 			}
 			if mtl.Dissolve < 1.0 {
-				fmt.Printf("    Transparency: %f\n", 1.0-mtl.Dissolve)
+				fmt.Fprintf(lib.Debug, "    Transparency: %f\n", 1.0-mtl.Dissolve)
 			}
 			if mtl.Refraction > 1.0 {
-				fmt.Printf("    Refraction Index: %f\n", mtl.Refraction)
+				fmt.Fprintf(lib.Debug, "    Refraction Index: %f\n", mtl.Refraction)
 			}
 		}
 	}
@@ -229,8 +233,18 @@ func LoadMTL(mtlPath string, debug bool) (*MaterialLibrary, error) {
 	return lib, nil
 }
 
+// Loads a texture map, falling back to the missing texture pattern when the image can't be loaded.
+func (lib *MaterialLibrary) imageTexture(filename string) hittable.Texture {
+	tex, err := hittable.NewImageTexture(filename)
+	if err != nil {
+		lib.Warnings = append(lib.Warnings, fmt.Errorf("%w, using the missing texture pattern", err))
+		return hittable.NewMissingTexture()
+	}
+	return tex
+}
+
 // ConvertToRaytracerMaterial converts an MTL material to a raytracer material
-func ConvertToRaytracerMaterial(mtl *MtlMaterial) hittable.Material {
+func (lib *MaterialLibrary) ConvertToRaytracerMaterial(mtl *MtlMaterial) hittable.Material {
 	// First, handle special case materials
 
 	// 1. Handle completely transparent or refractive materials (glass, water, etc.)
@@ -252,10 +266,10 @@ func ConvertToRaytracerMaterial(mtl *MtlMaterial) hittable.Material {
 	emissiveIntensity := mtl.Emission.X() + mtl.Emission.Y() + mtl.Emission.Z()
 	if emissiveIntensity > 0.1 {
 		if mtl.MapKd != "" {
-			tex := hittable.NewImageTexture(mtl.MapKd)
+			tex := lib.imageTexture(mtl.MapKd)
 			return hittable.NewDiffuseLightTextured(tex)
 		} else if mtl.MapKa != "" {
-			tex := hittable.NewImageTexture(mtl.MapKa)
+			tex := lib.imageTexture(mtl.MapKa)
 			return hittable.NewDiffuseLightTextured(tex)
 		}
 		return hittable.NewDiffuseLight(mtl.Emission)
@@ -302,10 +316,10 @@ func ConvertToRaytracerMaterial(mtl *MtlMaterial) hittable.Material {
 	case 0, 1, 2:
 		// For standard diffuse materials
 		if mtl.MapKd != "" {
-			return hittable.NewTexturedLambertian(hittable.NewImageTexture(mtl.MapKd))
+			return hittable.NewTexturedLambertian(lib.imageTexture(mtl.MapKd))
 		} else if mtl.MapKa != "" {
 			// Use ambient map as fallback
-			return hittable.NewTexturedLambertian(hittable.NewImageTexture(mtl.MapKa))
+			return hittable.NewTexturedLambertian(lib.imageTexture(mtl.MapKa))
 		}
 		return hittable.NewLambertian(mtl.Diffuse)
 
@@ -316,10 +330,10 @@ func ConvertToRaytracerMaterial(mtl *MtlMaterial) hittable.Material {
 	default:
 		// Fallback on diffuse
 		if mtl.MapKd != "" {
-			return hittable.NewTexturedLambertian(hittable.NewImageTexture(mtl.MapKd))
+			return hittable.NewTexturedLambertian(lib.imageTexture(mtl.MapKd))
 		} else if mtl.MapKa != "" {
 			// Use ambient map as fallback
-			return hittable.NewTexturedLambertian(hittable.NewImageTexture(mtl.MapKa))
+			return hittable.NewTexturedLambertian(lib.imageTexture(mtl.MapKa))
 		}
 		return hittable.NewLambertian(mtl.Diffuse)
 	}
@@ -2,8 +2,9 @@ package objLoader
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
@@ -18,7 +19,7 @@ import (
 type LoadObjOptions struct {
 	ScaleFactor     float64
 	FlipYZ          bool
-	Debug           bool
+	Debug           io.Writer // receives information about the model as it loads, nil for none
 	IgnoreNormals   bool
 	Center          bool
 	FlipFaces       bool
@@ -33,7 +34,7 @@ func DefaultLoadOptions() LoadObjOptions {
 	return LoadObjOptions{
 		ScaleFactor:     1.0,
 		FlipYZ:          false,
-		Debug:           true,
+		Debug:           os.Stdout,
 		IgnoreNormals:   false,
 		Center:          true,
 		FlipFaces:       false,
@@ -44,38 +45,55 @@ func DefaultLoadOptions() LoadObjOptions {
 	}
 }
 
-func fixIndex(i, length int) int {
+// ErrNoTriangles is returned for OBJ files without any faces.
+var ErrNoTriangles = errors.New("no triangles found")
+
+// ParseError reports a malformed line of an OBJ or MTL file.
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: line %d: %s", e.File, e.Line, e.Msg)
+}
+
+// Converts a 1-based or negative OBJ index into an index of a slice of the given length,
+// reporting whether it lies within the slice.
+func fixIndex(i, length int) (int, bool) {
 	if i < 0 {
 		i = length + i // Convert negative index
 	} else {
 		i = i - 1 // Convert 1-based to 0-based
 	}
-
-	// Safety check to avoid out-of-bounds access
-	if i < 0 || i >= length {
-		log.Printf("Warning: Index %d out of bounds (0-%d), clamping", i, length-1)
-		i = int(math.Max(0, math.Min(float64(i), float64(length-1))))
-	}
-
-	return i
+	return i, i >= 0 && i < length
 }
 
 // LoadObj loads a 3D model from an OBJ file with default options
-func LoadObj(filename string, mat hittable.Material) (hittable.Hittable, hittable.Hittable) {
+func LoadObj(filename string, mat hittable.Material) (hittable.Hittable, hittable.Hittable, []error, error) {
 	options := DefaultLoadOptions()
 	options.DefaultMaterial = mat
 	return LoadObjWithOptions(filename, options)
 }
 
 // LoadObjWithOptions loads a 3D model from an OBJ file with custom options. If any triangles are emissive within the model,
-// their locations are returned in the second argument
-func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hittable, hittable.Hittable) {
-	fmt.Printf("Attempting to load %s . . .\n", filename)
+// their locations are returned in the second argument. Problems the model loads despite, such as an MTL file or texture
+// map which can't be read, are returned as warnings.
+// A missing file gives an error matching fs.ErrNotExist, a malformed line a *ParseError and a file without faces ErrNoTriangles.
+func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hittable, hittable.Hittable, []error, error) {
+	if options.Debug != nil {
+		fmt.Fprintf(options.Debug, "Attempting to load %s . . .\n", filename)
+	}
 	file, err := os.Open(filename)
 	if err != nil {
-		log.Fatalf("Could not open file %s: %v", filename, err)
+		return nil, nil, nil, fmt.Errorf("could not open OBJ file: %w", err)
 	}
 	defer file.Close()
+	// Reports a malformed line of the file.
+	malformed := func(line int, format string, args ...any) error {
+		return &ParseError{File: filename, Line: line, Msg: fmt.Sprintf(format, args...)}
+	}
 
 	// Store raw vertices separately for manipulation
 	var rawVertices []*vec.Vec3
@@ -91,6 +109,7 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 
 	// Material handling variables
 	var mtlLib *MaterialLibrary
+	var warnings []error
 	var currentMaterial hittable.Material = options.DefaultMaterial
 	mtlFilename := ""
 
@@ -123,15 +142,17 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 		// Load MTL file if found
 		if mtlFilename != "" {
 			mtlPath := filepath.Join(filepath.Dir(filename), mtlFilename)
-			if options.Debug {
-				fmt.Printf("Loading MTL file: %s\n", mtlPath)
+			if options.Debug != nil {
+				fmt.Fprintf(options.Debug, "Loading MTL file: %s\n", mtlPath)
 			}
 
 			var err error
 			mtlLib, err = LoadMTL(mtlPath, options.Debug)
 			if err != nil {
-				log.Printf("Warning: Could not load MTL file: %v", err)
-				// Continue with default material
+				// continue with the default material
+				warnings = append(warnings, err)
+			} else {
+				warnings = append(warnings, mtlLib.Warnings...)
 			}
 		}
 
@@ -153,18 +174,20 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 		if len(parts) == 0 {
 			continue
 		}
-		if parts[0] == "vt" { // Texture coordinate
-			if len(parts) < 3 {
-				log.Printf("Line %d: Malformed texture coordinate, expected at least 2 coordinates: %s", lineNum, line)
-				continue
+		if parts[0] == "vt" { // Texture coordinate, v and w are optional
+			if len(parts) < 2 {
+				return nil, nil, nil, malformed(lineNum, "malformed texture coordinate, expected at least 1 coordinate: %s", line)
 			}
 
 			u, errU := strconv.ParseFloat(parts[1], 64)
-			v, errV := strconv.ParseFloat(parts[2], 64)
+			var v float64
+			var errV error
+			if len(parts) > 2 {
+				v, errV = strconv.ParseFloat(parts[2], 64)
+			}
 
 			if errU != nil || errV != nil {
-				log.Printf("Line %d: Invalid texture coordinates: %s", lineNum, line)
-				continue
+				return nil, nil, nil, malformed(lineNum, "invalid texture coordinates: %s", line)
 			}
 
 			// In OBJ files, v coordinate is often flipped (1-v), but we flip in the value function for backwards compatibility
@@ -172,8 +195,7 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 		}
 		if parts[0] == "v" { // Vertex
 			if len(parts) < 4 {
-				log.Printf("Line %d: Malformed vertex, expected at least 3 coordinates: %s", lineNum, line)
-				continue
+				return nil, nil, nil, malformed(lineNum, "malformed vertex, expected at least 3 coordinates: %s", line)
 			}
 
 			x, errX := strconv.ParseFloat(parts[1], 64)
@@ -181,8 +203,7 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 			z, errZ := strconv.ParseFloat(parts[3], 64)
 
 			if errX != nil || errY != nil || errZ != nil {
-				log.Printf("Line %d: Invalid vertex coordinates: %s", lineNum, line)
-				continue
+				return nil, nil, nil, malformed(lineNum, "invalid vertex coordinates: %s", line)
 			}
 
 			// Apply scale but store this raw value
@@ -220,19 +241,19 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 	lineNum = 0
 
 	// Debug info about model bounds
-	if options.Debug {
-		fmt.Printf("=== OBJ MODEL DIMENSIONS ===\n")
-		fmt.Printf("Min bounds: [%f, %f, %f]\n", minBounds[0], minBounds[1], minBounds[2])
-		fmt.Printf("Max bounds: [%f, %f, %f]\n", maxBounds[0], maxBounds[1], maxBounds[2])
-		fmt.Printf("Center: [%f, %f, %f]\n", center.X(), center.Y(), center.Z())
+	if options.Debug != nil {
+		fmt.Fprintf(options.Debug, "=== OBJ MODEL DIMENSIONS ===\n")
+		fmt.Fprintf(options.Debug, "Min bounds: [%f, %f, %f]\n", minBounds[0], minBounds[1], minBounds[2])
+		fmt.Fprintf(options.Debug, "Max bounds: [%f, %f, %f]\n", maxBounds[0], maxBounds[1], maxBounds[2])
+		fmt.Fprintf(options.Debug, "Center: [%f, %f, %f]\n", center.X(), center.Y(), center.Z())
 
 		width := maxBounds[0] - minBounds[0]
 		height := maxBounds[1] - minBounds[1]
 		depth := maxBounds[2] - minBounds[2]
-		fmt.Printf("Dimensions: width=%f, height=%f, depth=%f\n", width, height, depth)
+		fmt.Fprintf(options.Debug, "Dimensions: width=%f, height=%f, depth=%f\n", width, height, depth)
 
 		diag := math.Sqrt(width*width + height*height + depth*depth)
-		fmt.Printf("Diagonal length: %f\n", diag)
+		fmt.Fprintf(options.Debug, "Diagonal length: %f\n", diag)
 	}
 
 	// Process vertices with centering if requested
@@ -251,7 +272,7 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 	}
 
 	// Verify centering worked
-	if options.Debug && options.Center {
+	if options.Debug != nil && options.Center {
 		// Calculate new bounds
 		newMinBounds := [3]float64{math.MaxFloat64, math.MaxFloat64, math.MaxFloat64}
 		newMaxBounds := [3]float64{-math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64}
@@ -271,13 +292,13 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 			(newMinBounds[2]+newMaxBounds[2])/2,
 		)
 
-		fmt.Printf("=== AFTER CENTERING ===\n")
-		fmt.Printf("New min bounds: [%f, %f, %f]\n", newMinBounds[0], newMinBounds[1], newMinBounds[2])
-		fmt.Printf("New max bounds: [%f, %f, %f]\n", newMaxBounds[0], newMaxBounds[1], newMaxBounds[2])
-		fmt.Printf("New center: [%f, %f, %f]\n", newCenter.X(), newCenter.Y(), newCenter.Z())
+		fmt.Fprintf(options.Debug, "=== AFTER CENTERING ===\n")
+		fmt.Fprintf(options.Debug, "New min bounds: [%f, %f, %f]\n", newMinBounds[0], newMinBounds[1], newMinBounds[2])
+		fmt.Fprintf(options.Debug, "New max bounds: [%f, %f, %f]\n", newMaxBounds[0], newMaxBounds[1], newMaxBounds[2])
+		fmt.Fprintf(options.Debug, "New center: [%f, %f, %f]\n", newCenter.X(), newCenter.Y(), newCenter.Z())
 
 		if options.Position.X() != 0 || options.Position.Y() != 0 || options.Position.Z() != 0 {
-			fmt.Printf("Shifted to requested position: [%f, %f, %f]\n",
+			fmt.Fprintf(options.Debug, "Shifted to requested position: [%f, %f, %f]\n",
 				options.Position.X(), options.Position.Y(), options.Position.Z())
 		}
 	}
@@ -298,7 +319,7 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 		switch parts[0] {
 		case "vn": // Normal
 			if len(parts) < 4 {
-				continue
+				return nil, nil, nil, malformed(lineNum, "malformed normal, expected 3 coordinates: %s", line)
 			}
 
 			nx, errX := strconv.ParseFloat(parts[1], 64)
@@ -306,7 +327,7 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 			nz, errZ := strconv.ParseFloat(parts[3], 64)
 
 			if errX != nil || errY != nil || errZ != nil {
-				continue
+				return nil, nil, nil, malformed(lineNum, "invalid normal coordinates: %s", line)
 			}
 
 			if options.FlipYZ {
@@ -330,12 +351,12 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 			materialName := parts[1]
 			if material, exists := mtlLib.Materials[materialName]; exists {
 				currentMaterial = material.Material
-				if options.Debug {
-					fmt.Printf("Switched to material: %s\n", materialName)
+				if options.Debug != nil {
+					fmt.Fprintf(options.Debug, "Switched to material: %s\n", materialName)
 				}
 			} else {
-				if options.Debug {
-					fmt.Printf("Material not found: %s, using default\n", materialName)
+				if options.Debug != nil {
+					fmt.Fprintf(options.Debug, "Material not found: %s, using default\n", materialName)
 				}
 				currentMaterial = options.DefaultMaterial
 			}
@@ -358,35 +379,37 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 				if len(indices) > 0 && indices[0] != "" {
 					idx, err := strconv.Atoi(indices[0])
 					if err != nil {
-						continue
+						return nil, nil, nil, malformed(lineNum, "invalid vertex index %q", indices[0])
 					}
-
-					vIdx := fixIndex(idx, len(vertices))
-					if vIdx >= 0 && vIdx < len(vertices) {
-						faceVertices = append(faceVertices, vertices[vIdx])
-					} else {
-						continue
+					vIdx, ok := fixIndex(idx, len(vertices))
+					if !ok {
+						return nil, nil, nil, malformed(lineNum, "vertex index %d out of range, %d vertices are defined", idx, len(vertices))
 					}
+					faceVertices = append(faceVertices, vertices[vIdx])
 				}
 				// Texture coordinate index
 				if len(indices) > 1 && indices[1] != "" && len(texCoords) > 0 {
 					idx, err := strconv.Atoi(indices[1])
-					if err == nil {
-						tcIdx := fixIndex(idx, len(texCoords))
-						if tcIdx >= 0 && tcIdx < len(texCoords) {
-							faceTexCoords = append(faceTexCoords, texCoords[tcIdx])
-						}
+					if err != nil {
+						return nil, nil, nil, malformed(lineNum, "invalid texture coordinate index %q", indices[1])
+					}
+					tcIdx, ok := fixIndex(idx, len(texCoords))
+					if !ok {
+						return nil, nil, nil, malformed(lineNum, "texture coordinate index %d out of range, %d are defined", idx, len(texCoords))
 					}
+					faceTexCoords = append(faceTexCoords, texCoords[tcIdx])
 				}
 				// Normal index
 				if len(indices) > 2 && indices[2] != "" && len(normals) > 0 && !options.IgnoreNormals {
 					idx, err := strconv.Atoi(indices[2])
-					if err == nil {
-						nIdx := fixIndex(idx, len(normals))
-						if nIdx >= 0 && nIdx < len(normals) {
-							faceNormals = append(faceNormals, normals[nIdx])
-						}
+					if err != nil {
+						return nil, nil, nil, malformed(lineNum, "invalid normal index %q", indices[2])
+					}
+					nIdx, ok := fixIndex(idx, len(normals))
+					if !ok {
+						return nil, nil, nil, malformed(lineNum, "normal index %d out of range, %d normals are defined", idx, len(normals))
 					}
+					faceNormals = append(faceNormals, normals[nIdx])
 				}
 			}
 
@@ -470,20 +493,20 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatalf("Error reading file %s: %v", filename, err)
+		return nil, nil, nil, fmt.Errorf("error reading %s: %w", filename, err)
 	}
 
-	if options.Debug {
-		fmt.Printf("=== MODEL SUMMARY ===\n")
-		fmt.Printf("Loaded %d vertices, %d normals, %d triangles\n",
+	if options.Debug != nil {
+		fmt.Fprintf(options.Debug, "=== MODEL SUMMARY ===\n")
+		fmt.Fprintf(options.Debug, "Loaded %d vertices, %d normals, %d triangles\n",
 			len(vertices), len(normals), len(triangles))
 
 		if mtlLib != nil {
-			fmt.Printf("Used %d materials from MTL file\n", len(mtlLib.Materials))
+			fmt.Fprintf(options.Debug, "Used %d materials from MTL file\n", len(mtlLib.Materials))
 		}
 	}
 	if len(triangles) == 0 {
-		log.Fatalf("No triangles found in OBJ file")
+		return nil, nil, nil, fmt.Errorf("%s: %w", filename, ErrNoTriangles)
 	}
 	// Create a hittable list and add all triangles
 	model := hittable.NewHittableList(len(triangles))
@@ -512,15 +535,15 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 	bvh := hittable.BuildBVH(model)
 
 	// Final bbox check to verify positioning
-	if options.Debug {
-		fmt.Printf("%d Light sources found\n", i)
+	if options.Debug != nil {
+		fmt.Fprintf(options.Debug, "%d Light sources found\n", i)
 
 		bbox := bvh.BBox()
 		if bbox != nil {
-			fmt.Printf("=== FINAL BVH BOUNDS ===\n")
-			fmt.Printf("X: %f to %f\n", bbox.AxisInterval(0).Min, bbox.AxisInterval(0).Max)
-			fmt.Printf("Y: %f to %f\n", bbox.AxisInterval(1).Min, bbox.AxisInterval(1).Max)
-			fmt.Printf("Z: %f to %f\n", bbox.AxisInterval(2).Min, bbox.AxisInterval(2).Max)
+			fmt.Fprintf(options.Debug, "=== FINAL BVH BOUNDS ===\n")
+			fmt.Fprintf(options.Debug, "X: %f to %f\n", bbox.AxisInterval(0).Min, bbox.AxisInterval(0).Max)
+			fmt.Fprintf(options.Debug, "Y: %f to %f\n", bbox.AxisInterval(1).Min, bbox.AxisInterval(1).Max)
+			fmt.Fprintf(options.Debug, "Z: %f to %f\n", bbox.AxisInterval(2).Min, bbox.AxisInterval(2).Max)
 
 			// Calculate bbox center
 			bboxCenter := vec.New(
@@ -528,11 +551,11 @@ func LoadObjWithOptions(filename string, options LoadObjOptions) (hittable.Hitta
 				(bbox.AxisInterval(1).Min+bbox.AxisInterval(1).Max)/2,
 				(bbox.AxisInterval(2).Min+bbox.AxisInterval(2).Max)/2,
 			)
-			fmt.Printf("BVH center: [%f, %f, %f]\n", bboxCenter.X(), bboxCenter.Y(), bboxCenter.Z())
+			fmt.Fprintf(options.Debug, "BVH center: [%f, %f, %f]\n", bboxCenter.X(), bboxCenter.Y(), bboxCenter.Z())
 		} else {
-			fmt.Printf("Warning: BVH returned nil bbox\n")
+			fmt.Fprintf(options.Debug, "Warning: BVH returned nil bbox\n")
 		}
 	}
 
-	return bvh, lights
+	return bvh, lights, warnings, nil
 }
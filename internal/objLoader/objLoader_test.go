@@ -0,0 +1,93 @@
+package objLoader_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/objLoader"
+)
+
+// Writes an OBJ file to a temporary directory and loads it quietly.
+func load(t *testing.T, contents string) (hittable.Hittable, error) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "model.obj")
+	if err := os.WriteFile(filename, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := objLoader.DefaultLoadOptions()
+	opts.Debug = nil
+	model, _, _, err := objLoader.LoadObjWithOptions(filename, opts)
+	return model, err
+}
+
+func TestLoadObj(t *testing.T) {
+	model, err := load(t, "v 0 0 0\nv 1 0 0\nv 0 1 0\nv 1 1 0\nf 1 2 3\nf -3 -1 -2\n")
+	if err != nil {
+		t.Fatalf("Error loading model: %v", err)
+	}
+	if model.BBox() == nil {
+		t.Errorf("Expected the model to have a bounding box")
+	}
+
+	// texture coordinates may leave out v, and w
+	if _, err := load(t, "v 0 0 0\nv 1 0 0\nv 0 1 0\nvt 0\nvt 0.5\nvt 1 0 0\nf 1/1 2/2 3/3\n"); err != nil {
+		t.Errorf("Error loading a model with 1D texture coordinates: %v", err)
+	}
+}
+
+func TestLoadObjErrors(t *testing.T) {
+	opts := objLoader.DefaultLoadOptions()
+	opts.Debug = nil
+	if _, _, _, err := objLoader.LoadObjWithOptions("missing.obj", opts); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not found error, but got %v", err)
+	}
+
+	if _, err := load(t, "# only a comment\nv 0 0 0\n"); !errors.Is(err, objLoader.ErrNoTriangles) {
+		t.Errorf("Expected ErrNoTriangles, but got %v", err)
+	}
+
+	malformed := map[string]int{
+		"v 0 0 0\nv 1 0\n":                     2,
+		"v 0 0 0\nv 1 0 0\nvt a b\n":           3,
+		"v 0 0 0\nvt\n":                        2,
+		"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 4\n": 4,
+		"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 x 3\n": 4,
+	}
+	for contents, line := range malformed {
+		_, err := load(t, contents)
+		var parseErr *objLoader.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Expected a parse error for %q, but got %v", contents, err)
+		} else if parseErr.Line != line {
+			t.Errorf("Expected a parse error on line %d of %q, but got line %d", line, contents, parseErr.Line)
+		}
+	}
+}
+
+func TestLoadObjWarnings(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"missing.obj":  "mtllib missing.mtl\nv 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n",
+		"textured.obj": "mtllib textured.mtl\nusemtl paint\nv 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n",
+		"textured.mtl": "newmtl paint\nKd 1 1 1\nmap_Kd " + filepath.Join(dir, "missing.png") + "\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := objLoader.DefaultLoadOptions()
+	opts.Debug = nil
+	for _, name := range []string{"missing.obj", "textured.obj"} {
+		_, _, warnings, err := objLoader.LoadObjWithOptions(filepath.Join(dir, name), opts)
+		if err != nil {
+			t.Errorf("%s: expected the model to load, but got %v", name, err)
+		} else if len(warnings) != 1 || !errors.Is(warnings[0], fs.ErrNotExist) {
+			t.Errorf("%s: expected a warning about the missing file, but got %v", name, warnings)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package postfx
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+)
+
+// Bloom spreads light above a brightness threshold into the surrounding pixels, imitating glare in a lens.
+type Bloom struct {
+	Threshold float64 // luminance above which pixels start to glow
+	Strength  float64 // amount of the blurred highlights added back to the image
+	Radius    float64 // standard deviation of the glow as a fraction of the image width
+}
+
+func (b Bloom) Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	p := load(fb)
+	bright := make([][3]float64, len(p.rgb))
+	for i, c := range p.rgb {
+		l := luminance(c)
+		if l <= b.Threshold {
+			continue
+		}
+		// keep only the part of the color above the threshold, preserving its hue
+		s := (l - b.Threshold) / l
+		bright[i] = [3]float64{c[0] * s, c[1] * s, c[2] * s}
+	}
+	glow := gaussianBlur(bright, p.width, p.height, b.Radius*float64(p.width))
+
+	out := make([][3]float64, len(p.rgb))
+	for i, c := range p.rgb {
+		for k := range 3 {
+			out[i][k] = c[k] + b.Strength*glow[i][k]
+		}
+	}
+	return p.store(out)
+}
+
+// Vignette darkens the image towards its corners.
+type Vignette struct {
+	Strength float64 // how much the corners are darkened, 1 makes them black
+	Falloff  float64 // exponent of the falloff, larger values keep more of the center bright
+}
+
+func (v Vignette) Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	p := load(fb)
+	cx, cy := float64(p.width)/2, float64(p.height)/2
+	corner := math.Hypot(cx, cy)
+	out := make([][3]float64, len(p.rgb))
+	for y := range p.height {
+		for x := range p.width {
+			i := y*p.width + x
+			d := math.Hypot(float64(x)+.5-cx, float64(y)+.5-cy) / corner
+			scale := max(0, 1-v.Strength*math.Pow(d, v.Falloff))
+			out[i] = [3]float64{p.rgb[i][0] * scale, p.rgb[i][1] * scale, p.rgb[i][2] * scale}
+		}
+	}
+	return p.store(out)
+}
+
+// ChromaticAberration imitates a lens which focuses colors differently, fringing edges away from the center.
+type ChromaticAberration struct {
+	Strength float64 // how far red is scaled outwards and blue inwards, as a fraction of the distance from the center
+}
+
+func (ca ChromaticAberration) Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	p := load(fb)
+	cx, cy := float64(p.width-1)/2, float64(p.height-1)/2
+	out := make([][3]float64, len(p.rgb))
+	for y := range p.height {
+		for x := range p.width {
+			i := y*p.width + x
+			dx, dy := float64(x)-cx, float64(y)-cy
+			// red is magnified the least, so it is sampled closer to the center and appears pushed outwards
+			red := 1 - ca.Strength
+			blue := 1 + ca.Strength
+			out[i] = [3]float64{
+				p.sample(cx+dx*red, cy+dy*red, 0),
+				p.rgb[i][1],
+				p.sample(cx+dx*blue, cy+dy*blue, 2),
+			}
+		}
+	}
+	return p.store(out)
+}
+
+// Grain adds monochrome film grain whose amplitude follows the brightness of the image.
+type Grain struct {
+	Strength float64 // standard deviation of the grain relative to the pixel's brightness
+	Seed     uint64  // the same seed always produces the same grain
+}
+
+func (g Grain) Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	p := load(fb)
+	rng := rand.New(rand.NewPCG(g.Seed, 0))
+	out := make([][3]float64, len(p.rgb))
+	for i, c := range p.rgb {
+		scale := max(0, 1+g.Strength*rng.NormFloat64())
+		out[i] = [3]float64{c[0] * scale, c[1] * scale, c[2] * scale}
+	}
+	return p.store(out)
+}
+
+// Sharpen applies an unsharp mask, amplifying the difference between the image and a blurred copy.
+type Sharpen struct {
+	Strength float64 // amount of detail added back, 0 leaves the image unchanged
+	Radius   float64 // standard deviation of the blur in pixels, the size of the details which are enhanced
+}
+
+func (s Sharpen) Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	p := load(fb)
+	blurred := gaussianBlur(p.rgb, p.width, p.height, s.Radius)
+	out := make([][3]float64, len(p.rgb))
+	for i, c := range p.rgb {
+		for k := range 3 {
+			out[i][k] = max(0, c[k]+s.Strength*(c[k]-blurred[i][k]))
+		}
+	}
+	return p.store(out)
+}
@@ -0,0 +1,167 @@
+package postfx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+)
+
+// LUT grades colors with a 3D lookup table. Like the grading LUTs it is made for, the table is applied to
+// sRGB encoded values, so radiance outside of the table's domain is clipped; it is best placed last in a chain
+// and paired with a tone mapping operator that maps the image into [0, 1].
+type LUT struct {
+	size      int
+	domainMin [3]float64
+	domainMax [3]float64
+	table     [][3]float64 // size^3 entries with red changing fastest
+}
+
+// Loads a 3D LUT in the Adobe/Resolve .cube format.
+func LoadCube(filename string) (*LUT, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	lut, err := ParseCube(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return lut, nil
+}
+
+// Parses a 3D LUT in the .cube format.
+func ParseCube(r io.Reader) (*LUT, error) {
+	lut := &LUT{domainMax: [3]float64{1, 1, 1}}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "TITLE":
+		case "LUT_1D_SIZE":
+			return nil, fmt.Errorf("line %d: 1D LUTs are not supported", line)
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: expected LUT_3D_SIZE N", line)
+			}
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 2 {
+				return nil, fmt.Errorf("line %d: invalid LUT size %q", line, fields[1])
+			}
+			lut.size = size
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			v, err := parseTriple(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				lut.domainMin = v
+			} else {
+				lut.domainMax = v
+			}
+		default:
+			if lut.size == 0 {
+				return nil, fmt.Errorf("line %d: table data before LUT_3D_SIZE", line)
+			}
+			v, err := parseTriple(fields)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			lut.table = append(lut.table, v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lut.size == 0 {
+		return nil, fmt.Errorf("missing LUT_3D_SIZE")
+	}
+	if n := lut.size * lut.size * lut.size; len(lut.table) != n {
+		return nil, fmt.Errorf("expected %d table entries, but got %d", n, len(lut.table))
+	}
+	for k := range 3 {
+		if lut.domainMin[k] >= lut.domainMax[k] {
+			return nil, fmt.Errorf("DOMAIN_MIN %v must be below DOMAIN_MAX %v", lut.domainMin, lut.domainMax)
+		}
+	}
+	return lut, nil
+}
+
+func parseTriple(fields []string) ([3]float64, error) {
+	var v [3]float64
+	if len(fields) != 3 {
+		return v, fmt.Errorf("expected 3 values, but got %d", len(fields))
+	}
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid number %q", f)
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+func (l *LUT) Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	p := load(fb)
+	out := make([][3]float64, len(p.rgb))
+	for i, c := range p.rgb {
+		var encoded [3]float64
+		for k := range 3 {
+			encoded[k] = tonemap.SRGB(min(max(c[k], 0), 1))
+		}
+		graded := l.lookup(encoded)
+		for k := range 3 {
+			out[i][k] = tonemap.InverseSRGB(min(max(graded[k], 0), 1))
+		}
+	}
+	return p.store(out)
+}
+
+// Looks up a color with trilinear interpolation between the table's entries.
+func (l *LUT) lookup(c [3]float64) [3]float64 {
+	var index [3]int
+	var frac [3]float64
+	for k := range 3 {
+		t := (c[k] - l.domainMin[k]) / (l.domainMax[k] - l.domainMin[k])
+		if math.IsNaN(t) {
+			// min and max pass NaN through, and it would index outside of the table
+			t = 0
+		}
+		t = min(max(t, 0), 1) * float64(l.size-1)
+		index[k] = min(int(t), l.size-2)
+		frac[k] = t - float64(index[k])
+	}
+
+	var result [3]float64
+	for corner := range 8 {
+		weight := 1.0
+		var at [3]int
+		for k := range 3 {
+			if corner>>k&1 == 1 {
+				at[k] = index[k] + 1
+				weight *= frac[k]
+			} else {
+				at[k] = index[k]
+				weight *= 1 - frac[k]
+			}
+		}
+		entry := l.table[at[0]+at[1]*l.size+at[2]*l.size*l.size]
+		for k := range 3 {
+			result[k] += weight * entry[k]
+		}
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package postfx
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Effect is a post-processing step which transforms the linear radiance of a rendered image.
+type Effect interface {
+	Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer
+}
+
+// Chain applies its effects in order.
+type Chain []Effect
+
+func (c Chain) Apply(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	for _, effect := range c {
+		fb = effect.Apply(fb)
+	}
+	return fb
+}
+
+// Returns the names of the available effects.
+func Effects() []string {
+	return []string{"bloom", "vignette", "chromatic", "grain", "sharpen", "lut"}
+}
+
+// Parses a chain of effects separated by semicolons, each with optional comma separated parameters,
+// e.g. "bloom:threshold=1.5,strength=0.2;vignette;lut:file=grade.cube".
+func Parse(spec string) (Chain, error) {
+	var chain Chain
+	for _, step := range strings.Split(spec, ";") {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+		name, args, _ := strings.Cut(step, ":")
+		params := map[string]string{}
+		for _, arg := range strings.Split(args, ",") {
+			if strings.TrimSpace(arg) == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s: expected key=value, but got %q", name, arg)
+			}
+			params[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		effect, err := New(strings.TrimSpace(name), params)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, effect)
+	}
+	return chain, nil
+}
+
+// Creates an effect from its name and parameters, unset parameters keep their defaults.
+func New(name string, params map[string]string) (Effect, error) {
+	var effect Effect
+	var err error
+	switch strings.ToLower(name) {
+	case "bloom":
+		b := Bloom{Threshold: 1, Strength: .1, Radius: .01}
+		err = setParams(params, map[string]*float64{"threshold": &b.Threshold, "strength": &b.Strength, "radius": &b.Radius}, nil)
+		effect = b
+	case "vignette":
+		v := Vignette{Strength: .5, Falloff: 2}
+		err = setParams(params, map[string]*float64{"strength": &v.Strength, "falloff": &v.Falloff}, nil)
+		effect = v
+	case "chromatic":
+		c := ChromaticAberration{Strength: .005}
+		err = setParams(params, map[string]*float64{"strength": &c.Strength}, nil)
+		effect = c
+	case "grain":
+		g := Grain{Strength: .05}
+		seed := 1.0
+		err = setParams(params, map[string]*float64{"strength": &g.Strength, "seed": &seed}, nil)
+		g.Seed = uint64(seed)
+		effect = g
+	case "sharpen":
+		s := Sharpen{Strength: .5, Radius: 1}
+		err = setParams(params, map[string]*float64{"strength": &s.Strength, "radius": &s.Radius}, nil)
+		effect = s
+	case "lut":
+		file := ""
+		if err = setParams(params, nil, map[string]*string{"file": &file}); err != nil {
+			break
+		}
+		if file == "" {
+			return nil, fmt.Errorf("lut: missing file parameter")
+		}
+		effect, err = LoadCube(file)
+	default:
+		return nil, fmt.Errorf("unknown post-processing effect %q (supported: %s)", name, strings.Join(Effects(), ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return effect, nil
+}
+
+// Assigns parameters to the numeric or string fields they name.
+func setParams(params map[string]string, numbers map[string]*float64, strs map[string]*string) error {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		value := params[key]
+		if field, ok := numbers[strings.ToLower(key)]; ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for %s", value, key)
+			}
+			*field = v
+		} else if field, ok := strs[strings.ToLower(key)]; ok {
+			*field = value
+		} else {
+			return fmt.Errorf("unknown parameter %q", key)
+		}
+	}
+	return nil
+}
+
+// A framebuffer unpacked into averaged colors, which is easier to filter.
+type planes struct {
+	width   int
+	height  int
+	rgb     [][3]float64
+	samples []int
+}
+
+func load(fb *framebuffer.Framebuffer) *planes {
+	p := &planes{width: fb.Width, height: fb.Height, rgb: make([][3]float64, fb.Width*fb.Height), samples: make([]int, fb.Width*fb.Height)}
+	for y := range fb.Height {
+		for x := range fb.Width {
+			i := y*fb.Width + x
+			c := fb.Color(x, y)
+			for k := range 3 {
+				if v := c.Get(k); !math.IsNaN(v) {
+					p.rgb[i][k] = v
+				}
+			}
+			p.samples[i] = fb.Samples(x, y)
+		}
+	}
+	return p
+}
+
+// Packs colors back into a framebuffer, keeping the sample counts of the original so unsampled pixels stay empty.
+func (p *planes) store(rgb [][3]float64) *framebuffer.Framebuffer {
+	fb := framebuffer.New(p.width, p.height)
+	for y := range p.height {
+		for x := range p.width {
+			i := y*p.width + x
+			if p.samples[i] == 0 {
+				continue
+			}
+			fb.Set(x, y, vec.New(rgb[i][0], rgb[i][1], rgb[i][2]), p.samples[i])
+		}
+	}
+	return fb
+}
+
+// Samples one channel at a fractional position with bilinear filtering, clamping to the edges of the image.
+func (p *planes) sample(x, y float64, channel int) float64 {
+	x = min(max(x, 0), float64(p.width-1))
+	y = min(max(y, 0), float64(p.height-1))
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, p.width-1), min(y0+1, p.height-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+	top := p.rgb[y0*p.width+x0][channel]*(1-fx) + p.rgb[y0*p.width+x1][channel]*fx
+	bottom := p.rgb[y1*p.width+x0][channel]*(1-fx) + p.rgb[y1*p.width+x1][channel]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// Blurs an image with a separable gaussian of the given standard deviation in pixels.
+func gaussianBlur(rgb [][3]float64, width, height int, sigma float64) [][3]float64 {
+	if sigma <= 0 {
+		return slices.Clone(rgb)
+	}
+	radius := int(math.Ceil(3 * sigma))
+	weights := make([]float64, 2*radius+1)
+	total := 0.0
+	for i := range weights {
+		d := float64(i - radius)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		total += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+
+	pass := func(src [][3]float64, horizontal bool) [][3]float64 {
+		dst := make([][3]float64, len(src))
+		for y := range height {
+			for x := range width {
+				var sum [3]float64
+				for i, w := range weights {
+					sx, sy := x, y
+					if horizontal {
+						sx = min(max(x+i-radius, 0), width-1)
+					} else {
+						sy = min(max(y+i-radius, 0), height-1)
+					}
+					c := src[sy*width+sx]
+					sum[0] += w * c[0]
+					sum[1] += w * c[1]
+					sum[2] += w * c[2]
+				}
+				dst[y*width+x] = sum
+			}
+		}
+		return dst
+	}
+	return pass(pass(rgb, true), false)
+}
+
+// Returns the Rec. 709 luminance of a linear color.
+func luminance(c [3]float64) float64 {
+	return 0.2126*c[0] + 0.7152*c[1] + 0.0722*c[2]
+}
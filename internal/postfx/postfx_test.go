@@ -0,0 +1,188 @@
+package postfx_test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/postfx"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Builds an image filled with a single color.
+func flatImage(width, height int, c *vec.Vec3) *framebuffer.Framebuffer {
+	fb := framebuffer.New(width, height)
+	for y := range height {
+		for x := range width {
+			fb.Set(x, y, c, 1)
+		}
+	}
+	return fb
+}
+
+func near(a, b *vec.Vec3) bool {
+	return math.Abs(a.X()-b.X()) < 1e-6 && math.Abs(a.Y()-b.Y()) < 1e-6 && math.Abs(a.Z()-b.Z()) < 1e-6
+}
+
+func TestParse(t *testing.T) {
+	chain, err := postfx.Parse("bloom:threshold=2, strength=0.5; vignette ;sharpen:radius=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("Expected %d effects, but got %d", 3, len(chain))
+	}
+	exp := postfx.Bloom{Threshold: 2, Strength: .5, Radius: .01}
+	if chain[0] != exp {
+		t.Errorf("Expected %v, but got %v", exp, chain[0])
+	}
+
+	for _, spec := range []string{"blur", "bloom:size=2", "bloom:threshold=high", "vignette:strength", "lut"} {
+		if _, err := postfx.Parse(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestBloom(t *testing.T) {
+	dark := flatImage(16, 16, vec.New(.5, .5, .5))
+	if out := (postfx.Bloom{Threshold: 1, Strength: 1, Radius: .1}).Apply(dark); !near(out.Color(3, 3), vec.New(.5, .5, .5)) {
+		t.Errorf("Expected pixels below the threshold to be unchanged, but got %v", out.Color(3, 3))
+	}
+
+	bright := framebuffer.New(16, 16)
+	bright.Set(8, 8, vec.New(100, 100, 100), 1)
+	for y := range 16 {
+		for x := range 16 {
+			if x != 8 || y != 8 {
+				bright.Set(x, y, vec.Empty(), 1)
+			}
+		}
+	}
+	out := (postfx.Bloom{Threshold: 1, Strength: 1, Radius: .1}).Apply(bright)
+	if out.Color(10, 8).X() <= 0 {
+		t.Errorf("Expected the highlight to spread to its neighbours, but got %v", out.Color(10, 8))
+	}
+}
+
+func TestVignette(t *testing.T) {
+	out := (postfx.Vignette{Strength: 1, Falloff: 2}).Apply(flatImage(16, 16, vec.New(1, 1, 1)))
+	center, corner := out.Color(8, 8).X(), out.Color(0, 0).X()
+	if center < .99 || corner > .2 {
+		t.Errorf("Expected a bright center and dark corners, but got %v and %v", center, corner)
+	}
+}
+
+func TestChromaticAberration(t *testing.T) {
+	fb := framebuffer.New(17, 1)
+	for x := range 17 {
+		fb.Set(x, 0, vec.New(float64(x), float64(x), float64(x)), 1)
+	}
+	out := (postfx.ChromaticAberration{Strength: .1}).Apply(fb)
+	if c := out.Color(8, 0); !near(c, vec.New(8, 8, 8)) {
+		t.Errorf("Expected the center to be unchanged, but got %v", c)
+	}
+	if c := out.Color(14, 0); c.X() >= c.Y() || c.Z() <= c.Y() {
+		t.Errorf("Expected red and blue to be shifted in opposite directions, but got %v", c)
+	}
+}
+
+func TestGrain(t *testing.T) {
+	fb := flatImage(32, 32, vec.New(.5, .5, .5))
+	a := (postfx.Grain{Strength: .1, Seed: 7}).Apply(fb)
+	b := (postfx.Grain{Strength: .1, Seed: 7}).Apply(fb)
+	sum := 0.0
+	for y := range 32 {
+		for x := range 32 {
+			if !a.Color(x, y).Equals(b.Color(x, y)) {
+				t.Fatalf("Expected the same seed to produce the same grain at (%d, %d)", x, y)
+			}
+			sum += a.Color(x, y).X()
+		}
+	}
+	if mean := sum / (32 * 32); math.Abs(mean-.5) > .02 {
+		t.Errorf("Expected the grain to preserve the mean brightness of %v, but got %v", .5, mean)
+	}
+}
+
+func TestSharpen(t *testing.T) {
+	out := (postfx.Sharpen{Strength: 1, Radius: 1}).Apply(flatImage(8, 8, vec.New(.3, .3, .3)))
+	if c := out.Color(4, 4); !near(c, vec.New(.3, .3, .3)) {
+		t.Errorf("Expected a flat image to be unchanged, but got %v", c)
+	}
+
+	edge := framebuffer.New(8, 1)
+	for x := range 8 {
+		edge.Set(x, 0, vec.New(float64(x/4), float64(x/4), float64(x/4)), 1)
+	}
+	out = (postfx.Sharpen{Strength: 1, Radius: 1}).Apply(edge)
+	if out.Color(4, 0).X() <= 1 {
+		t.Errorf("Expected the bright side of an edge to overshoot, but got %v", out.Color(4, 0))
+	}
+}
+
+// Writes a cube LUT of the given size which maps every color through f.
+func cube(size int, f func(r, g, b float64) (float64, float64, float64)) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "TITLE \"test\"\n# comment\nLUT_3D_SIZE %d\n", size)
+	for b := range size {
+		for g := range size {
+			for r := range size {
+				s := float64(size - 1)
+				x, y, z := f(float64(r)/s, float64(g)/s, float64(b)/s)
+				fmt.Fprintf(sb, "%f %f %f\n", x, y, z)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestLUT(t *testing.T) {
+	identity, err := postfx.ParseCube(strings.NewReader(cube(5, func(r, g, b float64) (float64, float64, float64) { return r, g, b })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := vec.New(.2, .5, .8)
+	if out := identity.Apply(flatImage(1, 1, c)).Color(0, 0); !near(out, c) {
+		t.Errorf("Expected %v, but got %v", c, out)
+	}
+
+	swap, err := postfx.ParseCube(strings.NewReader(cube(2, func(r, g, b float64) (float64, float64, float64) { return b, g, r })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := swap.Apply(flatImage(1, 1, vec.New(1, 0, 0))).Color(0, 0); !near(out, vec.New(0, 0, 1)) {
+		t.Errorf("Expected %v, but got %v", vec.New(0, 0, 1), out)
+	}
+
+	for _, bad := range []string{"0 0 0\n", "LUT_3D_SIZE 2\n0 0 0\n", "LUT_1D_SIZE 4\n", "LUT_3D_SIZE 2\n0 0\n",
+		"DOMAIN_MAX 1 0 1\n" + cube(2, func(r, g, b float64) (float64, float64, float64) { return r, g, b }),
+		"DOMAIN_MIN 0 2 0\n" + cube(2, func(r, g, b float64) (float64, float64, float64) { return r, g, b })} {
+		if _, err := postfx.ParseCube(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+
+	// NaN radiance grades like black
+	if out := identity.Apply(flatImage(1, 1, vec.New(math.NaN(), .5, .5))).Color(0, 0); !near(out, vec.New(0, .5, .5)) {
+		t.Errorf("Expected %v, but got %v", vec.New(0, .5, .5), out)
+	}
+}
+
+func TestUnsampledPixelsStayEmpty(t *testing.T) {
+	fb := framebuffer.New(4, 4)
+	fb.Set(1, 1, vec.New(5, 5, 5), 3)
+	chain, err := postfx.Parse("bloom;vignette;chromatic;grain;sharpen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := chain.Apply(fb)
+	if out.Samples(1, 1) != 3 {
+		t.Errorf("Expected %d, but got %d", 3, out.Samples(1, 1))
+	}
+	if out.Samples(0, 0) != 0 {
+		t.Errorf("Expected %d, but got %d", 0, out.Samples(0, 0))
+	}
+}
@@ -1,91 +1,185 @@
 package progress
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"time"
+)
 
-	tea "github.com/charmbracelet/bubbletea"
+// Reporter follows the progress of a render, which is split into a known number of units of work such as tiles.
+// A reporter can follow several renders one after another. Its methods are never called concurrently.
+type Reporter interface {
+	// Start is called once before any work is done.
+	Start(total int)
+	// Advance is called as n more units of work are completed.
+	Advance(n int)
+	// Finish is called once the render ends, which may be before all of the work is done.
+	// It returns once the report is complete.
+	Finish()
+}
 
-	"github.com/charmbracelet/bubbles/progress"
-	"github.com/charmbracelet/bubbles/stopwatch"
-)
+// Interruptible is implemented by reporters the user can stop a render from, such as the progress bar.
+type Interruptible interface {
+	// OnInterrupt sets the function called when the user asks to stop the render.
+	OnInterrupt(stop func())
+}
 
-const (
-	padding  = 16
-	maxWidth = 160
-)
+// Silent reports nothing.
+type Silent struct{}
+
+func (Silent) Start(int)   {}
+func (Silent) Advance(int) {}
+func (Silent) Finish()     {}
 
-// Model to represent the state of the progress bar and stopwatch.
-type model struct {
-	totalItems  int
-	currentItem int
-	stopwatch   stopwatch.Model
-	progressbar progress.Model
-}
-
-// initialize the stopwatch
-func (m model) Init() tea.Cmd {
-	return m.stopwatch.Init()
-}
-
-// Updates the progress bar and stopwatch.
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		if msg.String() == "q" {
-			return m, tea.Quit
-		}
-
-	case tea.WindowSizeMsg:
-		// Resize the progress bar dynamically
-		m.progressbar.PercentageStyle.PaddingLeft(padding)
-		m.progressbar.Width = msg.Width - padding - 4
-		if m.progressbar.Width > maxWidth {
-			m.progressbar.Width = maxWidth
-		}
-		return m, nil
-	case int:
-		// Update progress
-		m.currentItem += msg
-
-		if m.currentItem >= m.totalItems {
-			return m, tea.Quit
-		}
-		return m, nil
-	case progress.FrameMsg:
-		progressModel, _ := m.progressbar.Update(msg)
-		m.progressbar = progressModel.(progress.Model)
-		return m, nil
+// Tracks the amount of work done and the time taken to estimate when a render will finish.
+type tracker struct {
+	total, done int
+	start       time.Time
+}
+
+func (t *tracker) reset(total int) {
+	t.total, t.done, t.start = total, 0, time.Now()
+}
+
+// Returns the fraction of the work done, between 0 and 1.
+func (t *tracker) fraction() float64 {
+	if t.total <= 0 {
+		return 1
 	}
-	var cmd tea.Cmd
-	m.stopwatch, cmd = m.stopwatch.Update(msg)
-	return m, cmd
+	return min(float64(t.done)/float64(t.total), 1)
 }
 
-// Returns a string representation of the progress bar and stopwatch.
-func (m model) View() string {
-	if m.currentItem >= m.totalItems {
-		// Print summary
-		m.stopwatch.Stop()
-		return fmt.Sprintf("Done after %s\n", m.stopwatch.View())
+// Estimates the time remaining from the average rate of progress so far, false if nothing has been done yet.
+func (t *tracker) remaining() (time.Duration, bool) {
+	if t.done <= 0 {
+		return 0, false
 	}
+	left := max(t.total-t.done, 0)
+	return time.Duration(float64(time.Since(t.start)) * float64(left) / float64(t.done)), true
+}
 
-	// Calculate progress percentage
-	percent := float64(m.currentItem) / float64(m.totalItems)
-	out := fmt.Sprintf("\n %s Time Elapsed: %s\n", m.progressbar.ViewAs(percent), m.stopwatch.View())
-	return out
+// Log writes a line for every tenth of the work completed, and at least every Interval in between,
+// with an estimate of the time remaining.
+type Log struct {
+	Interval time.Duration
+
+	out       io.Writer
+	tracker   tracker
+	lastTenth int
+	lastLine  time.Time
+}
+
+// Creates a reporter writing log lines to out, with a line at least every 30 seconds.
+func NewLog(out io.Writer) *Log {
+	return &Log{Interval: 30 * time.Second, out: out}
+}
+
+func (l *Log) Start(total int) {
+	l.tracker.reset(total)
+	l.lastTenth = 0
+	l.lastLine = time.Now()
+	fmt.Fprintf(l.out, "Beginning render of %d tiles. . .\n", total)
 }
 
-// Initialize a new progress bar model.
-func InitBar(num_items int) *tea.Program {
-	progressbar := progress.New(progress.WithDefaultGradient())
+func (l *Log) Advance(n int) {
+	l.tracker.done += n
+	tenth := int(10 * l.tracker.fraction())
+	if tenth == l.lastTenth && time.Since(l.lastLine) < l.Interval {
+		return
+	}
+	if l.tracker.done >= l.tracker.total {
+		// the last line is written by Finish
+		return
+	}
+	l.lastTenth = tenth
+	l.lastLine = time.Now()
+	line := fmt.Sprintf("Rendered %.0f%% (%d/%d tiles) in %s", 100*l.tracker.fraction(), l.tracker.done, l.tracker.total, round(time.Since(l.tracker.start)))
+	if eta, ok := l.tracker.remaining(); ok {
+		line += fmt.Sprintf(", about %s remaining", round(eta))
+	}
+	fmt.Fprintln(l.out, line)
+}
 
-	p := tea.NewProgram(model{
-		totalItems:  num_items,
-		stopwatch:   stopwatch.NewWithInterval(time.Second),
-		currentItem: 0,
-		progressbar: progressbar,
-	})
+func (l *Log) Finish() {
+	elapsed := round(time.Since(l.tracker.start))
+	if l.tracker.done < l.tracker.total {
+		fmt.Fprintf(l.out, "Stopped at %.0f%% (%d/%d tiles) after %s\n", 100*l.tracker.fraction(), l.tracker.done, l.tracker.total, elapsed)
+		return
+	}
+	fmt.Fprintf(l.out, "Done after %s\n", elapsed)
+}
+
+// Rounds a duration for display, to the second once it is longer than a few seconds.
+func round(d time.Duration) time.Duration {
+	if d > 10*time.Second {
+		return d.Round(time.Second)
+	}
+	return d.Round(10 * time.Millisecond)
+}
+
+// JSON writes one JSON object per line for the start of a render, its progress at most every Interval, and its end.
+// Every event has the work done and the total, progress and end events the seconds elapsed,
+// and progress events an estimate of the seconds remaining once known, e.g.
+//
+//	{"event":"progress","done":12,"total":48,"percent":25,"elapsed":3.2,"remaining":9.6}
+type JSON struct {
+	Interval time.Duration
+
+	enc       *json.Encoder
+	tracker   tracker
+	lastEvent time.Time
+}
+
+// A line written by the JSON reporter.
+type event struct {
+	Event     string   `json:"event"` // start, progress or finish
+	Done      int      `json:"done"`
+	Total     int      `json:"total"`
+	Percent   float64  `json:"percent"`
+	Elapsed   float64  `json:"elapsed"`
+	Remaining *float64 `json:"remaining,omitempty"`
+	Completed *bool    `json:"completed,omitempty"` // whether all of the work was done, set on finish
+}
+
+// Creates a reporter writing JSON events to out, with progress events at most once a second.
+func NewJSON(out io.Writer) *JSON {
+	return &JSON{Interval: time.Second, enc: json.NewEncoder(out)}
+}
+
+func (j *JSON) event(name string) event {
+	return event{
+		Event:   name,
+		Done:    j.tracker.done,
+		Total:   j.tracker.total,
+		Percent: 100 * j.tracker.fraction(),
+		Elapsed: time.Since(j.tracker.start).Seconds(),
+	}
+}
+
+func (j *JSON) Start(total int) {
+	j.tracker.reset(total)
+	j.lastEvent = time.Now()
+	j.enc.Encode(j.event("start"))
+}
+
+func (j *JSON) Advance(n int) {
+	j.tracker.done += n
+	if time.Since(j.lastEvent) < j.Interval || j.tracker.done >= j.tracker.total {
+		return
+	}
+	j.lastEvent = time.Now()
+	e := j.event("progress")
+	if eta, ok := j.tracker.remaining(); ok {
+		seconds := eta.Seconds()
+		e.Remaining = &seconds
+	}
+	j.enc.Encode(e)
+}
 
-	return p
+func (j *JSON) Finish() {
+	e := j.event("finish")
+	completed := j.tracker.done >= j.tracker.total
+	e.Completed = &completed
+	j.enc.Encode(e)
 }
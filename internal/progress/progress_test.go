@@ -0,0 +1,84 @@
+package progress_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/progress"
+)
+
+func TestJSON(t *testing.T) {
+	var out bytes.Buffer
+	r := progress.NewJSON(&out)
+	r.Interval = 0
+	r.Start(4)
+	r.Advance(1)
+	r.Advance(2)
+	r.Advance(1)
+	r.Finish()
+
+	type event struct {
+		Event     string
+		Done      int
+		Total     int
+		Remaining *float64
+		Completed *bool
+	}
+	var events []event
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var e event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("Error decoding event: %v", err)
+		}
+		events = append(events, e)
+	}
+
+	expected := []string{"start", "progress", "progress", "finish"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, but got %d", len(expected), len(events))
+	}
+	for i, e := range events {
+		if e.Event != expected[i] {
+			t.Errorf("Expected event %s, but got %s", expected[i], e.Event)
+		}
+		if e.Total != 4 {
+			t.Errorf("Expected a total of 4, but got %d", e.Total)
+		}
+	}
+	if events[2].Done != 3 || events[2].Remaining == nil {
+		t.Errorf("Expected 3 done with an estimate remaining, but got %d done", events[2].Done)
+	}
+	if last := events[3]; last.Done != 4 || last.Completed == nil || !*last.Completed {
+		t.Errorf("Expected a completed finish event, but got %+v", last)
+	}
+}
+
+func TestLog(t *testing.T) {
+	var out bytes.Buffer
+	r := progress.NewLog(&out)
+	r.Start(10)
+	for range 10 {
+		r.Advance(1)
+	}
+	r.Finish()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 11 {
+		t.Errorf("Expected 11 lines, but got %d", len(lines))
+	}
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "Done after") {
+		t.Errorf("Expected the render to be done, but got %q", last)
+	}
+
+	// the same reporter follows a second, interrupted render
+	out.Reset()
+	r.Start(10)
+	r.Advance(4)
+	r.Finish()
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "Stopped at 40% (4/10 tiles)") {
+		t.Errorf("Expected the render to stop at 40%%, but got %q", last)
+	}
+}
@@ -0,0 +1,43 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/nsp5488/go_raytracer/internal/progress"
+)
+
+// Maps a reporter name to a function creating it for an output stream.
+var reporters = map[string]func(out *os.File) progress.Reporter{
+	"auto": Auto,
+	"tui":  func(*os.File) progress.Reporter { return New() },
+	"log":  func(out *os.File) progress.Reporter { return progress.NewLog(out) },
+	"json": func(out *os.File) progress.Reporter { return progress.NewJSON(out) },
+	"none": func(*os.File) progress.Reporter { return progress.Silent{} },
+}
+
+// Auto returns a progress bar when out is a terminal and plain log lines otherwise, so progress written
+// to a file or a CI log isn't full of escape codes.
+func Auto(out *os.File) progress.Reporter {
+	if isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd()) {
+		return New()
+	}
+	return progress.NewLog(out)
+}
+
+// ByName returns the reporter registered under the given name, writing to out: auto, tui, log, json or none.
+func ByName(name string, out *os.File) (progress.Reporter, error) {
+	newReporter, ok := reporters[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(reporters))
+		for name := range reporters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown progress reporter %q (supported: %s)", name, strings.Join(names, ", "))
+	}
+	return newReporter(out), nil
+}
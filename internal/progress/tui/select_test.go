@@ -0,0 +1,19 @@
+package tui_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/progress/tui"
+)
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"log", "JSON", "none"} {
+		if _, err := tui.ByName(name, os.Stdout); err != nil {
+			t.Errorf("Expected reporter %s to exist, but got %v", name, err)
+		}
+	}
+	if _, err := tui.ByName("bogus", os.Stdout); err == nil {
+		t.Errorf("Expected an error for an unknown reporter")
+	}
+}
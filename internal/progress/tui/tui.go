@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/stopwatch"
+)
+
+const (
+	padding  = 16
+	maxWidth = 160
+)
+
+// Sent when the render has ended, completed or not.
+type finishMsg struct{}
+
+// Model to represent the state of the progress bar and stopwatch.
+type model struct {
+	totalItems  int
+	currentItem int
+	finished    bool
+	interrupt   func() // stops the render, if set
+	stopwatch   stopwatch.Model
+	progressbar progress.Model
+}
+
+// initialize the stopwatch
+func (m model) Init() tea.Cmd {
+	return m.stopwatch.Init()
+}
+
+// Updates the progress bar and stopwatch.
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			if m.interrupt == nil {
+				return m, tea.Quit
+			}
+			// keep showing the bar until the render has stopped
+			m.interrupt()
+			return m, nil
+		}
+
+	case tea.WindowSizeMsg:
+		// Resize the progress bar dynamically
+		m.progressbar.PercentageStyle.PaddingLeft(padding)
+		m.progressbar.Width = msg.Width - padding - 4
+		if m.progressbar.Width > maxWidth {
+			m.progressbar.Width = maxWidth
+		}
+		return m, nil
+	case int:
+		// Update progress
+		m.currentItem += msg
+		return m, nil
+	case finishMsg:
+		m.finished = true
+		return m, tea.Quit
+	case progress.FrameMsg:
+		progressModel, _ := m.progressbar.Update(msg)
+		m.progressbar = progressModel.(progress.Model)
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.stopwatch, cmd = m.stopwatch.Update(msg)
+	return m, cmd
+}
+
+// Returns a string representation of the progress bar and stopwatch.
+func (m model) View() string {
+	percent := float64(m.currentItem) / float64(max(m.totalItems, 1))
+	if m.finished {
+		// Print summary
+		m.stopwatch.Stop()
+		if m.currentItem < m.totalItems {
+			return fmt.Sprintf("Stopped at %.0f%% after %s\n", 100*percent, m.stopwatch.View())
+		}
+		return fmt.Sprintf("Done after %s\n", m.stopwatch.View())
+	}
+
+	out := fmt.Sprintf("\n %s Time Elapsed: %s\n", m.progressbar.ViewAs(percent), m.stopwatch.View())
+	return out
+}
+
+// Bar shows the progress of a render as an animated progress bar and stopwatch in the terminal.
+// Pressing q or ctrl+c stops the render when a function to do so was set by OnInterrupt, otherwise it hides the bar.
+type Bar struct {
+	program   *tea.Program
+	done      chan struct{} // closed once the program exits
+	interrupt func()
+}
+
+// Creates a progress bar, which takes over the terminal from Start until Finish.
+func New() *Bar {
+	return &Bar{}
+}
+
+// OnInterrupt sets the function pressing q or ctrl+c calls to stop the render.
+func (b *Bar) OnInterrupt(stop func()) {
+	b.interrupt = stop
+}
+
+func (b *Bar) Start(total int) {
+	fmt.Println("Beginning render. . .")
+	b.program = tea.NewProgram(model{
+		totalItems:  total,
+		stopwatch:   stopwatch.NewWithInterval(time.Second),
+		currentItem: 0,
+		progressbar: progress.New(progress.WithDefaultGradient()),
+		interrupt:   b.interrupt,
+	})
+	b.done = make(chan struct{})
+	go func() {
+		defer close(b.done)
+		_, err := b.program.Run()
+		switch {
+		case errors.Is(err, tea.ErrInterrupted) && b.interrupt != nil:
+			// the program exits by itself on SIGINT
+			b.interrupt()
+		case err != nil:
+			b.program.ReleaseTerminal()
+			log.Printf("Error running progress bar: %v", err)
+		}
+	}()
+}
+
+func (b *Bar) Advance(n int) {
+	b.program.Send(n)
+}
+
+func (b *Bar) Finish() {
+	b.program.Send(finishMsg{})
+	<-b.done
+}
@@ -0,0 +1,835 @@
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/lens"
+	"github.com/nsp5488/go_raytracer/internal/objLoader"
+	"github.com/nsp5488/go_raytracer/internal/postfx"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Turns a parsed scene description into hittables, resolving named textures, materials and objects.
+// Problems are collected rather than returned so a single pass reports everything wrong with a scene,
+// anything which fails to build is left out.
+type builder struct {
+	spec     *sceneSpec
+	dir      string
+	dryRun   bool // only check referenced files exist instead of loading images and models
+	confined bool // files must lie within dir
+	problems Problems
+	warnings Problems // things the scene renders despite
+
+	textures  map[string]hittable.Texture // nil for textures which failed to build
+	materials map[string]hittable.Material
+	resolving map[string]bool // named textures being built, to detect cycles
+
+	objects   map[string]hittable.Hittable // objects with an id, nil if they failed to build
+	objLights []hittable.Hittable          // emissive triangles of each OBJ include, transformed like the model
+}
+
+func newBuilder(spec *sceneSpec, dir string, dryRun bool) *builder {
+	return &builder{
+		spec:      spec,
+		dir:       dir,
+		dryRun:    dryRun,
+		textures:  map[string]hittable.Texture{},
+		materials: map[string]hittable.Material{},
+		resolving: map[string]bool{},
+		objects:   map[string]hittable.Hittable{},
+	}
+}
+
+// Records a problem with the value at path.
+func (b *builder) fail(path, format string, args ...any) {
+	b.problems = append(b.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Records a warning about the value at path, which doesn't keep the scene from rendering.
+func (b *builder) warn(path, format string, args ...any) {
+	b.warnings = append(b.warnings, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Reports whether problems were recorded since the count n was taken.
+func (b *builder) failedSince(n int) bool {
+	return len(b.problems) > n
+}
+
+func (b *builder) build(c *camera.Camera) *Scene {
+	b.configureCamera(c)
+
+	if len(b.spec.Objects) == 0 {
+		b.fail("objects", "the scene is empty")
+	}
+	world := hittable.NewHittableList(len(b.spec.Objects))
+	for i := range b.spec.Objects {
+		if obj := b.object(&b.spec.Objects[i], fmt.Sprintf("objects[%d]", i)); obj != nil {
+			world.Add(obj)
+		}
+	}
+
+	lights := hittable.NewHittableList(len(b.spec.Lights) + 1)
+	for i, id := range b.spec.Lights {
+		path := fmt.Sprintf("lights[%d]", i)
+		obj, ok := b.objects[id]
+		switch {
+		case !ok:
+			b.fail(path, "unknown object id %q, lights must refer to an object in the world", id)
+		case obj != nil:
+			lights.Add(obj)
+		}
+	}
+	if len(b.objLights) > 0 {
+		models := hittable.NewHittableList(len(b.objLights))
+		for _, l := range b.objLights {
+			models.Add(l)
+		}
+		lights.Add(models)
+	}
+
+	// textures and materials nothing refers to are built too, so their problems aren't hidden until they are used
+	for _, name := range sortedKeys(b.spec.Textures) {
+		b.namedTexture(name, "textures."+name)
+	}
+	for _, name := range sortedKeys(b.spec.Materials) {
+		b.namedMaterial(name, "materials."+name)
+	}
+
+	output := b.output()
+	if len(b.problems) > 0 || b.dryRun {
+		return nil
+	}
+	return &Scene{World: hittable.BuildBVH(world), Lights: lights, Output: output}
+}
+
+func (b *builder) configureCamera(c *camera.Camera) {
+	cs := b.spec.Camera
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"aspect_ratio", cs.AspectRatio},
+		{"width", float64(cs.Width)},
+		{"samples_per_pixel", float64(cs.SamplesPerPixel)},
+		{"max_depth", float64(cs.MaxDepth)},
+		{"defocus_angle", cs.DefocusAngle},
+		{"focus_distance", cs.FocusDistance},
+		{"max_contribution", cs.MaxContribution},
+		{"ortho_height", cs.OrthoHeight},
+		{"interocular", cs.Interocular},
+		{"convergence", cs.Convergence},
+		{"focal_length", cs.FocalLength},
+		{"sensor_width", cs.SensorWidth},
+		{"f_stop", cs.FStop},
+		{"aperture_blades", float64(cs.ApertureBlades)},
+		{"shutter_open", cs.ShutterOpen},
+		{"shutter_close", cs.ShutterClose},
+		{"iso", cs.ISO},
+		{"exposure_time", cs.ExposureTime},
+	} {
+		if field.value < 0 {
+			b.fail("camera."+field.name, "must not be negative, but got %v", field.value)
+		}
+	}
+	projection := camera.PERSPECTIVE
+	if cs.Projection != "" {
+		var err error
+		if projection, err = camera.ParseProjection(cs.Projection); err != nil {
+			b.fail("camera.projection", "%v", err)
+		}
+	}
+	// a fisheye sees up to all around, a perspective view less than half of that
+	maxFOV := 180.0
+	if projection == camera.FISHEYE {
+		maxFOV = 360
+	}
+	if cs.VerticalFOV < 0 || cs.VerticalFOV >= maxFOV {
+		b.fail("camera.vertical_fov", "must be between 0 and %v degrees, but got %v", maxFOV, cs.VerticalFOV)
+	}
+	if cs.OrthoHeight > 0 && projection != camera.ORTHOGRAPHIC {
+		b.fail("camera.ortho_height", "only applies to the orthographic projection")
+	}
+	if projection == camera.FISHEYE || projection == camera.EQUIRECTANGULAR {
+		for _, field := range []struct {
+			name  string
+			value float64
+		}{
+			{"shift_x", cs.ShiftX},
+			{"shift_y", cs.ShiftY},
+			{"tilt_x", cs.TiltX},
+			{"tilt_y", cs.TiltY},
+		} {
+			if field.value != 0 {
+				b.fail("camera."+field.name, "only applies to the perspective and orthographic projections")
+			}
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"tilt_x", cs.TiltX},
+		{"tilt_y", cs.TiltY},
+	} {
+		if math.Abs(field.value) >= 90 {
+			b.fail("camera."+field.name, "must be between -90 and 90 degrees, but got %v", field.value)
+		}
+	}
+	var distortion camera.Distortion
+	if len(cs.Distortion) > 0 {
+		if projection != camera.PERSPECTIVE {
+			b.fail("camera.distortion", "only applies to the perspective projection")
+		}
+		if len(cs.Distortion) > 5 {
+			b.fail("camera.distortion", "expected at most 5 coefficients [k1, k2, p1, p2, k3], but got %d", len(cs.Distortion))
+		}
+		coefficients := make([]float64, 5)
+		copy(coefficients, cs.Distortion)
+		distortion = camera.Distortion{K1: coefficients[0], K2: coefficients[1], P1: coefficients[2], P2: coefficients[3], K3: coefficients[4]}
+	}
+
+	stereo := camera.MONO
+	if cs.Stereo != "" {
+		var err error
+		if stereo, err = camera.ParseStereo(cs.Stereo); err != nil {
+			b.fail("camera.stereo", "%v", err)
+		}
+	}
+
+	// a physical lens takes the place of the field of view and defocus angle
+	if cs.FocalLength > 0 && cs.VerticalFOV > 0 {
+		b.fail("camera.focal_length", "conflicts with vertical_fov, give one of them")
+	}
+	if cs.SensorWidth > 0 && cs.FocalLength == 0 && cs.Lens == "" {
+		b.fail("camera.sensor_width", "only applies along with focal_length or lens")
+	}
+	if cs.FStop > 0 && cs.FocalLength > 0 && cs.DefocusAngle > 0 {
+		b.fail("camera.f_stop", "conflicts with defocus_angle, give one of them")
+	}
+	if cs.ApertureBlades > 0 && cs.ApertureBlades < 3 {
+		b.fail("camera.aperture_blades", "must be at least 3, but got %d", cs.ApertureBlades)
+	}
+	var aperture hittable.Texture
+	if len(cs.Aperture) > 0 {
+		aperture = b.textureRef(cs.Aperture, "camera.aperture")
+	}
+	if (cs.ShutterOpen != 0 || cs.ShutterClose != 0) && cs.ShutterClose <= cs.ShutterOpen {
+		b.fail("camera.shutter_close", "must be after shutter_open (%v), but got %v", cs.ShutterOpen, cs.ShutterClose)
+	}
+	shutter := camera.BOX
+	if cs.ShutterCurve != "" {
+		var err error
+		if shutter, err = camera.ParseShutter(cs.ShutterCurve); err != nil {
+			b.fail("camera.shutter_curve", "%v", err)
+		}
+	}
+	if cs.ISO > 0 && (cs.FStop == 0 || cs.ExposureTime == 0) {
+		b.fail("camera.iso", "exposing needs f_stop and exposure_time as well")
+	}
+	var system *lens.System
+	if cs.Lens != "" {
+		// the lens system takes the place of the thin lens and the view it gives
+		if projection != camera.PERSPECTIVE {
+			b.fail("camera.lens", "only applies to the perspective projection")
+		}
+		for _, field := range []struct {
+			name string
+			set  bool
+		}{
+			{"vertical_fov", cs.VerticalFOV != 0},
+			{"focal_length", cs.FocalLength != 0},
+			{"defocus_angle", cs.DefocusAngle != 0},
+			{"shift_x", cs.ShiftX != 0},
+			{"shift_y", cs.ShiftY != 0},
+			{"tilt_x", cs.TiltX != 0},
+			{"tilt_y", cs.TiltY != 0},
+			{"distortion", len(cs.Distortion) > 0},
+		} {
+			if field.set {
+				b.fail("camera."+field.name, "conflicts with lens, which takes its place")
+			}
+		}
+		if file := b.file(cs.Lens, "camera.lens"); file != "" {
+			var err error
+			if system, err = lens.Load(file); err != nil {
+				b.fail("camera.lens", "%v", err)
+			}
+		}
+	}
+
+	lookFrom := b.optionalVec(cs.LookFrom, "camera.look_from")
+	lookAt := b.optionalVec(cs.LookAt, "camera.look_at")
+	up := b.optionalVec(cs.Up, "camera.up")
+	// the same defaults PositionCamera uses
+	from, at, vup := vec.Empty(), vec.New(0, 0, -1), vec.New(0, 1, 0)
+	if lookFrom != nil {
+		from = lookFrom
+	}
+	if lookAt != nil {
+		at = lookAt
+	}
+	if up != nil {
+		vup = up
+	}
+	if at.Sub(from).NearZero() {
+		b.fail("camera.look_at", "must differ from look_from")
+	} else if vup.Cross(at.Sub(from)).NearZero() {
+		b.fail("camera.up", "must not be parallel to the viewing direction")
+	}
+
+	if cs.AspectRatio > 0 {
+		c.AspectRatio = cs.AspectRatio
+	}
+	if cs.Width > 0 {
+		c.Width = cs.Width
+	}
+	if cs.SamplesPerPixel > 0 {
+		c.SamplesPerPixel = cs.SamplesPerPixel
+	}
+	if cs.MaxDepth > 0 {
+		c.MaxDepth = cs.MaxDepth
+	}
+	if cs.VerticalFOV > 0 {
+		c.VerticalFOV = cs.VerticalFOV
+	}
+	c.DefocusAngle = cs.DefocusAngle
+	c.FocusDistance = cs.FocusDistance
+	c.MaxContribution = cs.MaxContribution
+	c.Projection = projection
+	c.OrthoHeight = cs.OrthoHeight
+	c.Stereo = stereo
+	c.Interocular = cs.Interocular
+	c.Convergence = cs.Convergence
+	c.ShiftX = cs.ShiftX
+	c.ShiftY = cs.ShiftY
+	c.TiltX = cs.TiltX
+	c.TiltY = cs.TiltY
+	c.Distortion = distortion
+	c.FocalLength = cs.FocalLength
+	c.SensorWidth = cs.SensorWidth
+	c.FStop = cs.FStop
+	c.ApertureBlades = cs.ApertureBlades
+	c.ApertureRotation = cs.ApertureRotation
+	c.ApertureMask = aperture
+	c.ShutterOpen = cs.ShutterOpen
+	c.ShutterClose = cs.ShutterClose
+	c.ShutterCurve = shutter
+	c.ISO = cs.ISO
+	c.ExposureTime = cs.ExposureTime
+	c.Lens = system
+	c.PositionCamera(lookFrom, lookAt, up)
+
+	c.Background = vec.Empty()
+	if b.spec.Background != nil {
+		if bg := b.vec(b.spec.Background, "background"); bg != nil {
+			c.Background = bg
+		}
+	}
+}
+
+// Converts a required vector, recording a problem if it is missing or malformed.
+func (b *builder) vec(v vector, path string) *vec.Vec3 {
+	if v == nil {
+		b.fail(path, "missing, expected [x, y, z]")
+		return nil
+	}
+	return b.optionalVec(v, path)
+}
+
+// Converts a vector which may be left out, returning nil if it is.
+func (b *builder) optionalVec(v vector, path string) *vec.Vec3 {
+	if v == nil {
+		return nil
+	}
+	if len(v) != 3 {
+		b.fail(path, "expected [x, y, z], but got %d numbers", len(v))
+		return nil
+	}
+	return vec.New(v[0], v[1], v[2])
+}
+
+// Resolves a texture reference: a color, the name of a texture or an inline texture.
+func (b *builder) textureRef(raw json.RawMessage, path string) hittable.Texture {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		b.fail(path, "missing color or texture")
+		return nil
+	}
+	switch raw[0] {
+	case '[':
+		var color vector
+		if err := json.Unmarshal(raw, &color); err != nil {
+			b.fail(path, "expected [r, g, b]")
+			return nil
+		}
+		if c := b.vec(color, path); c != nil {
+			return hittable.NewSolidColor(c)
+		}
+		return nil
+	case '"':
+		var name string
+		json.Unmarshal(raw, &name)
+		return b.namedTexture(name, path)
+	case '{':
+		spec := textureSpec{}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			b.fail(path, "%v", err)
+			return nil
+		}
+		return b.texture(&spec, path)
+	}
+	b.fail(path, "expected a color, texture name or texture")
+	return nil
+}
+
+func (b *builder) namedTexture(name, path string) hittable.Texture {
+	if tex, ok := b.textures[name]; ok {
+		return tex
+	}
+	spec, ok := b.spec.Textures[name]
+	if !ok {
+		b.fail(path, "unknown texture %q", name)
+		return nil
+	}
+	if b.resolving[name] {
+		b.fail(path, "texture %q refers to itself", name)
+		return nil
+	}
+	b.resolving[name] = true
+	tex := b.texture(&spec, "textures."+name)
+	delete(b.resolving, name)
+	b.textures[name] = tex
+	return tex
+}
+
+func (b *builder) texture(spec *textureSpec, path string) hittable.Texture {
+	n := len(b.problems)
+	switch spec.Type {
+	case "solid":
+		if color := b.vec(spec.Color, path+".color"); color != nil {
+			return hittable.NewSolidColor(color)
+		}
+		return nil
+	case "checker":
+		if spec.Scale <= 0 {
+			b.fail(path+".scale", "must be positive, but got %v", spec.Scale)
+		}
+		even := b.textureRef(spec.Even, path+".even")
+		odd := b.textureRef(spec.Odd, path+".odd")
+		if b.failedSince(n) {
+			return nil
+		}
+		return hittable.NewCheckerboard(spec.Scale, even, odd)
+	case "image":
+		file := b.file(spec.File, path+".file")
+		if file == "" {
+			return nil
+		}
+		if b.dryRun {
+			return hittable.NewSolidColorRGB(1, 0, 1)
+		}
+		tex, err := hittable.NewImageTexture(file)
+		if err != nil {
+			b.fail(path+".file", "%v", err)
+			return nil
+		}
+		return tex
+	case "noise":
+		if spec.Scale <= 0 {
+			b.fail(path+".scale", "must be positive, but got %v", spec.Scale)
+			return nil
+		}
+		switch spec.Variant {
+		case "", "perlin":
+			return hittable.NewNoiseTextureWithType(spec.Scale, hittable.PERLIN)
+		case "marble":
+			return hittable.NewNoiseTextureWithType(spec.Scale, hittable.MARBLE)
+		case "turbulent":
+			return hittable.NewNoiseTextureWithType(spec.Scale, hittable.TURBULENT)
+		}
+		b.fail(path+".variant", "unknown noise variant %q (supported: perlin, marble, turbulent)", spec.Variant)
+		return nil
+	}
+	b.fail(path+".type", "unknown texture type %q (supported: solid, checker, image, noise)", spec.Type)
+	return nil
+}
+
+// Resolves a material reference: the name of a material or an inline material.
+func (b *builder) materialRef(raw json.RawMessage, path string) hittable.Material {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		b.fail(path, "missing material")
+		return nil
+	}
+	switch raw[0] {
+	case '"':
+		var name string
+		json.Unmarshal(raw, &name)
+		return b.namedMaterial(name, path)
+	case '{':
+		spec := materialSpec{}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			b.fail(path, "%v", err)
+			return nil
+		}
+		return b.material(&spec, path)
+	}
+	b.fail(path, "expected a material name or material")
+	return nil
+}
+
+func (b *builder) namedMaterial(name, path string) hittable.Material {
+	if mat, ok := b.materials[name]; ok {
+		return mat
+	}
+	spec, ok := b.spec.Materials[name]
+	if !ok {
+		b.fail(path, "unknown material %q", name)
+		return nil
+	}
+	mat := b.material(&spec, "materials."+name)
+	b.materials[name] = mat
+	return mat
+}
+
+func (b *builder) material(spec *materialSpec, path string) hittable.Material {
+	switch spec.Type {
+	case "lambertian":
+		if tex := b.textureRef(spec.Albedo, path+".albedo"); tex != nil {
+			return hittable.NewTexturedLambertian(tex)
+		}
+		return nil
+	case "metal":
+		var albedo vector
+		if err := json.Unmarshal(spec.Albedo, &albedo); err != nil {
+			b.fail(path+".albedo", "metal needs a color, expected [r, g, b]")
+			return nil
+		}
+		if spec.Fuzz < 0 || spec.Fuzz > 1 {
+			b.fail(path+".fuzz", "must be between 0 and 1, but got %v", spec.Fuzz)
+			return nil
+		}
+		if color := b.vec(albedo, path+".albedo"); color != nil {
+			return hittable.NewMetal(color, spec.Fuzz)
+		}
+		return nil
+	case "dielectric":
+		if spec.IOR <= 0 {
+			b.fail(path+".ior", "must be positive, but got %v", spec.IOR)
+			return nil
+		}
+		return hittable.NewDielectric(spec.IOR)
+	case "diffuse_light":
+		if tex := b.textureRef(spec.Emit, path+".emit"); tex != nil {
+			return hittable.NewDiffuseLightTextured(tex)
+		}
+		return nil
+	case "isotropic":
+		if tex := b.textureRef(spec.Albedo, path+".albedo"); tex != nil {
+			return hittable.NewIsotropicTexture(tex)
+		}
+		return nil
+	}
+	b.fail(path+".type", "unknown material type %q (supported: lambertian, metal, dielectric, diffuse_light, isotropic)", spec.Type)
+	return nil
+}
+
+// Builds an object and applies its transforms, registering it under its id.
+func (b *builder) object(spec *objectSpec, path string) hittable.Hittable {
+	n := len(b.problems)
+	modelLights := len(b.objLights)
+	obj := b.primitive(spec, path)
+	// the emissive triangles of models within the object move with it
+	apply := func(transform func(hittable.Hittable) hittable.Hittable) {
+		obj = transform(obj)
+		for i := modelLights; i < len(b.objLights); i++ {
+			b.objLights[i] = transform(b.objLights[i])
+		}
+	}
+	for i, t := range spec.Transform {
+		tpath := fmt.Sprintf("%s.transform[%d]", path, i)
+		switch {
+		case t.Translate != nil && t.RotateY != nil:
+			b.fail(tpath, "a transform step must either translate or rotate_y")
+		case t.Translate != nil:
+			if offset := b.vec(t.Translate, tpath+".translate"); offset != nil && obj != nil {
+				apply(func(h hittable.Hittable) hittable.Hittable { return hittable.Translate(h, offset) })
+			}
+		case t.RotateY != nil:
+			if obj != nil {
+				apply(func(h hittable.Hittable) hittable.Hittable { return hittable.RotateY(h, *t.RotateY) })
+			}
+		default:
+			b.fail(tpath, "empty transform step")
+		}
+	}
+	if b.failedSince(n) {
+		obj = nil
+	}
+
+	if spec.ID != "" {
+		if _, ok := b.objects[spec.ID]; ok {
+			b.fail(path+".id", "duplicate object id %q", spec.ID)
+		} else {
+			b.objects[spec.ID] = obj
+		}
+	}
+	return obj
+}
+
+// Builds an object without its transforms.
+func (b *builder) primitive(spec *objectSpec, path string) hittable.Hittable {
+	n := len(b.problems)
+	switch spec.Type {
+	case "sphere":
+		center := b.vec(spec.Center, path+".center")
+		center2 := b.optionalVec(spec.Center2, path+".center2")
+		if spec.Radius <= 0 {
+			b.fail(path+".radius", "must be positive, but got %v", spec.Radius)
+		}
+		mat := b.materialRef(spec.Material, path+".material")
+		if b.failedSince(n) {
+			return nil
+		}
+		if center2 != nil {
+			return hittable.NewMotionSphere(center, center2, spec.Radius, mat)
+		}
+		return hittable.NewSphere(center, spec.Radius, mat)
+
+	case "quad":
+		q := b.vec(spec.Q, path+".q")
+		u := b.vec(spec.U, path+".u")
+		v := b.vec(spec.V, path+".v")
+		if u != nil && v != nil && u.Cross(v).NearZero() {
+			b.fail(path, "degenerate quad, u and v must not be parallel or zero")
+		}
+		mat := b.materialRef(spec.Material, path+".material")
+		if b.failedSince(n) {
+			return nil
+		}
+		return hittable.NewQuad(q, u, v, mat)
+
+	case "box":
+		lo := b.vec(spec.Min, path+".min")
+		hi := b.vec(spec.Max, path+".max")
+		if lo != nil && hi != nil && (lo.X() == hi.X() || lo.Y() == hi.Y() || lo.Z() == hi.Z()) {
+			b.fail(path, "degenerate box, min and max must differ in every dimension")
+		}
+		mat := b.materialRef(spec.Material, path+".material")
+		if b.failedSince(n) {
+			return nil
+		}
+		return hittable.NewBox(lo, hi, mat)
+
+	case "triangle":
+		if len(spec.Vertices) != 3 {
+			b.fail(path+".vertices", "a triangle needs 3 vertices, but got %d", len(spec.Vertices))
+		}
+		if spec.Normals != nil && len(spec.Normals) != 3 {
+			b.fail(path+".normals", "expected 3 normals, but got %d", len(spec.Normals))
+		}
+		if spec.UVs != nil && len(spec.UVs) != 3 {
+			b.fail(path+".uvs", "expected 3 texture coordinates, but got %d", len(spec.UVs))
+		}
+		mat := b.materialRef(spec.Material, path+".material")
+		if b.failedSince(n) {
+			return nil
+		}
+		var vertices, normals [3]*vec.Vec3
+		for i := range 3 {
+			vertices[i] = b.vec(spec.Vertices[i], fmt.Sprintf("%s.vertices[%d]", path, i))
+			if spec.Normals != nil {
+				normals[i] = b.vec(spec.Normals[i], fmt.Sprintf("%s.normals[%d]", path, i))
+			}
+		}
+		if b.failedSince(n) {
+			return nil
+		}
+		if vertices[1].Sub(vertices[0]).Cross(vertices[2].Sub(vertices[0])).NearZero() {
+			b.fail(path+".vertices", "degenerate triangle, the vertices must not be collinear")
+			return nil
+		}
+		switch {
+		case spec.Normals != nil && spec.UVs != nil:
+			return hittable.NewTexturedTriangleWithNormals(vertices, normals, [3][2]float64(spec.UVs), mat)
+		case spec.Normals != nil:
+			return hittable.NewTriangleWithNormals(vertices, normals, mat)
+		case spec.UVs != nil:
+			return hittable.NewTexturedTriangle(vertices, [3][2]float64(spec.UVs), mat)
+		}
+		return hittable.NewTriangle(vertices, mat)
+
+	case "medium":
+		var boundary hittable.Hittable
+		if spec.Boundary == nil {
+			b.fail(path+".boundary", "missing boundary object")
+		} else {
+			boundary = b.object(spec.Boundary, path+".boundary")
+		}
+		if spec.Density <= 0 {
+			b.fail(path+".density", "must be positive, but got %v", spec.Density)
+		}
+		tex := b.textureRef(spec.Albedo, path+".albedo")
+		if b.failedSince(n) {
+			return nil
+		}
+		return hittable.ConstantMediumTexture(boundary, spec.Density, tex)
+
+	case "group":
+		if len(spec.Objects) == 0 {
+			b.fail(path+".objects", "empty group")
+			return nil
+		}
+		group := hittable.NewHittableList(len(spec.Objects))
+		for i := range spec.Objects {
+			if obj := b.object(&spec.Objects[i], fmt.Sprintf("%s.objects[%d]", path, i)); obj != nil {
+				group.Add(obj)
+			}
+		}
+		if b.failedSince(n) {
+			return nil
+		}
+		if spec.BVH {
+			return hittable.BuildBVH(group)
+		}
+		return group
+
+	case "obj":
+		file := b.file(spec.File, path+".file")
+		if spec.Scale < 0 {
+			b.fail(path+".scale", "must not be negative, but got %v", spec.Scale)
+		}
+		position := b.optionalVec(spec.Position, path+".position")
+		var mat hittable.Material
+		if len(spec.Material) > 0 {
+			mat = b.materialRef(spec.Material, path+".material")
+		}
+		if b.failedSince(n) {
+			return nil
+		}
+		if b.dryRun {
+			return hittable.NewHittableList(0)
+		}
+
+		opts := objLoader.DefaultLoadOptions()
+		opts.Debug = nil
+		if spec.Debug {
+			opts.Debug = os.Stdout
+		}
+		opts.Center = spec.Recenter
+		opts.FlipYZ = spec.FlipYZ
+		opts.FlipFaces = spec.FlipFaces
+		opts.IgnoreNormals = spec.IgnoreNormals
+		opts.IgnoreMtl = spec.IgnoreMtl
+		opts.FindWindows = spec.FindWindows
+		if spec.Scale != 0 {
+			opts.ScaleFactor = spec.Scale
+		}
+		if position != nil {
+			opts.Position = position
+		}
+		if mat != nil {
+			opts.DefaultMaterial = mat
+		}
+		model, lights, warnings, err := objLoader.LoadObjWithOptions(file, opts)
+		if err != nil {
+			b.fail(path+".file", "%v", err)
+			return nil
+		}
+		for _, w := range warnings {
+			b.warn(path+".file", "%v", w)
+		}
+		if hl, ok := lights.(*hittable.HittableList); ok && hl.Len() > 0 {
+			b.objLights = append(b.objLights, hl)
+		}
+		return model
+	}
+	b.fail(path+".type", "unknown object type %q (supported: sphere, quad, box, triangle, medium, group, obj)", spec.Type)
+	return nil
+}
+
+// Resolves a file referenced by the scene relative to the scene's directory and checks that it exists.
+// Returns an empty string if it does not.
+func (b *builder) file(name, path string) string {
+	if name == "" {
+		b.fail(path, "missing file name")
+		return ""
+	}
+	if b.confined && !filepath.IsLocal(name) {
+		b.fail(path, "%q is outside of the scene directory", name)
+		return ""
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(b.dir, name)
+	}
+	if _, err := os.Stat(name); err != nil {
+		b.fail(path, "%v", err)
+		return ""
+	}
+	return name
+}
+
+func (b *builder) output() Output {
+	out := Output{}
+	spec := b.spec.Output
+	if spec == nil {
+		return out
+	}
+	if spec.Tonemap != "" {
+		op, err := tonemap.ParseOperator(spec.Tonemap)
+		if err != nil {
+			b.fail("output.tonemap", "%v", err)
+		}
+		out.Tonemap = &tonemap.Mapper{Operator: op, Exposure: spec.Exposure, WhitePoint: spec.WhitePoint}
+	} else if spec.Exposure != 0 || spec.WhitePoint != 0 {
+		out.Tonemap = &tonemap.Mapper{Operator: tonemap.CLAMP, Exposure: spec.Exposure, WhitePoint: spec.WhitePoint}
+	}
+	if spec.WhitePoint < 0 {
+		b.fail("output.white_point", "must not be negative, but got %v", spec.WhitePoint)
+	}
+	if spec.Denoise < 0 || spec.Denoise > 1 {
+		b.fail("output.denoise", "must be between 0 and 1, but got %v", spec.Denoise)
+	}
+	out.Denoise = spec.Denoise
+
+	for i, step := range spec.Post {
+		path := fmt.Sprintf("output.post[%d]", i)
+		name, ok := step["effect"].(string)
+		if !ok {
+			b.fail(path+".effect", "missing effect name")
+			continue
+		}
+		params := map[string]string{}
+		failed := false
+		for _, key := range sortedKeys(step) {
+			if key == "effect" {
+				continue
+			}
+			value := fmt.Sprint(step[key])
+			if key == "file" {
+				if value = b.file(value, path+".file"); value == "" {
+					failed = true
+				}
+			}
+			params[key] = value
+		}
+		if failed {
+			continue
+		}
+		effect, err := postfx.New(name, params)
+		if err != nil {
+			b.fail(path, "%v", err)
+			continue
+		}
+		out.Post = append(out.Post, effect)
+	}
+	return out
+}
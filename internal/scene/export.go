@@ -0,0 +1,358 @@
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Export writes a world, its lights and the camera's settings in the scene format.
+// Every light must be part of the world. File names of image textures and lens systems are written as they were given.
+func Export(w io.Writer, c *camera.Camera, world, lights hittable.Hittable) error {
+	return newExporter("").export(w, c, world, lights)
+}
+
+// Save exports a scene to a file, rewriting the file names of image textures and lens systems relative to the scene's directory
+// so it can be loaded from anywhere.
+func Save(filename string, c *camera.Camera, world, lights hittable.Hittable) error {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = newExporter(dir).export(file, c, world, lights)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Converts hittables back into their scene description. Materials are named so shared materials are written once.
+type exporter struct {
+	spec      *sceneSpec
+	dir       string // image file names are made relative to this directory, unless it is empty
+	materials map[hittable.Material]string
+	ids       map[hittable.Hittable]string // ids of the lights, which are given to their first occurrence in the world
+	written   map[hittable.Hittable]bool
+}
+
+func newExporter(dir string) *exporter {
+	return &exporter{
+		spec:      &sceneSpec{Materials: map[string]materialSpec{}},
+		dir:       dir,
+		materials: map[hittable.Material]string{},
+		ids:       map[hittable.Hittable]string{},
+		written:   map[hittable.Hittable]bool{},
+	}
+}
+
+func (e *exporter) export(w io.Writer, c *camera.Camera, world, lights hittable.Hittable) error {
+	if err := e.camera(c); err != nil {
+		return err
+	}
+
+	for i, light := range e.members(lights, hittable.LIST) {
+		id := fmt.Sprintf("light%d", i+1)
+		e.ids[light] = id
+		e.spec.Lights = append(e.spec.Lights, id)
+	}
+
+	// the loader puts the world in a BVH itself, so the top level is written as a plain list of objects
+	for _, obj := range e.members(world, hittable.LIST, hittable.BVH) {
+		spec, err := e.object(obj)
+		if err != nil {
+			return err
+		}
+		e.spec.Objects = append(e.spec.Objects, spec)
+	}
+	for light, id := range e.ids {
+		if !e.written[light] {
+			return fmt.Errorf("light %s is not part of the world", id)
+		}
+	}
+
+	out, err := json.MarshalIndent(e.spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	// keep vectors and colors on one line
+	out = numberArray.ReplaceAllFunc(out, func(arr []byte) []byte {
+		return bytes.ReplaceAll(whitespace.ReplaceAll(arr, nil), []byte(","), []byte(", "))
+	})
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+var (
+	numberArray = regexp.MustCompile(`\[[-0-9.eE+,\s]*\]`)
+	whitespace  = regexp.MustCompile(`\s+`)
+)
+
+// Returns the members of h if it is one of the given container shapes, otherwise h itself.
+func (e *exporter) members(h hittable.Hittable, containers ...hittable.Shape) []hittable.Hittable {
+	if h == nil {
+		return nil
+	}
+	if d, ok := hittable.Describe(h); ok {
+		for _, shape := range containers {
+			if d.Shape == shape {
+				return d.Children
+			}
+		}
+	}
+	return []hittable.Hittable{h}
+}
+
+func (e *exporter) camera(c *camera.Camera) error {
+	lookFrom, lookAt, up := c.Position()
+	e.spec.Camera = cameraSpec{
+		AspectRatio:     c.AspectRatio,
+		Width:           c.Width,
+		SamplesPerPixel: c.SamplesPerPixel,
+		MaxDepth:        c.MaxDepth,
+		VerticalFOV:     c.VerticalFOV,
+		LookFrom:        toVector(lookFrom),
+		LookAt:          toVector(lookAt),
+		Up:              toVector(up),
+		DefocusAngle:    c.DefocusAngle,
+		FocusDistance:   c.FocusDistance,
+		MaxContribution: c.MaxContribution,
+		OrthoHeight:     c.OrthoHeight,
+		Interocular:     c.Interocular,
+		Convergence:     c.Convergence,
+
+		ShiftX:           c.ShiftX,
+		ShiftY:           c.ShiftY,
+		TiltX:            c.TiltX,
+		TiltY:            c.TiltY,
+		FocalLength:      c.FocalLength,
+		SensorWidth:      c.SensorWidth,
+		FStop:            c.FStop,
+		ApertureBlades:   c.ApertureBlades,
+		ApertureRotation: c.ApertureRotation,
+		ShutterOpen:      c.ShutterOpen,
+		ShutterClose:     c.ShutterClose,
+		ISO:              c.ISO,
+		ExposureTime:     c.ExposureTime,
+	}
+	if c.Projection != 0 && c.Projection != camera.PERSPECTIVE {
+		e.spec.Camera.Projection = c.Projection.String()
+	}
+	if c.Stereo != 0 && c.Stereo != camera.MONO {
+		e.spec.Camera.Stereo = c.Stereo.String()
+	}
+	if d := c.Distortion; d != (camera.Distortion{}) {
+		e.spec.Camera.Distortion = []float64{d.K1, d.K2, d.P1, d.P2, d.K3}
+	}
+	// a physical lens takes the place of the settings it overrides
+	if c.FocalLength > 0 {
+		e.spec.Camera.VerticalFOV = 0
+		if c.FStop > 0 {
+			e.spec.Camera.DefocusAngle = 0
+		}
+	} else if c.Lens == nil {
+		e.spec.Camera.SensorWidth = 0
+	}
+	if c.ShutterCurve != 0 && c.ShutterCurve != camera.BOX {
+		e.spec.Camera.ShutterCurve = c.ShutterCurve.String()
+	}
+	if c.ApertureMask != nil {
+		aperture, err := e.texture(c.ApertureMask)
+		if err != nil {
+			return fmt.Errorf("aperture mask: %w", err)
+		}
+		e.spec.Camera.Aperture = aperture
+	}
+	if c.Lens != nil {
+		if c.Lens.File == "" {
+			return errors.New("cannot export a lens system which was not loaded from a file")
+		}
+		e.spec.Camera.Lens = e.path(c.Lens.File)
+		// the lens system takes the place of the thin lens and the view it gives
+		cs := &e.spec.Camera
+		cs.VerticalFOV, cs.FocalLength, cs.DefocusAngle = 0, 0, 0
+		cs.ShiftX, cs.ShiftY, cs.TiltX, cs.TiltY, cs.Distortion = 0, 0, 0, 0, nil
+	}
+	e.spec.Background = toVector(c.Background)
+	return nil
+}
+
+func (e *exporter) object(h hittable.Hittable) (objectSpec, error) {
+	d, ok := hittable.Describe(h)
+	if !ok {
+		return objectSpec{}, fmt.Errorf("cannot export objects of type %T", h)
+	}
+	spec := objectSpec{}
+	var err error
+	switch d.Shape {
+	case hittable.SPHERE:
+		spec = objectSpec{Type: "sphere", Center: toVector(d.Center), Center2: toVector(d.Center2), Radius: d.Radius}
+	case hittable.QUAD:
+		spec = objectSpec{Type: "quad", Q: toVector(d.Q), U: toVector(d.U), V: toVector(d.V)}
+	case hittable.BOX:
+		spec = objectSpec{Type: "box", Min: toVector(d.Min), Max: toVector(d.Max)}
+	case hittable.TRIANGLE:
+		spec = objectSpec{Type: "triangle", UVs: d.UVs}
+		for _, v := range d.Vertices {
+			spec.Vertices = append(spec.Vertices, toVector(v))
+		}
+		for _, n := range d.Normals {
+			spec.Normals = append(spec.Normals, toVector(n))
+		}
+	case hittable.MEDIUM:
+		boundary, err := e.object(d.Children[0])
+		if err != nil {
+			return spec, err
+		}
+		phase, ok := hittable.DescribeMaterial(d.Material)
+		if !ok || phase.Kind != hittable.ISOTROPIC {
+			return spec, fmt.Errorf("cannot export a medium with a %T phase function", d.Material)
+		}
+		spec = objectSpec{Type: "medium", Boundary: &boundary, Density: d.Density}
+		if spec.Albedo, err = e.texture(phase.Texture); err != nil {
+			return spec, err
+		}
+		return e.identify(h, spec), nil
+	case hittable.LIST, hittable.BVH:
+		spec = objectSpec{Type: "group", BVH: d.Shape == hittable.BVH}
+		for _, child := range d.Children {
+			member, err := e.object(child)
+			if err != nil {
+				return spec, err
+			}
+			spec.Objects = append(spec.Objects, member)
+		}
+		return e.identify(h, spec), nil
+	case hittable.TRANSLATE, hittable.ROTATE_Y:
+		// transforms are listed innermost first, the order they are applied in
+		if spec, err = e.object(d.Children[0]); err != nil {
+			return spec, err
+		}
+		step := transformSpec{Translate: toVector(d.Offset)}
+		if d.Shape == hittable.ROTATE_Y {
+			step = transformSpec{RotateY: &d.Angle}
+		}
+		spec.Transform = append(spec.Transform, step)
+		return e.identify(h, spec), nil
+	}
+
+	if spec.Material, err = e.material(d.Material); err != nil {
+		return spec, err
+	}
+	return e.identify(h, spec), nil
+}
+
+// Gives an object its id if it is a light which has not been written yet.
+func (e *exporter) identify(h hittable.Hittable, spec objectSpec) objectSpec {
+	if id, ok := e.ids[h]; ok && !e.written[h] {
+		spec.ID = id
+	}
+	e.written[h] = true
+	return spec
+}
+
+// Returns a reference to a named material, adding it to the scene the first time it is used.
+func (e *exporter) material(m hittable.Material) (json.RawMessage, error) {
+	if name, ok := e.materials[m]; ok {
+		return reference(name), nil
+	}
+	d, ok := hittable.DescribeMaterial(m)
+	if !ok {
+		return nil, fmt.Errorf("cannot export materials of type %T", m)
+	}
+	spec := materialSpec{}
+	var err error
+	switch d.Kind {
+	case hittable.LAMBERTIAN:
+		spec.Type = "lambertian"
+		spec.Albedo, err = e.texture(d.Texture)
+	case hittable.METAL:
+		spec = materialSpec{Type: "metal", Albedo: reference(toVector(d.Albedo)), Fuzz: d.Fuzz}
+	case hittable.DIELECTRIC:
+		spec = materialSpec{Type: "dielectric", IOR: d.RefractionIndex}
+	case hittable.DIFFUSE_LIGHT:
+		spec.Type = "diffuse_light"
+		spec.Emit, err = e.texture(d.Texture)
+	case hittable.ISOTROPIC:
+		spec.Type = "isotropic"
+		spec.Albedo, err = e.texture(d.Texture)
+	}
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s%d", spec.Type, len(e.spec.Materials)+1)
+	e.spec.Materials[name] = spec
+	e.materials[m] = name
+	return reference(name), nil
+}
+
+// Returns a texture reference, solid colors are written as a color and other textures inline.
+func (e *exporter) texture(t hittable.Texture) (json.RawMessage, error) {
+	d, ok := hittable.DescribeTexture(t)
+	if !ok {
+		return nil, fmt.Errorf("cannot export textures of type %T", t)
+	}
+	spec := textureSpec{}
+	switch d.Kind {
+	case hittable.SOLID:
+		return reference(toVector(d.Color)), nil
+	case hittable.CHECKER:
+		even, err := e.texture(d.Even)
+		if err != nil {
+			return nil, err
+		}
+		odd, err := e.texture(d.Odd)
+		if err != nil {
+			return nil, err
+		}
+		spec = textureSpec{Type: "checker", Scale: d.Scale, Even: even, Odd: odd}
+	case hittable.IMAGE:
+		spec = textureSpec{Type: "image", File: e.path(d.File)}
+	case hittable.NOISE:
+		spec = textureSpec{Type: "noise", Scale: d.Scale, Variant: "perlin"}
+		switch d.Variant {
+		case hittable.MARBLE:
+			spec.Variant = "marble"
+		case hittable.TURBULENT:
+			spec.Variant = "turbulent"
+		}
+	}
+	return reference(spec), nil
+}
+
+// Encodes a name, color or inline description as a reference in the scene.
+// Returns a file name relative to the scene's directory, or as it was given when there is none.
+func (e *exporter) path(file string) string {
+	if e.dir != "" {
+		if abs, err := filepath.Abs(file); err == nil {
+			if rel, err := filepath.Rel(e.dir, abs); err == nil {
+				return rel
+			}
+		}
+	}
+	return file
+}
+
+func reference(v any) json.RawMessage {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+func toVector(v *vec.Vec3) vector {
+	if v == nil {
+		return nil
+	}
+	return vector{v.X(), v.Y(), v.Z()}
+}
@@ -0,0 +1,152 @@
+package scene_test
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/interval"
+	"github.com/nsp5488/go_raytracer/internal/lens"
+	"github.com/nsp5488/go_raytracer/internal/ray"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	white := hittable.NewLambertian(vec.New(.73, .73, .73))
+	checker := hittable.NewTexturedLambertian(hittable.NewCheckerboardColors(.5, vec.New(1, 0, 0), vec.New(0, 0, 1)))
+	light := hittable.NewQuad(vec.New(-1, 4, -1), vec.New(2, 0, 0), vec.New(0, 0, 2), hittable.NewDiffuseLight(vec.New(4, 4, 4)))
+
+	world := hittable.NewHittableList(5)
+	world.Add(hittable.NewSphere(vec.New(0, -100, 0), 99, checker))
+	world.Add(hittable.NewMotionSphere(vec.New(-2, 0, 0), vec.New(-2, .5, 0), .5, hittable.NewMetal(vec.New(.8, .8, .8), .2)))
+	box := hittable.NewBox(vec.New(0, -1, 0), vec.New(1, 1, 1), white)
+	world.Add(hittable.Translate(hittable.RotateY(box, 30), vec.New(1, 0, 0)))
+	world.Add(hittable.ConstantMedium(hittable.NewSphere(vec.New(0, 8, -3), 1, hittable.NewDielectric(1.5)), .5, vec.New(1, 1, 1)))
+	world.Add(light)
+	lights := hittable.NewHittableList(1)
+	lights.Add(light)
+
+	c := camera.Camera{Width: 80, VerticalFOV: 30, Projection: camera.ORTHOGRAPHIC, OrthoHeight: 4, Stereo: camera.TOP_BOTTOM, Interocular: 0.1}
+	c.PositionCamera(vec.New(0, 1, 10), vec.New(0, 0, 0), vec.New(0, 1, 0))
+	out := &bytes.Buffer{}
+	if err := scene.Export(out, &c, hittable.BuildBVH(world), lights); err != nil {
+		t.Fatalf("Expected the scene to export, but got %v", err)
+	}
+
+	loaded := camera.Camera{}
+	s, err := scene.Parse(out.Bytes(), ".", &loaded)
+	if err != nil {
+		t.Fatalf("Expected the exported scene to parse, but got %v\n%s", err, out)
+	}
+	if loaded.Width != 80 || loaded.VerticalFOV != 30 || loaded.Projection != camera.ORTHOGRAPHIC || loaded.OrthoHeight != 4 {
+		t.Errorf("Expected the camera settings to round trip, but got width %v, fov %v and a %v projection %v high",
+			loaded.Width, loaded.VerticalFOV, loaded.Projection, loaded.OrthoHeight)
+	}
+	if loaded.Stereo != camera.TOP_BOTTOM || loaded.Interocular != 0.1 {
+		t.Errorf("Expected the stereo settings to round trip, but got %v %v apart", loaded.Stereo, loaded.Interocular)
+	}
+
+	// the medium is out of the way of these rays, everything else must be hit at the same points
+	for x := -3.0; x <= 3; x += .25 {
+		for y := -1.5; y <= 3; y += .25 {
+			r := ray.NewWithTime(vec.New(x, y, 10), vec.New(0, 0, -1), .5)
+			exp, act := &hittable.HitRecord{}, &hittable.HitRecord{}
+			expHit := world.Hit(r, *interval.New(.001, 12), exp)
+			actHit := s.World.Hit(r, *interval.New(.001, 12), act)
+			if expHit != actHit {
+				t.Errorf("Expected hit %v, but got %v at (%v, %v)", expHit, actHit, x, y)
+			} else if expHit && exp.P().Sub(act.P()).Length() > 1e-9 {
+				t.Errorf("Expected %v, but got %v", exp.P(), act.P())
+			}
+		}
+	}
+
+	r := ray.New(vec.New(0, 0, 0), vec.New(0, 1, 0))
+	if !s.Lights.Hit(r, *interval.New(.001, math.Inf(1)), &hittable.HitRecord{}) {
+		t.Error("Expected the light to be sampled")
+	}
+}
+
+func TestExportPhysicalCamera(t *testing.T) {
+	world := hittable.NewHittableList(1)
+	world.Add(hittable.NewSphere(vec.New(0, 0, -5), 1, hittable.NewLambertian(vec.New(1, 1, 1))))
+	mask := hittable.NewCheckerboardColors(.25, vec.New(1, 1, 1), vec.New(0, 0, 0))
+	c := camera.Camera{VerticalFOV: 30, FocalLength: 85, FStop: 1.8, ApertureBlades: 6, ApertureRotation: 15, ApertureMask: mask,
+		ShutterOpen: 0.25, ShutterClose: 0.75, ShutterCurve: camera.COSINE, ISO: 400, ExposureTime: 0.01,
+		ShiftY: 0.2, TiltX: 30, Distortion: camera.Distortion{K1: -0.1, K2: 0.02, P1: 0.001, P2: -0.002, K3: 0.003}}
+	c.ApplyDefaults()
+	c.PositionCamera(nil, nil, nil)
+	out := &bytes.Buffer{}
+	if err := scene.Export(out, &c, world, hittable.NewHittableList(0)); err != nil {
+		t.Fatalf("Expected the scene to export, but got %v", err)
+	}
+
+	loaded := camera.Camera{}
+	if _, err := scene.Parse(out.Bytes(), ".", &loaded); err != nil {
+		t.Fatalf("Expected the exported scene to parse, but got %v\n%s", err, out)
+	}
+	if loaded.FocalLength != 85 || loaded.SensorWidth != 36 || loaded.FStop != 1.8 || loaded.ApertureBlades != 6 || loaded.ApertureRotation != 15 {
+		t.Errorf("Expected the lens to round trip, but got %vmm on a %vmm sensor at f/%v with %v blades turned %v degrees",
+			loaded.FocalLength, loaded.SensorWidth, loaded.FStop, loaded.ApertureBlades, loaded.ApertureRotation)
+	}
+	if loaded.ShiftY != 0.2 || loaded.TiltX != 30 || loaded.Distortion != c.Distortion {
+		t.Errorf("Expected the lens shift, tilt and distortion to round trip, but got %v, %v and %+v", loaded.ShiftY, loaded.TiltX, loaded.Distortion)
+	}
+	if loaded.ApertureMask == nil {
+		t.Error("Expected the aperture mask to round trip")
+	}
+	if loaded.ShutterOpen != 0.25 || loaded.ShutterClose != 0.75 || loaded.ShutterCurve != camera.COSINE || loaded.ISO != 400 || loaded.ExposureTime != 0.01 {
+		t.Errorf("Expected the shutter to round trip, but got a %v shutter from %v to %v, %vs at ISO %v",
+			loaded.ShutterCurve, loaded.ShutterOpen, loaded.ShutterClose, loaded.ExposureTime, loaded.ISO)
+	}
+}
+
+func TestExportLensSystem(t *testing.T) {
+	world := hittable.NewHittableList(1)
+	world.Add(hittable.NewSphere(vec.New(0, 0, -5), 1, hittable.NewLambertian(vec.New(1, 1, 1))))
+	system, err := lens.Load("../../scenes/lenses/dgauss.50mm.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := camera.Camera{Lens: system, FocusDistance: 5}
+	c.ApplyDefaults()
+	c.PositionCamera(nil, nil, nil)
+	out := &bytes.Buffer{}
+	if err := scene.Export(out, &c, world, hittable.NewHittableList(0)); err != nil {
+		t.Fatalf("Expected the scene to export, but got %v", err)
+	}
+
+	loaded := camera.Camera{}
+	if _, err := scene.Parse(out.Bytes(), ".", &loaded); err != nil {
+		t.Fatalf("Expected the exported scene to parse, but got %v\n%s", err, out)
+	}
+	if loaded.Lens == nil || len(loaded.Lens.Elements) != len(system.Elements) || loaded.SensorWidth != 36 || loaded.FocusDistance != 5 {
+		t.Errorf("Expected the lens system to round trip, but got %+v on a %vmm sensor focused at %v", loaded.Lens, loaded.SensorWidth, loaded.FocusDistance)
+	}
+
+	singlet, err := lens.Parse(strings.NewReader("50 2 1.5 20\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := camera.Camera{Lens: singlet}
+	parsed.ApplyDefaults()
+	parsed.PositionCamera(nil, nil, nil)
+	if err := scene.Export(&bytes.Buffer{}, &parsed, world, hittable.NewHittableList(0)); err == nil {
+		t.Error("Expected an error exporting a lens system without a file")
+	}
+}
+
+func TestExportLightOutsideWorld(t *testing.T) {
+	world := hittable.NewHittableList(1)
+	world.Add(hittable.NewSphere(vec.New(0, 0, 0), 1, hittable.NewLambertian(vec.New(1, 1, 1))))
+	light := hittable.NewSphere(vec.New(0, 5, 0), 1, hittable.NewDiffuseLight(vec.New(1, 1, 1)))
+
+	err := scene.Export(&bytes.Buffer{}, &camera.Camera{}, world, light)
+	if err == nil || !strings.Contains(err.Error(), "not part of the world") {
+		t.Errorf("Expected an error for the light outside the world, but got %v", err)
+	}
+}
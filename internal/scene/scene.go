@@ -0,0 +1,218 @@
+package scene
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/postfx"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+)
+
+// Scene is a world loaded from a scene description, ready to be rendered.
+type Scene struct {
+	World  hittable.Hittable
+	Lights hittable.Hittable
+	Output Output
+	// Warnings lists what the scene renders despite, such as a model's texture maps which can't be loaded. They are
+	// left to the caller to report.
+	Warnings Problems
+}
+
+// Output holds the scene's preferences for turning the render into an image.
+type Output struct {
+	Tonemap *tonemap.Mapper // nil if the scene does not choose a tone mapping
+	Denoise float64         // denoiser strength, 0 disables it
+	Post    postfx.Chain
+}
+
+// The top level of a scene file.
+type sceneSpec struct {
+	Camera     cameraSpec              `json:"camera,omitempty"`
+	Background vector                  `json:"background,omitempty"`
+	Textures   map[string]textureSpec  `json:"textures,omitempty"`
+	Materials  map[string]materialSpec `json:"materials,omitempty"`
+	Objects    []objectSpec            `json:"objects,omitempty"`
+	Lights     []string                `json:"lights,omitempty"`
+	Output     *outputSpec             `json:"output,omitempty"`
+}
+
+type cameraSpec struct {
+	AspectRatio     float64 `json:"aspect_ratio,omitempty"`
+	Width           int     `json:"width,omitempty"`
+	SamplesPerPixel int     `json:"samples_per_pixel,omitempty"`
+	MaxDepth        int     `json:"max_depth,omitempty"`
+	VerticalFOV     float64 `json:"vertical_fov,omitempty"`
+	LookFrom        vector  `json:"look_from,omitempty"`
+	LookAt          vector  `json:"look_at,omitempty"`
+	Up              vector  `json:"up,omitempty"`
+	DefocusAngle    float64 `json:"defocus_angle,omitempty"`
+	FocusDistance   float64 `json:"focus_distance,omitempty"`
+	MaxContribution float64 `json:"max_contribution,omitempty"`
+	Projection      string  `json:"projection,omitempty"` // perspective, orthographic, fisheye or equirectangular
+	OrthoHeight     float64 `json:"ortho_height,omitempty"`
+	Stereo          string  `json:"stereo,omitempty"` // mono, side-by-side or top-bottom
+	Interocular     float64 `json:"interocular,omitempty"`
+	Convergence     float64 `json:"convergence,omitempty"`
+	ShiftX          float64 `json:"shift_x,omitempty"`
+	ShiftY          float64 `json:"shift_y,omitempty"`
+	TiltX           float64 `json:"tilt_x,omitempty"`
+	TiltY           float64 `json:"tilt_y,omitempty"`
+	// Brown-Conrady coefficients in OpenCV's order: k1, k2, p1, p2 and k3, trailing ones may be left out
+	Distortion []float64 `json:"distortion,omitempty"`
+
+	// physical camera
+	FocalLength      float64         `json:"focal_length,omitempty"` // in millimeters
+	SensorWidth      float64         `json:"sensor_width,omitempty"` // in millimeters
+	FStop            float64         `json:"f_stop,omitempty"`
+	ApertureBlades   int             `json:"aperture_blades,omitempty"`
+	ApertureRotation float64         `json:"aperture_rotation,omitempty"`
+	Aperture         json.RawMessage `json:"aperture,omitempty"` // a mask, a color or texture reference
+	ShutterOpen      float64         `json:"shutter_open,omitempty"`
+	ShutterClose     float64         `json:"shutter_close,omitempty"`
+	ShutterCurve     string          `json:"shutter_curve,omitempty"` // box, triangle or cosine
+	ISO              float64         `json:"iso,omitempty"`
+	ExposureTime     float64         `json:"exposure_time,omitempty"` // in seconds
+	Lens             string          `json:"lens,omitempty"`          // a lens prescription file
+}
+
+// A texture: a solid color, checkerboard, image or noise.
+type textureSpec struct {
+	Type    string          `json:"type,omitempty"`
+	Color   vector          `json:"color,omitempty"`   // solid
+	Scale   float64         `json:"scale,omitempty"`   // checker, noise
+	Even    json.RawMessage `json:"even,omitempty"`    // checker, a color or texture reference
+	Odd     json.RawMessage `json:"odd,omitempty"`     // checker, a color or texture reference
+	File    string          `json:"file,omitempty"`    // image
+	Variant string          `json:"variant,omitempty"` // noise: perlin, marble or turbulent
+}
+
+// A material. Albedo and emit accept a color, the name of a texture or an inline texture.
+type materialSpec struct {
+	Type   string          `json:"type,omitempty"`
+	Albedo json.RawMessage `json:"albedo,omitempty"` // lambertian, metal (color only), isotropic
+	Fuzz   float64         `json:"fuzz,omitempty"`   // metal
+	IOR    float64         `json:"ior,omitempty"`    // dielectric
+	Emit   json.RawMessage `json:"emit,omitempty"`   // diffuse_light
+}
+
+// An object in the scene. Material accepts the name of a material or an inline material.
+type objectSpec struct {
+	ID        string          `json:"id,omitempty"`
+	Type      string          `json:"type,omitempty"`
+	Material  json.RawMessage `json:"material,omitempty"`
+	Transform []transformSpec `json:"transform,omitempty"`
+
+	// sphere
+	Center  vector  `json:"center,omitempty"`
+	Center2 vector  `json:"center2,omitempty"` // moving spheres travel from center to center2 while the shutter is open
+	Radius  float64 `json:"radius,omitempty"`
+	// quad
+	Q vector `json:"q,omitempty"`
+	U vector `json:"u,omitempty"`
+	V vector `json:"v,omitempty"`
+	// box
+	Min vector `json:"min,omitempty"`
+	Max vector `json:"max,omitempty"`
+	// triangle
+	Vertices []vector     `json:"vertices,omitempty"`
+	Normals  []vector     `json:"normals,omitempty"`
+	UVs      [][2]float64 `json:"uvs,omitempty"`
+	// medium
+	Boundary *objectSpec     `json:"boundary,omitempty"`
+	Density  float64         `json:"density,omitempty"`
+	Albedo   json.RawMessage `json:"albedo,omitempty"`
+	// group
+	Objects []objectSpec `json:"objects,omitempty"`
+	BVH     bool         `json:"bvh,omitempty"`
+	// obj
+	File          string  `json:"file,omitempty"`
+	Scale         float64 `json:"scale,omitempty"`
+	Recenter      bool    `json:"recenter,omitempty"`
+	Position      vector  `json:"position,omitempty"`
+	FlipYZ        bool    `json:"flip_yz,omitempty"`
+	FlipFaces     bool    `json:"flip_faces,omitempty"`
+	IgnoreNormals bool    `json:"ignore_normals,omitempty"`
+	IgnoreMtl     bool    `json:"ignore_mtl,omitempty"`
+	FindWindows   bool    `json:"find_windows,omitempty"`
+	Debug         bool    `json:"debug,omitempty"`
+}
+
+// A single transformation, applied in the order they are listed.
+type transformSpec struct {
+	Translate vector   `json:"translate,omitempty"`
+	RotateY   *float64 `json:"rotate_y,omitempty"`
+}
+
+type outputSpec struct {
+	Tonemap    string           `json:"tonemap,omitempty"`
+	Exposure   float64          `json:"exposure,omitempty"`
+	WhitePoint float64          `json:"white_point,omitempty"`
+	Denoise    float64          `json:"denoise,omitempty"`
+	Post       []map[string]any `json:"post,omitempty"` // each effect is named by its "effect" key, the others are its parameters
+}
+
+// A vector written as an array of three numbers. The length is checked by the builder so the problem can be
+// reported with its location.
+type vector []float64
+
+// Load reads a scene file, configures the camera and builds the world it describes.
+// Relative paths in the scene, such as OBJ includes and image textures, are resolved against the scene's directory.
+// If the scene has problems the error is a Problems listing all of them.
+func Load(filename string, c *camera.Camera) (*Scene, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s, problems := parse(data, filepath.Dir(filename), c, false, false)
+	if len(problems) > 0 {
+		return nil, problems.in(filename)
+	}
+	s.Warnings = s.Warnings.in(filename)
+	return s, nil
+}
+
+// Parse builds a scene from its JSON description, resolving relative paths against dir.
+// If the scene has problems the error is a Problems listing all of them.
+func Parse(data []byte, dir string, c *camera.Camera) (*Scene, error) {
+	s, problems := parse(data, dir, c, false, false)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return s, nil
+}
+
+// ParseConfined is Parse for scenes from clients which may only use the files in dir. Absolute paths and paths leading
+// out of dir are problems, which are reported without looking them up.
+func ParseConfined(data []byte, dir string, c *camera.Camera) (*Scene, error) {
+	s, problems := parse(data, dir, c, false, true)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return s, nil
+}
+
+func parse(data []byte, dir string, c *camera.Camera, dryRun, confined bool) (*Scene, Problems) {
+	spec := &sceneSpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, Problems{decodeProblem(data, err)}
+	}
+	b := newBuilder(spec, dir, dryRun)
+	b.confined = confined
+	var tree any
+	json.Unmarshal(data, &tree)
+	b.checkFields(tree, reflect.TypeOf(spec), "")
+	s := b.build(c)
+	if len(b.problems) == 0 {
+		if s != nil {
+			b.warnings.locate(data)
+			s.Warnings = b.warnings
+		}
+		return s, nil
+	}
+	b.problems.locate(data)
+	return nil, b.problems
+}
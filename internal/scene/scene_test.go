@@ -0,0 +1,270 @@
+package scene_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	"github.com/nsp5488/go_raytracer/internal/interval"
+	"github.com/nsp5488/go_raytracer/internal/ray"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+const simple = `{
+	"camera": {"width": 64, "samples_per_pixel": 4, "vertical_fov": 30, "look_from": [0, 0, 5], "look_at": [0, 0, 0]},
+	"background": [0.5, 0.7, 1],
+	"textures": {"checks": {"type": "checker", "scale": 0.5, "even": [1, 1, 1], "odd": [0, 0, 0]}},
+	"materials": {
+		"floor": {"type": "lambertian", "albedo": "checks"},
+		"lamp": {"type": "diffuse_light", "emit": [4, 4, 4]}
+	},
+	"objects": [
+		{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": {"type": "metal", "albedo": [0.8, 0.8, 0.8], "fuzz": 0.1}},
+		{"type": "quad", "q": [-5, -1, -5], "u": [10, 0, 0], "v": [0, 0, 10], "material": "floor"},
+		{"id": "lamp", "type": "sphere", "center": [0, 5, 0], "radius": 0.5, "material": "lamp"},
+		{"type": "box", "min": [0, 0, 0], "max": [1, 1, 1], "material": "floor", "transform": [{"rotate_y": 45}, {"translate": [3, -1, 0]}]}
+	],
+	"lights": ["lamp"],
+	"output": {"tonemap": "aces", "exposure": 1, "denoise": 0.5, "post": [{"effect": "vignette", "strength": 0.3}]}
+}`
+
+func TestParse(t *testing.T) {
+	c := camera.Camera{}
+	s, err := scene.Parse([]byte(simple), ".", &c)
+	if err != nil {
+		t.Fatalf("Expected the scene to parse, but got %v", err)
+	}
+	if c.Width != 64 || c.SamplesPerPixel != 4 || c.VerticalFOV != 30 {
+		t.Errorf("Expected the camera to be configured, but got width %v, %v samples and fov %v", c.Width, c.SamplesPerPixel, c.VerticalFOV)
+	}
+	if !c.Background.Equals(vec.New(.5, .7, 1)) {
+		t.Errorf("Expected %v, but got %v", vec.New(.5, .7, 1), c.Background)
+	}
+
+	rec := &hittable.HitRecord{}
+	r := ray.New(vec.New(0, 0, 5), vec.New(0, 0, -1))
+	if !s.World.Hit(r, *interval.New(0.001, 100), rec) {
+		t.Fatal("Expected the ray to hit the sphere")
+	}
+	if !rec.P().Equals(vec.New(0, 0, 1)) {
+		t.Errorf("Expected %v, but got %v", vec.New(0, 0, 1), rec.P())
+	}
+	if !s.Lights.Hit(ray.New(vec.New(0, 0, 0), vec.New(0, 1, 0)), *interval.New(0.001, 100), rec) {
+		t.Error("Expected the lights to contain the lamp")
+	}
+
+	exp := tonemap.Mapper{Operator: tonemap.ACES, Exposure: 1}
+	if s.Output.Tonemap == nil || *s.Output.Tonemap != exp {
+		t.Errorf("Expected %v, but got %v", exp, s.Output.Tonemap)
+	}
+	if s.Output.Denoise != .5 || len(s.Output.Post) != 1 {
+		t.Errorf("Expected denoising at 0.5 and 1 effect, but got %v and %d effects", s.Output.Denoise, len(s.Output.Post))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		`{"objects": []}`: "the scene is empty",
+		`{"objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": "missing"}]}`:         `objects[0].material: unknown material "missing"`,
+		`{"objects": [{"type": "sphere", "center": [0, 0], "radius": 1}]}`:                                   "objects[0].center: expected [x, y, z], but got 2 numbers",
+		`{"objects": [{"type": "cone"}]}`:                                                                    `objects[0].type: unknown object type "cone"`,
+		`{"objects": [{"type": "sphere", "centre": [0, 0, 0]}]}`:                                             `unknown field "centre"`,
+		`{"objects": [{"type": "group", "objects": [{"type": "quad", "q": [0, 0, 0], "u": [1, 0, 0]}]}]}`:    "objects[0].objects[0].v: missing",
+		`{"objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": {"type": "glass"}}]}`: `objects[0].material.type: unknown material type "glass"`,
+		`{"objects": [{"type": "obj", "file": "missing.obj"}]}`:                                              "objects[0].file",
+		`{"camera": {"projection": "cylindrical"}, "objects": []}`:                                           `camera.projection: unknown projection "cylindrical"`,
+		`{"camera": {"stereo": "anaglyph"}, "objects": []}`:                                                  `camera.stereo: unknown stereo layout "anaglyph"`,
+		`{"camera": {"interocular": -1}, "objects": []}`:                                                     "camera.interocular: must not be negative",
+		`{"camera": {"focal_length": 50, "vertical_fov": 40}, "objects": []}`:                                "camera.focal_length: conflicts with vertical_fov",
+		`{"camera": {"shutter_open": 0.5, "shutter_close": 0.2}, "objects": []}`:                             "camera.shutter_close: must be after shutter_open",
+		`{"camera": {"iso": 100}, "objects": []}`:                                                            "camera.iso: exposing needs f_stop and exposure_time",
+		`{"camera": {"projection": "fisheye", "shift_y": 0.2}, "objects": []}`:                               "camera.shift_y: only applies to the perspective and orthographic projections",
+		`{"camera": {"tilt_x": 90}, "objects": []}`:                                                          "camera.tilt_x: must be between -90 and 90 degrees",
+		`{"camera": {"distortion": [0, 0, 0, 0, 0, 0]}, "objects": []}`:                                      "camera.distortion: expected at most 5 coefficients",
+		`{"camera": {"lens": "lenses/dgauss.50mm.dat", "vertical_fov": 40}, "objects": []}`:                  "camera.vertical_fov: conflicts with lens",
+		`{"camera": {"lens": "missing.dat"}, "objects": []}`:                                                 "camera.lens",
+		`{"camera": {"ortho_height": 2}, "objects": []}`:                                                     "camera.ortho_height: only applies to the orthographic projection",
+		`{"camera": {"projection": "fisheye", "vertical_fov": 400}, "objects": []}`:                          "camera.vertical_fov: must be between 0 and 360 degrees",
+		`{"objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": "m"}], "lights": ["x"],
+		  "materials": {"m": {"type": "lambertian", "albedo": [1, 1, 1]}}}`: `lights[0]: unknown object id "x"`,
+		`{"objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": "m", "id": "a"},
+		              {"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": "m", "id": "a"}],
+		  "materials": {"m": {"type": "lambertian", "albedo": [1, 1, 1]}}}`: `objects[1].id: duplicate object id "a"`,
+		`{"objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": "m"}],
+		  "materials": {"m": {"type": "lambertian", "albedo": "t"}}, "textures": {"t": {"type": "checker", "scale": 1, "even": "t", "odd": [0, 0, 0]}}}`: `texture "t" refers to itself`,
+	}
+	for input, exp := range cases {
+		c := camera.Camera{}
+		_, err := scene.Parse([]byte(input), ".", &c)
+		if err == nil || !strings.Contains(err.Error(), exp) {
+			t.Errorf("Expected an error containing %q, but got %v for %s", exp, err, input)
+		}
+	}
+}
+
+func TestUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "corrupt.png"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.obj"), []byte("v 0 0 0\nv 1 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.dat"), []byte("50 2 1.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		`{"objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": {"type": "lambertian", "albedo": {"type": "image", "file": "corrupt.png"}}}]}`: "objects[0].material.albedo.file: could not decode image",
+		`{"objects": [{"type": "obj", "file": "broken.obj"}]}`: "objects[0].file: " + filepath.Join(dir, "broken.obj") + ": line 2: malformed vertex",
+		`{"camera": {"lens": "broken.dat"}, "objects": []}`:    "camera.lens: " + filepath.Join(dir, "broken.dat") + ": line 1",
+	}
+	for input, exp := range cases {
+		c := camera.Camera{}
+		_, err := scene.Parse([]byte(input), dir, &c)
+		if err == nil || !strings.Contains(err.Error(), exp) {
+			t.Errorf("Expected an error containing %q, but got %v for %s", exp, err, input)
+		}
+	}
+}
+
+func TestTransformedModelLights(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lamp.obj": "mtllib lamp.mtl\nusemtl glow\nv 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n",
+		"lamp.mtl": "newmtl glow\nKd 1 1 1\nKe 4 4 4\n",
+		"scene.json": `{
+  "objects": [
+    {"type": "obj", "file": "lamp.obj", "transform": [{"rotate_y": 90}, {"translate": [0, 0, -2]}]}
+  ]
+}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := camera.Camera{}
+	s, err := scene.Load(filepath.Join(dir, "scene.json"), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Warnings) > 0 {
+		t.Errorf("Expected no warnings, but got %v", s.Warnings)
+	}
+	// the lamp is turned to face along x and moved back, its emissive triangle must be sampled where it ended up
+	hits := func(origin, direction *vec.Vec3) bool {
+		return s.Lights.Hit(ray.New(origin, direction), *interval.New(.001, math.Inf(1)), &hittable.HitRecord{})
+	}
+	if hits(vec.New(.25, .25, 5), vec.New(0, 0, -1)) {
+		t.Error("Expected the untransformed triangle not to be sampled")
+	}
+	if !hits(vec.New(5, .25, -2.25), vec.New(-1, 0, 0)) && !hits(vec.New(5, .25, -1.75), vec.New(-1, 0, 0)) {
+		t.Error("Expected the transformed triangle to be sampled")
+	}
+}
+
+func TestTransformedLights(t *testing.T) {
+	lamps := map[string]string{
+		"box": `{"id": "lamp", "type": "box", "min": [-1, -1, -1], "max": [1, 1, 1], "material": "lamp", "transform": [{"rotate_y": 30}, {"translate": [0, 3, 0]}]}`,
+		"bvh": `{"id": "lamp", "type": "group", "bvh": true, "objects": [{"type": "sphere", "center": [0, 3, 0], "radius": 1, "material": "lamp"}, {"type": "quad", "q": [-1, 3, 2], "u": [2, 0, 0], "v": [0, 0, 2], "material": "lamp"}]}`,
+	}
+	for name, lamp := range lamps {
+		input := `{
+			"materials": {"lamp": {"type": "diffuse_light", "emit": [1, 1, 1]}},
+			"objects": [` + lamp + `],
+			"lights": ["lamp"]
+		}`
+		c := camera.Camera{}
+		s, err := scene.Parse([]byte(input), ".", &c)
+		if err != nil {
+			t.Errorf("%s: expected the light to load, but got %v", name, err)
+			continue
+		}
+		if !s.Lights.Hit(ray.New(vec.New(0, 0, 0), vec.New(0, 1, 0)), *interval.New(.001, math.Inf(1)), &hittable.HitRecord{}) {
+			t.Errorf("%s: expected the light to be sampled", name)
+		}
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	c := camera.Camera{}
+	s, err := scene.Load("../../scenes/cornell_box.json", &c)
+	if err != nil {
+		t.Fatalf("Expected the example scene to load, but got %v", err)
+	}
+	if s.World == nil || s.Lights == nil {
+		t.Error("Expected the example scene to have a world and lights")
+	}
+	if c.Width != 600 {
+		t.Errorf("Expected %v, but got %v", 600, c.Width)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	input := `{
+  "materials": {
+    "white": {"type": "lambertian", "albedo": "chalk"}
+  },
+  "objects": [
+    {"type": "sphere", "center": [0, 0, 0], "radius": -1, "material": "white"},
+    {"type": "quad", "q": [0, 0, 0], "u": [1, 0, 0], "v": [2, 0, 0], "material": "paint"},
+    {"type": "sphere", "center": [0, 3, 0], "radius": 1, "material": "white", "colour": [1, 0, 0]}
+  ],
+  "lights": ["sun"]
+}`
+	exp := []string{
+		`3:37: materials.white.albedo: unknown texture "chalk"`,
+		`6:45: objects[0].radius: must be positive, but got -1`,
+		`7:5: objects[1]: degenerate quad, u and v must not be parallel or zero`,
+		`7:70: objects[1].material: unknown material "paint"`,
+		`8:79: objects[2].colour: unknown field "colour"`,
+		`10:14: lights[0]: unknown object id "sun", lights must refer to an object in the world`,
+	}
+	problems := scene.Validate([]byte(input), ".")
+	if len(problems) != len(exp) {
+		t.Fatalf("Expected %d problems, but got %d:\n%v", len(exp), len(problems), problems)
+	}
+	for i, p := range problems {
+		if p.Error() != exp[i] {
+			t.Errorf("Expected %v, but got %v", exp[i], p.Error())
+		}
+	}
+}
+
+func TestValidateUnusedDefinitions(t *testing.T) {
+	input := `{
+  "textures": {"photo": {"type": "image", "file": "missing.png"}},
+  "materials": {"white": {"type": "lambertian", "albedo": [1, 1, 1]}, "shiny": {"type": "metl"}},
+  "objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": "white"}]
+}`
+	exp := []string{
+		"2:43: textures.photo.file",
+		`3:81: materials.shiny.type: unknown material type "metl"`,
+	}
+	problems := scene.Validate([]byte(input), ".")
+	if len(problems) != len(exp) {
+		t.Fatalf("Expected %d problems, but got %d:\n%v", len(exp), len(problems), problems)
+	}
+	for i, p := range problems {
+		if !strings.HasPrefix(p.Error(), exp[i]) {
+			t.Errorf("Expected %v, but got %v", exp[i], p.Error())
+		}
+	}
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	problems := scene.Validate([]byte("{\n  \"objects\": [\n    {\"type\": \"sphere\",}\n  ]\n}"), ".")
+	if len(problems) != 1 || problems[0].Line != 3 {
+		t.Errorf("Expected a single problem on line 3, but got %v", problems)
+	}
+	problems = scene.Validate([]byte("{\n  \"objects\": [{\"type\": \"sphere\", \"radius\": \"big\"}]\n}"), ".")
+	if len(problems) != 1 || problems[0].Line != 2 || !strings.Contains(problems[0].Message, "expected float64, but got string") {
+		t.Errorf("Expected a type error on line 2, but got %v", problems)
+	}
+}
@@ -0,0 +1,254 @@
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+)
+
+// Problem is a mistake in a scene description and where it was found.
+type Problem struct {
+	File    string // empty when the scene was not read from a file
+	Line    int    // 1-based, 0 if the location is unknown
+	Column  int
+	Path    string // the offending value, e.g. objects[2].material
+	Message string
+}
+
+func (p Problem) Error() string {
+	var sb strings.Builder
+	if p.File != "" {
+		sb.WriteString(p.File + ":")
+	}
+	if p.Line > 0 {
+		fmt.Fprintf(&sb, "%d:%d:", p.Line, p.Column)
+	}
+	if sb.Len() > 0 {
+		sb.WriteString(" ")
+	}
+	if p.Path != "" {
+		sb.WriteString(p.Path + ": ")
+	}
+	sb.WriteString(p.Message)
+	return sb.String()
+}
+
+// Problems lists everything wrong with a scene, one problem per line.
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	lines := make([]string, len(ps))
+	for i, p := range ps {
+		lines[i] = p.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Attributes the problems to a file.
+func (ps Problems) in(filename string) Problems {
+	for i := range ps {
+		ps[i].File = filename
+	}
+	return ps
+}
+
+// Fills in the line and column of each problem from its path and sorts them by their position in the file.
+func (ps Problems) locate(data []byte) {
+	offsets := indexOffsets(data)
+	for i := range ps {
+		path := ps[i].Path
+		offset, ok := offsets[path]
+		// values which are missing are reported at the closest enclosing value
+		for !ok && path != "" {
+			path = parentPath(path)
+			offset, ok = offsets[path]
+		}
+		ps[i].Line, ps[i].Column = lineColumn(data, offset)
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].Line != ps[j].Line {
+			return ps[i].Line < ps[j].Line
+		}
+		return ps[i].Column < ps[j].Column
+	})
+}
+
+// Validate checks a scene description without building it, returning every problem found.
+// Referenced files are checked to exist but images and models are not loaded.
+func Validate(data []byte, dir string) Problems {
+	_, problems := parse(data, dir, &camera.Camera{}, true, false)
+	return problems
+}
+
+// ValidateFile checks a scene file, see Validate. The error is only set if the file cannot be read.
+func ValidateFile(filename string) (Problems, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Validate(data, filepath.Dir(filename)).in(filename), nil
+}
+
+// Converts an error from decoding the scene into a problem, locating it if the decoder says where it happened.
+func decodeProblem(data []byte, err error) Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	p := Problem{Message: strings.TrimPrefix(err.Error(), "json: ")}
+	switch {
+	case errors.As(err, &syntaxErr):
+		p.Line, p.Column = lineColumn(data, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		p.Line, p.Column = lineColumn(data, typeErr.Offset)
+		p.Path = typeErr.Field
+		p.Message = fmt.Sprintf("expected %s, but got %s", typeErr.Type, typeErr.Value)
+	}
+	return p
+}
+
+// The types inline textures and materials are decoded into, by the name of the field which holds them.
+var inlineTypes = map[string]reflect.Type{
+	"material": reflect.TypeOf(materialSpec{}),
+	"albedo":   reflect.TypeOf(textureSpec{}),
+	"emit":     reflect.TypeOf(textureSpec{}),
+	"even":     reflect.TypeOf(textureSpec{}),
+	"odd":      reflect.TypeOf(textureSpec{}),
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// Reports keys of the decoded JSON value which don't match a field of the type they are decoded into, usually typos.
+func (b *builder) checkFields(value any, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			fields[name] = t.Field(i).Type
+		}
+		for _, key := range sortedKeys(obj) {
+			field, ok := fields[key]
+			if !ok {
+				b.fail(joinPath(path, key), "unknown field %q", key)
+				continue
+			}
+			if field == rawMessageType {
+				if field, ok = inlineTypes[key]; !ok {
+					continue
+				}
+			}
+			b.checkFields(obj[key], field, joinPath(path, key))
+		}
+	case reflect.Slice:
+		if arr, ok := value.([]any); ok {
+			for i, v := range arr {
+				b.checkFields(v, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case reflect.Map:
+		if obj, ok := value.(map[string]any); ok {
+			for _, key := range sortedKeys(obj) {
+				b.checkFields(obj[key], t.Elem(), joinPath(path, key))
+			}
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Returns the path of the value containing the one at path, e.g. objects[2] for objects[2].material.
+func parentPath(path string) string {
+	i := strings.LastIndexAny(path, ".[")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+// Maps the path of every value in a JSON document to the byte offset it starts at.
+// Object members are located at their key so problems point at the name of the field.
+func indexOffsets(data []byte) map[string]int64 {
+	offsets := map[string]int64{"": skipSeparators(data, 0)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				offset := skipSeparators(data, dec.InputOffset())
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				member := joinPath(path, key.(string))
+				offsets[member] = offset
+				if err := walk(member); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				element := fmt.Sprintf("%s[%d]", path, i)
+				offsets[element] = skipSeparators(data, dec.InputOffset())
+				if err := walk(element); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	// a malformed document is reported by the decoder, the offsets found until then are still useful
+	walk("")
+	return offsets
+}
+
+// Skips the whitespace, commas and colons between JSON tokens.
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// Converts a byte offset into a 1-based line and column.
+func lineColumn(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, column
+}
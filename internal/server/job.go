@@ -0,0 +1,251 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/aov"
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/denoise"
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+)
+
+// The stage a job is in.
+type State string
+
+const (
+	QUEUED    State = "queued"
+	RUNNING   State = "running"
+	DONE      State = "done"
+	CANCELLED State = "cancelled"
+	FAILED    State = "failed"
+)
+
+// Settings override those of a job's scene. Zero values keep the scene's settings.
+type Settings struct {
+	Width           int      `json:"width,omitempty"`
+	AspectRatio     float64  `json:"aspect_ratio,omitempty"`
+	SamplesPerPixel int      `json:"samples_per_pixel,omitempty"`
+	MaxDepth        int      `json:"max_depth,omitempty"`
+	Seed            uint64   `json:"seed,omitempty"`    // seeds the camera's sampler, random by default
+	Threads         int      `json:"threads,omitempty"` // workers rendering the job, the server's default when unset
+	TimeBudget      string   `json:"time_budget,omitempty"`
+	Format          string   `json:"format,omitempty"` // the image format, png by default
+	Tonemap         string   `json:"tonemap,omitempty"`
+	Exposure        *float64 `json:"exposure,omitempty"`
+}
+
+// Applies the settings to a camera set up by the scene.
+func (s Settings) apply(c *camera.Camera, threads int) error {
+	if s.Width < 0 || s.AspectRatio < 0 || s.SamplesPerPixel < 0 || s.MaxDepth < 0 || s.Threads < 0 {
+		return errors.New("width, aspect_ratio, samples_per_pixel, max_depth and threads must not be negative")
+	}
+	if s.Width > 0 {
+		c.Width = s.Width
+	}
+	if s.AspectRatio > 0 {
+		c.AspectRatio = s.AspectRatio
+	}
+	if s.SamplesPerPixel > 0 {
+		c.SamplesPerPixel = s.SamplesPerPixel
+	}
+	if s.MaxDepth > 0 {
+		c.MaxDepth = s.MaxDepth
+	}
+	c.Seed = s.Seed
+	c.MaxThreads = threads
+	if s.Threads > 0 {
+		c.MaxThreads = s.Threads
+	}
+	if s.TimeBudget != "" {
+		budget, err := time.ParseDuration(s.TimeBudget)
+		if err != nil || budget <= 0 {
+			return fmt.Errorf("invalid time_budget %q, expected a positive duration such as 30s or 5m", s.TimeBudget)
+		}
+		c.TimeBudget = budget
+	}
+	return nil
+}
+
+// Status describes a job as reported by the API.
+type Status struct {
+	ID        string     `json:"id"`
+	State     State      `json:"state"`
+	Width     int        `json:"width"`
+	Height    int        `json:"height"`
+	Done      int        `json:"done"`  // tiles rendered
+	Total     int        `json:"total"` // tiles to render, known once the job starts
+	Percent   float64    `json:"percent"`
+	Error     string     `json:"error,omitempty"`
+	Submitted time.Time  `json:"submitted"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Image     string     `json:"image,omitempty"` // path of the image, once there is one
+}
+
+// A render job. It follows its own progress as the camera's progress reporter.
+type job struct {
+	id            string
+	camera        *camera.Camera
+	scene         *scene.Scene
+	enc           encoder.Encoder
+	contentType   string
+	denoise       *denoise.Options // requested by the scene
+	width, height int
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	mu                sync.Mutex
+	state             State
+	done, total       int
+	err               error
+	submitted         time.Time
+	started, finished time.Time
+	image             []byte // the encoded image, once the job has ended
+}
+
+func (j *job) Start(total int) {
+	j.mu.Lock()
+	j.done, j.total = 0, total
+	j.mu.Unlock()
+}
+
+func (j *job) Advance(n int) {
+	j.mu.Lock()
+	j.done += n
+	j.mu.Unlock()
+}
+
+func (j *job) Finish() {}
+
+// Renders the job, unless it was cancelled while queued, and encodes its image.
+func (j *job) run() {
+	j.mu.Lock()
+	if j.state != QUEUED {
+		j.mu.Unlock()
+		return
+	}
+	j.state = RUNNING
+	j.started = time.Now()
+	j.mu.Unlock()
+	defer j.cancel()
+
+	log.Printf("Rendering job %s", j.id)
+	image, err := j.render()
+	cancelled := errors.Is(err, context.Canceled)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = time.Now()
+	j.image = image
+	switch {
+	case cancelled:
+		j.state = CANCELLED
+	case err != nil:
+		j.state, j.err = FAILED, err
+	default:
+		j.state = DONE
+	}
+	log.Printf("Job %s %s after %s", j.id, j.state, j.finished.Sub(j.started).Round(time.Millisecond))
+}
+
+// Renders and encodes the job's image, which is also kept when the render is cancelled. A panic fails the job
+// rather than taking the server and every other job down with it.
+func (j *job) render() (image []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v\n%s", j.id, r, debug.Stack())
+			image, err = nil, fmt.Errorf("the render failed: %v", r)
+		}
+	}()
+	err = j.camera.Render(j.ctx, j.scene.World, j.scene.Lights)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	image, encErr := j.encode()
+	if encErr != nil {
+		return nil, encErr
+	}
+	return image, err
+}
+
+// Denoises, post-processes and encodes the rendered image.
+func (j *job) encode() ([]byte, error) {
+	fb := j.camera.Image()
+	if j.denoise != nil {
+		var err error
+		fb, err = denoise.Denoise(fb, j.camera.AOV(aov.ALBEDO), j.camera.AOV(aov.SHADING_NORMAL), *j.denoise)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fb = j.scene.Output.Post.Apply(fb)
+	var buf bytes.Buffer
+	if err := j.enc.Encode(&buf, fb); err != nil {
+		return nil, fmt.Errorf("error encoding the image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Cancels the job if it is queued or running, reporting whether it was.
+func (j *job) stop() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch j.state {
+	case QUEUED:
+		j.state = CANCELLED
+		j.finished = time.Now()
+	case RUNNING:
+		// the state changes once the render has stopped
+	default:
+		return false
+	}
+	j.cancel()
+	return true
+}
+
+// Returns the encoded image, nil until the job has ended with one, and the job's state.
+func (j *job) result() ([]byte, State) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.image, j.state
+}
+
+func (j *job) status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := Status{
+		ID:        j.id,
+		State:     j.state,
+		Width:     j.width,
+		Height:    j.height,
+		Done:      j.done,
+		Total:     j.total,
+		Submitted: j.submitted,
+	}
+	if j.total > 0 {
+		s.Percent = 100 * float64(min(j.done, j.total)) / float64(j.total)
+	}
+	if j.err != nil {
+		s.Error = j.err.Error()
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		s.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		s.Finished = &finished
+	}
+	if j.image != nil {
+		s.Image = "/jobs/" + j.id + "/image"
+	}
+	return s
+}
@@ -0,0 +1,333 @@
+// Package server runs render jobs submitted over HTTP. Jobs carry a scene description and settings overriding it,
+// wait in a queue and are rendered a few at a time, and their images are kept in memory until they are deleted.
+//
+//	POST   /jobs            submit {"scene": {...}, "settings": {...}}, responds with the job's status
+//	GET    /jobs            list the status of every job
+//	GET    /jobs/{id}       the status of a job, with its progress in tiles
+//	GET    /jobs/{id}/image the rendered image, once the job is done or was cancelled while rendering
+//	DELETE /jobs/{id}       cancel a queued or running job, or delete a finished one
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/aov"
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/denoise"
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+)
+
+// Config controls how many jobs are run and the resources they get.
+type Config struct {
+	Concurrency int    // jobs rendered at the same time
+	Threads     int    // default number of workers for each job
+	QueueSize   int    // jobs which can wait to be rendered, further submissions are refused
+	MaxPixels   int    // largest image a job may render, in pixels, since its framebuffer is allocated up front
+	Dir         string // directory the files named in scenes are read from, they can't name files outside of it
+}
+
+// Returns the configuration used by the serve command unless overridden: one job at a time on 4 threads,
+// with up to 64 jobs waiting and images up to 8K UHD.
+func DefaultConfig() Config {
+	return Config{Concurrency: 1, Threads: 4, QueueSize: 64, MaxPixels: 7680 * 4320, Dir: "."}
+}
+
+// The largest request body accepted, scenes are small but may inline large triangle lists.
+const maxRequestSize = 64 << 20
+
+// Server queues render jobs and serves their status and images. It is an http.Handler.
+type Server struct {
+	config  Config
+	handler http.Handler
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	order  []string // job ids in submission order
+	nextID int
+	queue  chan *job
+	closed bool
+
+	workers sync.WaitGroup
+}
+
+// Creates a server and starts its workers, which run until Close is called.
+func New(config Config) *Server {
+	config.Concurrency = max(config.Concurrency, 1)
+	config.Threads = max(config.Threads, 1)
+	config.QueueSize = max(config.QueueSize, 1)
+	if config.MaxPixels <= 0 {
+		config.MaxPixels = DefaultConfig().MaxPixels
+	}
+	s := &Server{
+		config: config,
+		jobs:   map[string]*job{},
+		queue:  make(chan *job, config.QueueSize),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.submit)
+	mux.HandleFunc("GET /jobs", s.list)
+	mux.HandleFunc("GET /jobs/{id}", s.status)
+	mux.HandleFunc("GET /jobs/{id}/image", s.image)
+	mux.HandleFunc("DELETE /jobs/{id}", s.delete)
+	s.handler = mux
+
+	for range config.Concurrency {
+		s.workers.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Close refuses new jobs, cancels the queued and running ones and waits for the workers to stop.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.queue)
+	for _, j := range s.jobs {
+		j.stop()
+	}
+	s.mu.Unlock()
+	s.workers.Wait()
+}
+
+// Renders queued jobs until the queue is closed.
+func (s *Server) work() {
+	defer s.workers.Done()
+	for j := range s.queue {
+		j.run()
+	}
+}
+
+// The body of a job submission.
+type request struct {
+	Scene    json.RawMessage `json:"scene"`
+	Settings Settings        `json:"settings"`
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var req request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if len(req.Scene) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("the request has no scene"))
+		return
+	}
+
+	j, err := s.newJob(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	samples := j.camera.SamplesPerPixel // the camera belongs to the worker once the job is queued
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, errors.New("the server is shutting down"))
+		return
+	}
+	// the id is set before the job is queued, where a worker may pick it up
+	j.id = strconv.Itoa(s.nextID + 1)
+	select {
+	case s.queue <- j:
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("the queue is full, %d jobs are waiting", s.config.QueueSize))
+		return
+	}
+	s.nextID++
+	s.jobs[j.id] = j
+	s.order = append(s.order, j.id)
+	s.mu.Unlock()
+
+	log.Printf("Queued job %s: %dx%d, %d samples per pixel", j.id, j.width, j.height, samples)
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeJSON(w, http.StatusAccepted, j.status())
+}
+
+// Builds a job from a submission, checking its scene and settings.
+func (s *Server) newJob(req request) (*job, error) {
+	c := &camera.Camera{}
+	sc, err := scene.ParseConfined(req.Scene, s.config.Dir, c)
+	if err != nil {
+		return nil, err
+	}
+	settings := req.Settings
+	if err := settings.apply(c, s.config.Threads); err != nil {
+		return nil, err
+	}
+
+	format := settings.Format
+	if format == "" {
+		format = "png"
+	}
+	enc, err := encoder.ByName(format)
+	if err != nil {
+		return nil, err
+	}
+	mapper := tonemap.Mapper{}
+	if sc.Output.Tonemap != nil {
+		mapper = *sc.Output.Tonemap
+	}
+	if settings.Tonemap != "" {
+		if mapper.Operator, err = tonemap.ParseOperator(settings.Tonemap); err != nil {
+			return nil, err
+		}
+	}
+	if settings.Exposure != nil {
+		mapper.Exposure = *settings.Exposure
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		camera:      c,
+		scene:       sc,
+		enc:         encoder.WithTonemap(enc, mapper),
+		contentType: contentTypes[format],
+		ctx:         ctx,
+		cancel:      cancel,
+		state:       QUEUED,
+		submitted:   time.Now(),
+	}
+	if sc.Output.Denoise > 0 {
+		opts := denoise.DefaultOptions()
+		opts.Strength = min(sc.Output.Denoise, 1)
+		j.denoise = &opts
+		c.AOVs = []aov.Kind{aov.ALBEDO, aov.SHADING_NORMAL}
+	}
+	c.ApplyDefaults()
+	j.width, j.height = c.ImageSize()
+	if j.width > s.config.MaxPixels || j.height > s.config.MaxPixels || j.width*j.height > s.config.MaxPixels {
+		cancel()
+		return nil, fmt.Errorf("the %dx%d image is larger than the %d pixels the server renders", j.width, j.height, s.config.MaxPixels)
+	}
+	c.Progress = j
+	return j, nil
+}
+
+// Returns the job with the id in the request's path, writing a 404 response if there is none.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *job {
+	s.mu.Lock()
+	j, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", r.PathValue("id")))
+		return nil
+	}
+	return j
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := make([]*job, len(s.order))
+	for i, id := range s.order {
+		jobs[i] = s.jobs[id]
+	}
+	s.mu.Unlock()
+
+	statuses := make([]Status, len(jobs))
+	for i, j := range jobs {
+		statuses[i] = j.status()
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	if j := s.lookup(w, r); j != nil {
+		writeJSON(w, http.StatusOK, j.status())
+	}
+}
+
+func (s *Server) image(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	data, state := j.result()
+	if data == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("job %s has no image, it is %s", j.id, state))
+		return
+	}
+	w.Header().Set("Content-Type", j.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// Cancels an active job, which is kept so its status and partial image can still be read, or deletes a finished one.
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	if j.stop() {
+		writeJSON(w, http.StatusAccepted, j.status())
+		return
+	}
+	s.mu.Lock()
+	delete(s.jobs, j.id)
+	for i, id := range s.order {
+		if id == j.id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Content types of the encoded images, by format name.
+var contentTypes = map[string]string{
+	"ppm":   "image/x-portable-pixmap",
+	"png":   "image/png",
+	"png16": "image/png",
+	"jpeg":  "image/jpeg",
+	"exr":   "image/x-exr",
+	"hdr":   "image/vnd.radiance",
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// The body of error responses. Scenes with problems list each of them.
+type errorResponse struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	resp := errorResponse{Error: err.Error()}
+	var problems scene.Problems
+	if errors.As(err, &problems) {
+		resp.Error = fmt.Sprintf("the scene has %d problems", len(problems))
+		for _, p := range problems {
+			resp.Problems = append(resp.Problems, p.Error())
+		}
+	}
+	writeJSON(w, code, resp)
+}
@@ -0,0 +1,218 @@
+package server_test
+
+import (
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/server"
+)
+
+const sceneJSON = `{
+	"camera": {"width": 16, "aspect_ratio": 1, "samples_per_pixel": 2, "max_depth": 4, "look_from": [0, 0, 5], "look_at": [0, 0, 0]},
+	"background": [0.5, 0.7, 1],
+	"objects": [{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": {"type": "lambertian", "albedo": [0.8, 0.2, 0.2]}}]
+}`
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := server.New(server.Config{Concurrency: 1, Threads: 2, QueueSize: 4, Dir: "."})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Close()
+	})
+	return ts
+}
+
+func submit(t *testing.T, ts *httptest.Server, body string) (*http.Response, server.Status) {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status server.Status
+	json.NewDecoder(resp.Body).Decode(&status)
+	return resp, status
+}
+
+// Polls a job until it has ended.
+func wait(t *testing.T, ts *httptest.Server, id string) server.Status {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(ts.URL + "/jobs/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var status server.Status
+		json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if status.State != server.QUEUED && status.State != server.RUNNING {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish in time", id)
+	return server.Status{}
+}
+
+func TestRenderJob(t *testing.T) {
+	ts := newServer(t)
+	resp, status := submit(t, ts, `{"scene": `+sceneJSON+`, "settings": {"width": 24, "seed": 1}}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected %d, but got %d", http.StatusAccepted, resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/jobs/"+status.ID {
+		t.Errorf("Expected the location of the job, but got %q", loc)
+	}
+
+	status = wait(t, ts, status.ID)
+	if status.State != server.DONE || status.Percent != 100 {
+		t.Fatalf("Expected the job to be done, but got %+v", status)
+	}
+	img, err := http.Get(ts.URL + status.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Body.Close()
+	if ct := img.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Expected image/png, but got %q", ct)
+	}
+	decoded, err := png.Decode(img.Body)
+	if err != nil {
+		t.Fatalf("Expected a PNG image, but got %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != 24 || b.Dy() != 24 {
+		t.Errorf("Expected a 24x24 image, but got %v", b)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/jobs/"+status.ID, nil)
+	del, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected a finished job to be deleted, but got %d", del.StatusCode)
+	}
+	if gone, _ := http.Get(ts.URL + "/jobs/" + status.ID); gone.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the deleted job to be gone, but got %d", gone.StatusCode)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	ts := newServer(t)
+	_, running := submit(t, ts, `{"scene": `+sceneJSON+`, "settings": {"width": 400, "samples_per_pixel": 100000}}`)
+	_, queued := submit(t, ts, `{"scene": `+sceneJSON+`}`)
+
+	for _, id := range []string{queued.ID, running.ID} {
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/jobs/"+id, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Errorf("Expected job %s to be cancelled, but got %d", id, resp.StatusCode)
+		}
+	}
+	if status := wait(t, ts, running.ID); status.State != server.CANCELLED {
+		t.Errorf("Expected the running job to be cancelled, but got %v", status.State)
+	}
+	if status := wait(t, ts, queued.ID); status.State != server.CANCELLED || status.Started != nil {
+		t.Errorf("Expected the queued job to be cancelled before starting, but got %+v", status)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	ts := newServer(t)
+	tests := []struct {
+		name, body, expected string
+	}{
+		{"malformed", `{"scene": `, "invalid request"},
+		{"no scene", `{"settings": {"width": 10}}`, "no scene"},
+		{"unknown field", `{"scene": ` + sceneJSON + `, "colour": 1}`, "unknown field"},
+		{"bad scene", `{"scene": {"objects": [{"type": "sphere", "radius": -1}]}}`, "problems"},
+		{"bad settings", `{"scene": ` + sceneJSON + `, "settings": {"time_budget": "soon"}}`, "time_budget"},
+		{"bad format", `{"scene": ` + sceneJSON + `, "settings": {"format": "gif"}}`, "gif"},
+		{"oversized", `{"scene": ` + sceneJSON + `, "settings": {"width": 1000000}}`, "larger than"},
+		{"oversized scene", `{"scene": ` + strings.Replace(sceneJSON, `"width": 16`, `"width": 1000000`, 1) + `}`, "larger than"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body struct {
+				Error    string   `json:"error"`
+				Problems []string `json:"problems"`
+			}
+			json.NewDecoder(resp.Body).Decode(&body)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected %d, but got %d", http.StatusBadRequest, resp.StatusCode)
+			}
+			if !strings.Contains(body.Error, test.expected) {
+				t.Errorf("Expected an error mentioning %q, but got %q", test.expected, body.Error)
+			}
+			if test.name == "bad scene" && len(body.Problems) == 0 {
+				t.Error("Expected the scene's problems to be listed")
+			}
+		})
+	}
+
+	if resp, _ := http.Get(ts.URL + "/jobs/42"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown job to give %d, but got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestMaxPixels(t *testing.T) {
+	srv := server.New(server.Config{Concurrency: 1, Threads: 1, QueueSize: 4, MaxPixels: 24 * 24, Dir: "."})
+	ts := httptest.NewServer(srv)
+	defer func() {
+		ts.Close()
+		srv.Close()
+	}()
+	if resp, _ := submit(t, ts, `{"scene": `+sceneJSON+`, "settings": {"width": 24}}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected a 24x24 image to be accepted, but got %d", resp.StatusCode)
+	}
+	if resp, _ := submit(t, ts, `{"scene": `+sceneJSON+`, "settings": {"width": 25}}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a 25x25 image to be refused, but got %d", resp.StatusCode)
+	}
+}
+
+func TestFilesOutsideDir(t *testing.T) {
+	ts := newServer(t)
+	abs, err := filepath.Abs("server_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both files exist, the problem must not tell either way
+	for _, file := range []string{"../../README.md", abs, "../server/../../go.mod"} {
+		body := `{"scene": {"objects": [{"type": "obj", "file": ` + strconv.Quote(file) + `}]}}`
+		resp, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result struct {
+			Problems []string `json:"problems"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, but got %d", file, http.StatusBadRequest, resp.StatusCode)
+		}
+		if len(result.Problems) != 1 || !strings.Contains(result.Problems[0], "outside of the scene directory") {
+			t.Errorf("%s: expected the file to be refused, but got %v", file, result.Problems)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package tiles
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// The order in which the tiles of an image are handed out for rendering.
+type Order uint8
+
+const (
+	_ Order = iota
+	SCANLINE
+	SPIRAL
+	HILBERT
+)
+
+var orderNames = map[Order]string{
+	SCANLINE: "scanline",
+	SPIRAL:   "spiral",
+	HILBERT:  "hilbert",
+}
+
+func (o Order) String() string {
+	if name, ok := orderNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Order(%d)", o)
+}
+
+// ParseOrder converts the name of an order (scanline, spiral or hilbert) to an Order.
+func ParseOrder(name string) (Order, error) {
+	for order, n := range orderNames {
+		if strings.EqualFold(name, n) {
+			return order, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tile order %q (supported: scanline, spiral, hilbert)", name)
+}
+
+// Split divides the region into square tiles of the given size, clipped to the region, in the requested order.
+func Split(region image.Rectangle, size int, order Order) []image.Rectangle {
+	if region.Empty() {
+		return nil
+	}
+	size = max(1, size)
+	cols := (region.Dx() + size - 1) / size
+	rows := (region.Dy() + size - 1) / size
+
+	var cells []image.Point
+	switch order {
+	case SPIRAL:
+		cells = spiral(cols, rows)
+	case HILBERT:
+		cells = hilbert(cols, rows)
+	default:
+		cells = scanline(cols, rows)
+	}
+
+	tiles := make([]image.Rectangle, len(cells))
+	for i, cell := range cells {
+		min := region.Min.Add(cell.Mul(size))
+		tiles[i] = image.Rectangle{Min: min, Max: min.Add(image.Pt(size, size))}.Intersect(region)
+	}
+	return tiles
+}
+
+// Returns every cell of the grid row by row, top to bottom.
+func scanline(cols, rows int) []image.Point {
+	cells := make([]image.Point, 0, cols*rows)
+	for y := range rows {
+		for x := range cols {
+			cells = append(cells, image.Pt(x, y))
+		}
+	}
+	return cells
+}
+
+// Returns every cell of the grid walking outwards from the center in a square spiral,
+// so the middle of the image, usually the subject, is rendered first.
+func spiral(cols, rows int) []image.Point {
+	total := cols * rows
+	cells := make([]image.Point, 0, total)
+	p := image.Pt((cols-1)/2, (rows-1)/2)
+	directions := []image.Point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+	// walk legs of length 1, 1, 2, 2, 3, 3, ... turning after each leg
+	for leg := 0; len(cells) < total; leg++ {
+		dir := directions[leg%4]
+		for range leg/2 + 1 {
+			if p.X >= 0 && p.X < cols && p.Y >= 0 && p.Y < rows {
+				cells = append(cells, p)
+			}
+			p = p.Add(dir)
+		}
+	}
+	return cells
+}
+
+// Returns every cell of the grid along a Hilbert curve, which keeps consecutive tiles close together
+// and improves cache coherence between workers.
+func hilbert(cols, rows int) []image.Point {
+	n := 1
+	for n < max(cols, rows) {
+		n *= 2
+	}
+	cells := scanline(cols, rows)
+	sort.Slice(cells, func(i, j int) bool {
+		return hilbertIndex(n, cells[i]) < hilbertIndex(n, cells[j])
+	})
+	return cells
+}
+
+// Returns the distance along a Hilbert curve covering an n*n grid, n being a power of 2, of the given cell.
+func hilbertIndex(n int, p image.Point) int {
+	d := 0
+	x, y := p.X, p.Y
+	for s := n / 2; s > 0; s /= 2 {
+		rx, ry := 0, 0
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+
+		// rotate the quadrant so the curve stays continuous
+		if ry == 0 {
+			if rx == 1 {
+				x = s - 1 - x
+				y = s - 1 - y
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
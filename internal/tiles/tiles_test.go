@@ -0,0 +1,87 @@
+package tiles_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/tiles"
+)
+
+// Checks that the tiles cover every pixel of the region exactly once.
+func checkCoverage(t *testing.T, region image.Rectangle, ts []image.Rectangle) {
+	covered := map[image.Point]int{}
+	for _, tile := range ts {
+		if !tile.In(region) {
+			t.Errorf("Tile %v is outside of region %v", tile, region)
+		}
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			for x := tile.Min.X; x < tile.Max.X; x++ {
+				covered[image.Pt(x, y)]++
+			}
+		}
+	}
+	if len(covered) != region.Dx()*region.Dy() {
+		t.Errorf("Expected %d pixels to be covered, but got %d", region.Dx()*region.Dy(), len(covered))
+	}
+	for p, n := range covered {
+		if n != 1 {
+			t.Errorf("Expected pixel %v to be covered once, but got %d", p, n)
+		}
+	}
+}
+
+func TestSplitCoverage(t *testing.T) {
+	region := image.Rect(3, 5, 70, 41)
+	for _, order := range []tiles.Order{tiles.SCANLINE, tiles.SPIRAL, tiles.HILBERT} {
+		ts := tiles.Split(region, 16, order)
+		if len(ts) != 5*3 {
+			t.Errorf("Expected %d tiles, but got %d for %s", 15, len(ts), order)
+		}
+		checkCoverage(t, region, ts)
+	}
+}
+
+func TestScanlineOrder(t *testing.T) {
+	ts := tiles.Split(image.Rect(0, 0, 20, 20), 10, tiles.SCANLINE)
+	exp := []image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10), image.Rect(0, 10, 10, 20), image.Rect(10, 10, 20, 20)}
+	for i := range exp {
+		if ts[i] != exp[i] {
+			t.Errorf("Expected %v, but got %v", exp[i], ts[i])
+		}
+	}
+}
+
+func TestSpiralStartsInCenter(t *testing.T) {
+	ts := tiles.Split(image.Rect(0, 0, 50, 50), 10, tiles.SPIRAL)
+	exp := image.Rect(20, 20, 30, 30)
+	if ts[0] != exp {
+		t.Errorf("Expected %v, but got %v", exp, ts[0])
+	}
+}
+
+func TestHilbertIsContinuous(t *testing.T) {
+	ts := tiles.Split(image.Rect(0, 0, 80, 80), 10, tiles.HILBERT)
+	for i := 1; i < len(ts); i++ {
+		d := ts[i].Min.Sub(ts[i-1].Min)
+		if abs(d.X)+abs(d.Y) != 10 {
+			t.Errorf("Expected tile %v to be adjacent to %v", ts[i], ts[i-1])
+		}
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	order, err := tiles.ParseOrder("Hilbert")
+	if err != nil || order != tiles.HILBERT {
+		t.Errorf("Expected %s, but got %s (%v)", tiles.HILBERT, order, err)
+	}
+	if _, err := tiles.ParseOrder("zigzag"); err == nil {
+		t.Error("Expected an error for an unknown order")
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,148 @@
+package tonemap
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// The curve used to compress scene radiance into the displayable range.
+type Operator uint8
+
+const (
+	_                 Operator = iota
+	CLAMP                      // clips everything above 1, the raytracer's original behavior
+	REINHARD                   // L / (1 + L) on luminance, never reaches white
+	EXTENDED_REINHARD          // Reinhard with a white point which is mapped to 1
+	ACES                       // Narkowicz's fit of the ACES filmic curve
+	HABLE                      // John Hable's Uncharted 2 filmic curve
+)
+
+var operatorNames = map[Operator]string{
+	CLAMP:             "clamp",
+	REINHARD:          "reinhard",
+	EXTENDED_REINHARD: "reinhard-extended",
+	ACES:              "aces",
+	HABLE:             "hable",
+}
+
+func (o Operator) String() string {
+	if name, ok := operatorNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Operator(%d)", o)
+}
+
+// ParseOperator returns the operator with the given name.
+func ParseOperator(name string) (Operator, error) {
+	for op, n := range operatorNames {
+		if strings.EqualFold(name, n) {
+			return op, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tone mapping operator %q (supported: clamp, reinhard, reinhard-extended, aces, hable)", name)
+}
+
+// Default white points, the radiance which is mapped to white, for the operators which use one.
+const (
+	defaultReinhardWhite = 4
+	defaultHableWhite    = 11.2
+)
+
+// Mapper converts linear scene radiance into display values. The zero value clamps without any exposure adjustment.
+type Mapper struct {
+	Operator   Operator
+	Exposure   float64 // in stops, every stop doubles the brightness
+	WhitePoint float64 // radiance mapped to white by the extended Reinhard and Hable operators, 0 picks a default
+}
+
+// Map applies the exposure and tone curve to a linear color, returning linear display values in [0, 1].
+// NaN and negative components are mapped to 0.
+func (m Mapper) Map(c *vec.Vec3) [3]float64 {
+	scale := math.Exp2(m.Exposure)
+	rgb := [3]float64{}
+	for i := range rgb {
+		x := c.Get(i) * scale
+		if math.IsNaN(x) || x < 0 {
+			x = 0
+		}
+		rgb[i] = x
+	}
+
+	switch m.Operator {
+	case REINHARD:
+		rgb = scaleLuminance(rgb, func(l float64) float64 { return l / (1 + l) })
+	case EXTENDED_REINHARD:
+		white := m.white(defaultReinhardWhite)
+		rgb = scaleLuminance(rgb, func(l float64) float64 { return l * (1 + l/(white*white)) / (1 + l) })
+	case ACES:
+		for i, x := range rgb {
+			rgb[i] = (x * (2.51*x + 0.03)) / (x*(2.43*x+0.59) + 0.14)
+		}
+	case HABLE:
+		white := hable(m.white(defaultHableWhite))
+		for i, x := range rgb {
+			rgb[i] = hable(x) / white
+		}
+	}
+
+	for i, x := range rgb {
+		rgb[i] = min(max(x, 0), 1)
+	}
+	return rgb
+}
+
+// Display maps a linear color and encodes it with the sRGB transfer function, ready to be quantized.
+func (m Mapper) Display(c *vec.Vec3) [3]float64 {
+	rgb := m.Map(c)
+	for i, x := range rgb {
+		rgb[i] = SRGB(x)
+	}
+	return rgb
+}
+
+func (m Mapper) white(fallback float64) float64 {
+	if m.WhitePoint > 0 {
+		return m.WhitePoint
+	}
+	return fallback
+}
+
+// SRGB is the sRGB opto-electronic transfer function, encoding a linear value in [0, 1] for display.
+func SRGB(x float64) float64 {
+	if x <= 0.0031308 {
+		return 12.92 * x
+	}
+	return 1.055*math.Pow(x, 1/2.4) - 0.055
+}
+
+// InverseSRGB decodes an sRGB encoded value in [0, 1] back to linear.
+func InverseSRGB(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+// Returns the Rec. 709 luminance of a linear color.
+func luminance(rgb [3]float64) float64 {
+	return 0.2126*rgb[0] + 0.7152*rgb[1] + 0.0722*rgb[2]
+}
+
+// Applies a curve to the luminance of a color, scaling its components to preserve the hue.
+func scaleLuminance(rgb [3]float64, curve func(float64) float64) [3]float64 {
+	l := luminance(rgb)
+	if l <= 0 {
+		return [3]float64{}
+	}
+	s := curve(l) / l
+	return [3]float64{rgb[0] * s, rgb[1] * s, rgb[2] * s}
+}
+
+// Hable's filmic curve with the constants from Uncharted 2.
+func hable(x float64) float64 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+}
@@ -0,0 +1,95 @@
+package tonemap_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestSRGB(t *testing.T) {
+	cases := map[float64]float64{0: 0, 0.002: 0.02584, 0.25: 0.537099, 1: 1}
+	for in, exp := range cases {
+		if act := tonemap.SRGB(in); math.Abs(act-exp) > 1e-5 {
+			t.Errorf("Expected %v, but got %v for %v", exp, act, in)
+		}
+	}
+}
+
+func TestExposure(t *testing.T) {
+	m := tonemap.Mapper{Exposure: 1}
+	if act := m.Map(vec.New(.25, .25, .25)); !near(act[0], .5) {
+		t.Errorf("Expected %v, but got %v", .5, act[0])
+	}
+	m.Exposure = -2
+	if act := m.Map(vec.New(1, 1, 1)); !near(act[0], .25) {
+		t.Errorf("Expected %v, but got %v", .25, act[0])
+	}
+}
+
+func TestOperators(t *testing.T) {
+	gray := func(v float64) *vec.Vec3 { return vec.New(v, v, v) }
+
+	if act := (tonemap.Mapper{}).Map(gray(3)); act[0] != 1 {
+		t.Errorf("Expected clamp to map %v to %v, but got %v", 3, 1, act[0])
+	}
+	if act := (tonemap.Mapper{Operator: tonemap.REINHARD}).Map(gray(1)); !near(act[0], .5) {
+		t.Errorf("Expected reinhard to map %v to %v, but got %v", 1, .5, act[0])
+	}
+	if act := (tonemap.Mapper{Operator: tonemap.EXTENDED_REINHARD, WhitePoint: 2}).Map(gray(2)); !near(act[0], 1) {
+		t.Errorf("Expected extended reinhard to map the white point to %v, but got %v", 1, act[0])
+	}
+	if act := (tonemap.Mapper{Operator: tonemap.HABLE}).Map(gray(11.2)); !near(act[0], 1) {
+		t.Errorf("Expected hable to map the white point to %v, but got %v", 1, act[0])
+	}
+	if act := (tonemap.Mapper{Operator: tonemap.ACES}).Map(gray(1000)); act[0] < .99 || act[0] > 1 {
+		t.Errorf("Expected aces to approach %v, but got %v", 1, act[0])
+	}
+
+	// every operator is monotonic and maps black to black
+	for _, op := range []tonemap.Operator{tonemap.CLAMP, tonemap.REINHARD, tonemap.EXTENDED_REINHARD, tonemap.ACES, tonemap.HABLE} {
+		m := tonemap.Mapper{Operator: op}
+		if act := m.Map(gray(0)); !near(act[0], 0) {
+			t.Errorf("Expected %v to map black to %v, but got %v", op, 0, act[0])
+		}
+		prev := 0.0
+		for v := 0.01; v < 20; v *= 1.5 {
+			act := m.Map(gray(v))[0]
+			if act < prev {
+				t.Errorf("Expected %v to be monotonic, but %v maps to %v after %v", op, v, act, prev)
+			}
+			prev = act
+		}
+	}
+}
+
+func TestReinhardPreservesHue(t *testing.T) {
+	act := tonemap.Mapper{Operator: tonemap.REINHARD}.Map(vec.New(1, .5, 0))
+	if !near(act[0]/act[1], 2) || act[2] != 0 {
+		t.Errorf("Expected the ratio of the channels to be preserved, but got %v", act)
+	}
+}
+
+func TestMapNaN(t *testing.T) {
+	act := tonemap.Mapper{Operator: tonemap.ACES}.Map(vec.New(math.NaN(), -1, .5))
+	if act[0] != 0 || act[1] != 0 {
+		t.Errorf("Expected NaN and negative components to map to 0, but got %v", act)
+	}
+}
+
+func TestParseOperator(t *testing.T) {
+	for _, op := range []tonemap.Operator{tonemap.CLAMP, tonemap.REINHARD, tonemap.EXTENDED_REINHARD, tonemap.ACES, tonemap.HABLE} {
+		act, err := tonemap.ParseOperator(op.String())
+		if err != nil || act != op {
+			t.Errorf("Expected %v, but got %v (%v)", op, act, err)
+		}
+	}
+	if _, err := tonemap.ParseOperator("filmic"); err == nil {
+		t.Error("Expected an error for an unknown operator")
+	}
+}
@@ -1,7 +1,6 @@
 package vec_test
 
 import (
-	"bytes"
 	"fmt"
 	"math"
 	"testing"
@@ -136,19 +135,3 @@ func TestString(t *testing.T) {
 		t.Errorf("Expected %s, got %s", exp, act)
 	}
 }
-
-func TestWriteColor(t *testing.T) {
-	v := vec.New(0, 128, 255)
-	exp := fmt.Sprintf("%d %d %d\n", 0, 255, 255)
-	b := &bytes.Buffer{}
-	v.PrintColor(b)
-	delim := byte('\n')
-	act, err := b.ReadString(delim)
-	if err != nil {
-		t.Error("Invalid output format")
-	}
-	if act != exp {
-		t.Errorf("Expected %s,, got %s", exp, act)
-	}
-
-}
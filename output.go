@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nsp5488/go_raytracer/internal/aov"
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/denoise"
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/postfx"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Describes how the rendered images are written.
+type output struct {
+	filename string
+	enc      encoder.Encoder
+	aovs     []aov.Kind       // output variables written alongside the image
+	layers   bool             // store the output variables as layers of an EXR image instead of separate images
+	denoise  *denoise.Options // denoise the image before writing it, if set
+	post     postfx.Chain     // effects applied to the image after denoising
+	// Pixels of an interrupted render which should have been rendered. Those without samples are painted magenta
+	// in formats without transparency, PNG and EXR leave them transparent.
+	marked image.Rectangle
+}
+
+// The color unrendered pixels are marked with.
+var unrenderedColor = vec.New(1, 0, 1)
+
+// Writes the rendered image and its output variables, either as layers of an EXR image or as separate images
+// named after the output file, e.g. image.depth.png. Only EXR files store the raw values of the outputs,
+// other formats get a visualization.
+func (o *output) write(c *camera.Camera) error {
+	img := c.Image()
+	if o.denoise != nil {
+		var err error
+		img, err = denoise.Denoise(img, c.AOV(aov.ALBEDO), c.AOV(aov.SHADING_NORMAL), *o.denoise)
+		if err != nil {
+			return err
+		}
+	}
+	img = o.post.Apply(img)
+	if !o.marked.Empty() {
+		img = markUnrendered(img, o.marked, o.enc)
+	}
+
+	if o.layers {
+		exr := encoder.LayeredEXR{}
+		for _, kind := range o.aovs {
+			exr.Layers = append(exr.Layers, encoder.Layer{Name: kind.String(), Channels: kind.Channels(), Image: c.AOV(kind)})
+		}
+		return writeImage(o.filename, exr, img)
+	}
+
+	if err := writeImage(o.filename, o.enc, img); err != nil {
+		return err
+	}
+	// output variables hold data rather than radiance, so they are never tone mapped
+	aovEnc := encoder.WithTonemap(o.enc, tonemap.Mapper{})
+	ext := filepath.Ext(o.filename)
+	for _, kind := range o.aovs {
+		fb := c.AOV(kind)
+		if _, ok := o.enc.(encoder.EXR); !ok {
+			fb = aov.Visualize(kind, fb)
+		}
+		if err := writeImage(strings.TrimSuffix(o.filename, ext)+"."+kind.String()+ext, aovEnc, fb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns a copy of the image with the pixels of region which have no samples painted in unrenderedColor,
+// unless enc writes them transparent.
+func markUnrendered(fb *framebuffer.Framebuffer, region image.Rectangle, enc encoder.Encoder) *framebuffer.Framebuffer {
+	switch enc.(type) {
+	case encoder.PNG, encoder.EXR:
+		return fb
+	}
+	marked := fb.Clone()
+	region = region.Intersect(fb.Bounds())
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if fb.Samples(x, y) == 0 {
+				marked.Set(x, y, unrenderedColor, 1)
+			}
+		}
+	}
+	return marked
+}
+
+// Encodes the framebuffer to a temporary file which then replaces the output file,
+// so readers never observe a partially written image.
+func writeImage(filename string, enc encoder.Encoder, fb *framebuffer.Framebuffer) error {
+	tmp := filename + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+	err = enc.Encode(out, fb)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// Parses a pixel rectangle given as x0,y0,x1,y1.
+func parseRegion(s string) (image.Rectangle, error) {
+	var x0, y0, x1, y1 int
+	if _, err := fmt.Sscanf(s, "%d,%d,%d,%d", &x0, &y0, &x1, &y1); err != nil {
+		return image.Rectangle{}, fmt.Errorf("invalid region %q, expected x0,y0,x1,y1: %w", s, err)
+	}
+	r := image.Rect(x0, y0, x1, y1)
+	if r.Empty() {
+		return image.Rectangle{}, fmt.Errorf("region %q is empty", s)
+	}
+	return r, nil
+}
@@ -0,0 +1,241 @@
+// Package raytracer is the public API of the path tracer, for programs which build scenes and render them
+// in-process. Rendering never exits the process or writes to the terminal, problems are returned as errors
+// and progress is only reported through Options.Progress. Models which load despite problems, such as a missing
+// MTL file or texture map, leave them in Scene.Warnings.
+//
+//	scene := raytracer.NewScene()
+//	scene.Add(raytracer.Sphere(raytracer.V(0, 0, -1), 0.5, raytracer.Lambertian(raytracer.V(.7, .3, .3))))
+//	result, err := raytracer.Render(ctx, scene, raytracer.Options{Width: 400, LookAt: raytracer.V(0, 0, -1)})
+//	if err != nil {
+//		return err
+//	}
+//	png.Encode(out, result.Image())
+package raytracer
+
+import (
+	"context"
+	"errors"
+	"image"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/lens"
+	"github.com/nsp5488/go_raytracer/internal/progress"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Reporter follows the progress of a render, in tiles.
+type Reporter = progress.Reporter
+
+// Tonemap converts the linear radiance of a render into display colors.
+// The zero value clips everything brighter than white.
+type Tonemap = tonemap.Mapper
+
+// Tone mapping curves for Tonemap.Operator.
+const (
+	Clamp            = tonemap.CLAMP
+	Reinhard         = tonemap.REINHARD
+	ExtendedReinhard = tonemap.EXTENDED_REINHARD
+	ACES             = tonemap.ACES
+	Hable            = tonemap.HABLE
+)
+
+// Projection maps the pixels of the image to the directions the camera sees.
+type Projection = camera.Projection
+
+// Projections for Options.Projection.
+const (
+	Perspective     = camera.PERSPECTIVE     // VerticalFOV spans the height of the image
+	Orthographic    = camera.ORTHOGRAPHIC    // parallel rays from a view OrthoHeight units tall
+	Fisheye         = camera.FISHEYE         // a circular equidistant fisheye, VerticalFOV (up to 360) spans the circle's diameter
+	Equirectangular = camera.EQUIRECTANGULAR // a 360 by 180 degree panorama, for images twice as wide as they are tall
+)
+
+// Stereo arranges the views of both eyes in one image.
+type Stereo = camera.Stereo
+
+// Stereo layouts for Options.Stereo.
+const (
+	Mono       = camera.MONO
+	SideBySide = camera.SIDE_BY_SIDE // the left eye's view on the left
+	TopBottom  = camera.TOP_BOTTOM   // the left eye's view on top
+)
+
+// Distortion holds the Brown-Conrady coefficients of a lens, as OpenCV's calibration reports them.
+type Distortion = camera.Distortion
+
+// LensSystem is a camera lens made of spherical elements, traced ray by ray.
+type LensSystem = lens.System
+
+// LensParseError reports a malformed line of a lens prescription.
+type LensParseError = lens.ParseError
+
+// Loads a lens prescription, with one surface per line from the front of the lens to the back: its radius, the
+// thickness up to the next surface, the index of refraction behind it and its aperture's diameter, with lengths in
+// millimeters. A radius of 0 marks the aperture stop. A missing file gives an error matching fs.ErrNotExist and
+// a malformed line a *LensParseError.
+func LoadLens(filename string) (*LensSystem, error) {
+	return lens.Load(filename)
+}
+
+// Shutter weighs the moments between the shutter opening and closing.
+type Shutter = camera.Shutter
+
+// Shutter curves for Options.ShutterCurve.
+const (
+	BoxShutter      = camera.BOX      // opens and closes instantly
+	TriangleShutter = camera.TRIANGLE // opens and closes steadily
+	CosineShutter   = camera.COSINE   // opens and closes smoothly
+)
+
+// Options configure a render. Unset fields take the same defaults as the command line renderer.
+type Options struct {
+	Width           int     // in pixels, 100 by default
+	AspectRatio     float64 // width over height, 1 by default
+	SamplesPerPixel int     // 100 by default
+	MaxDepth        int     // maximum number of bounces per ray, 10 by default
+
+	// The camera sits at LookFrom, the origin by default, and looks towards LookAt, -z by default, with Up pointing up.
+	LookFrom, LookAt, Up *Vec3
+	VerticalFOV          float64 // in degrees, 90 by default
+	DefocusAngle         float64 // in degrees, 0 keeps everything in focus
+	FocusDistance        float64 // 10 by default
+	Background           *Vec3   // the radiance of rays which escape the scene, black by default
+	Projection           Projection
+	OrthoHeight          float64 // height in world units of orthographic views, by default that of a perspective view at FocusDistance
+
+	// Stereo renders both eyes' views, each Width wide, into one image. Interocular is the distance between the eyes,
+	// 0.064 by default, and their views converge at Convergence, or are parallel when it is 0.
+	// Equirectangular and fisheye views render omni-directional stereo.
+	Stereo      Stereo
+	Interocular float64
+	Convergence float64
+
+	// Lens shift moves the view across by ShiftX and up by ShiftY fractions of its size without turning the camera.
+	// TiltX and TiltY turn the top and right side of the plane in focus away from the camera by that many degrees.
+	// Distortion renders perspective views as seen through a calibrated lens.
+	ShiftX     float64
+	ShiftY     float64
+	TiltX      float64
+	TiltY      float64
+	Distortion Distortion
+
+	// A physical lens of FocalLength millimeters on a sensor SensorWidth millimeters wide, 36 by default, replaces
+	// VerticalFOV, and opened to FStop it replaces DefocusAngle, with world units taken to be meters.
+	// The aperture is a polygon of ApertureBlades sides turned by ApertureRotation degrees, or a circle with less
+	// than 3, unless an ApertureMask weighs where light passes the lens.
+	FocalLength      float64
+	SensorWidth      float64
+	FStop            float64
+	ApertureBlades   int
+	ApertureRotation float64
+	ApertureMask     Texture
+	// The shutter is open from ShutterOpen to ShutterClose, 0 and 1 by default, which motion blur is sampled between.
+	ShutterOpen  float64
+	ShutterClose float64
+	ShutterCurve Shutter
+	// Exposes radiance in nits as a camera set to ISO with an exposure of ExposureTime seconds at FStop would,
+	// radiance is left as it is when ISO is 0.
+	ISO          float64
+	ExposureTime float64
+	// A Lens system replaces FocalLength, FStop, VerticalFOV and DefocusAngle, focused at FocusDistance meters
+	// with the film SensorWidth millimeters wide. It only renders the perspective projection.
+	Lens *LensSystem
+
+	Threads    int           // number of workers, 1 by default
+	Seed       uint64        // seeds the camera's sampling, 0 picks a random seed
+	TimeBudget time.Duration // stop after this long and return the samples taken so far, 0 for no limit
+	// Only the pixels within Region are rendered, the others are left without samples. An empty Region renders everything.
+	Region image.Rectangle
+
+	Tonemap  Tonemap  // how Result.Image converts radiance to colors
+	Progress Reporter // follows the progress of the render, nothing is reported when nil
+}
+
+// Result holds the image produced by a render.
+type Result struct {
+	// The linear radiance of every pixel with the number of samples it took.
+	Framebuffer *framebuffer.Framebuffer
+	// The fraction of the work which was done, below 1 when the render was cancelled or ran out of time.
+	Completion float64
+	// The number of rays traced, counting every bounce.
+	Rays uint64
+
+	tonemap Tonemap
+}
+
+// Image returns the render as an 8-bit sRGB image, tone mapped with Options.Tonemap.
+// Pixels without samples are transparent.
+func (r *Result) Image() *image.RGBA {
+	return encoder.ToRGBA(r.Framebuffer, r.tonemap)
+}
+
+// Radiance returns the average linear RGB radiance of every pixel, three values per pixel in row-major order
+// starting at the top left corner.
+func (r *Result) Radiance() []float64 {
+	fb := r.Framebuffer
+	out := make([]float64, 0, 3*fb.Width*fb.Height)
+	for y := range fb.Height {
+		for x := range fb.Width {
+			c := fb.Color(x, y)
+			out = append(out, c.X(), c.Y(), c.Z())
+		}
+	}
+	return out
+}
+
+// Render renders the scene with the given options. Options it cannot render with, such as negative sizes, a shutter
+// which closes before it opens or a tilt of 90 degrees, give an error.
+// When ctx is cancelled the workers stop promptly, and the samples taken so far are returned with the context's error.
+func Render(ctx context.Context, scene *Scene, opts Options) (*Result, error) {
+	c := camera.Camera{
+		Width:            opts.Width,
+		AspectRatio:      opts.AspectRatio,
+		SamplesPerPixel:  opts.SamplesPerPixel,
+		MaxDepth:         opts.MaxDepth,
+		VerticalFOV:      opts.VerticalFOV,
+		DefocusAngle:     opts.DefocusAngle,
+		FocusDistance:    opts.FocusDistance,
+		Projection:       opts.Projection,
+		OrthoHeight:      opts.OrthoHeight,
+		Stereo:           opts.Stereo,
+		Interocular:      opts.Interocular,
+		Convergence:      opts.Convergence,
+		ShiftX:           opts.ShiftX,
+		ShiftY:           opts.ShiftY,
+		TiltX:            opts.TiltX,
+		TiltY:            opts.TiltY,
+		Distortion:       opts.Distortion,
+		FocalLength:      opts.FocalLength,
+		SensorWidth:      opts.SensorWidth,
+		FStop:            opts.FStop,
+		ApertureBlades:   opts.ApertureBlades,
+		ApertureRotation: opts.ApertureRotation,
+		ApertureMask:     opts.ApertureMask,
+		ShutterOpen:      opts.ShutterOpen,
+		ShutterClose:     opts.ShutterClose,
+		ShutterCurve:     opts.ShutterCurve,
+		ISO:              opts.ISO,
+		ExposureTime:     opts.ExposureTime,
+		Lens:             opts.Lens,
+		Background:       opts.Background,
+		MaxThreads:       opts.Threads,
+		Seed:             opts.Seed,
+		TimeBudget:       opts.TimeBudget,
+		Region:           opts.Region,
+		Progress:         opts.Progress,
+	}
+	if c.Background == nil {
+		c.Background = vec.Empty()
+	}
+	c.PositionCamera(opts.LookFrom, opts.LookAt, opts.Up)
+
+	err := c.Render(ctx, scene.bvh(), scene.lights)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	return &Result{Framebuffer: c.Framebuffer, Completion: c.Completion(), Rays: c.Rays(), tonemap: opts.Tonemap}, err
+}
@@ -0,0 +1,155 @@
+package raytracer_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsp5488/go_raytracer/pkg/raytracer"
+)
+
+// A small room lit by a quad in its ceiling.
+func testScene() *raytracer.Scene {
+	s := raytracer.NewScene()
+	white := raytracer.Lambertian(raytracer.V(.73, .73, .73))
+	s.Add(
+		raytracer.Box(raytracer.V(-2, -1, -4), raytracer.V(2, -0.9, 0), white),
+		raytracer.Sphere(raytracer.V(0, 0, -2), 0.5, raytracer.Metal(raytracer.V(.8, .8, .8), 0.1)),
+	)
+	s.AddLight(raytracer.Quad(raytracer.V(-4, 2, -6), raytracer.V(8, 0, 0), raytracer.V(0, 0, 8), raytracer.DiffuseLight(raytracer.V(4, 4, 4))))
+	return s
+}
+
+func TestRender(t *testing.T) {
+	opts := raytracer.Options{Width: 32, AspectRatio: 2, SamplesPerPixel: 4, MaxDepth: 4, Threads: 2, Seed: 1, Background: raytracer.V(.5, .7, 1)}
+	result, err := raytracer.Render(context.Background(), testScene(), opts)
+	if err != nil {
+		t.Fatalf("Error rendering: %v", err)
+	}
+	if result.Completion != 1 {
+		t.Errorf("Expected the render to complete, but got %v", result.Completion)
+	}
+	img := result.Image()
+	if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 16 {
+		t.Fatalf("Expected a 32x16 image, but got %v", b)
+	}
+	if radiance := result.Radiance(); len(radiance) != 3*32*16 {
+		t.Errorf("Expected %d radiance values, but got %d", 3*32*16, len(radiance))
+	}
+	// the top row only sees the sky
+	if c := img.RGBAAt(0, 0); c.A != 255 || c.B <= c.R {
+		t.Errorf("Expected an opaque blue sky, but got %v", c)
+	}
+}
+
+func TestRenderCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := raytracer.Render(ctx, testScene(), raytracer.Options{Width: 16, SamplesPerPixel: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the render to be cancelled, but got %v", err)
+	}
+	if result == nil || result.Completion != 0 {
+		t.Errorf("Expected an empty partial result, but got %+v", result)
+	}
+}
+
+func TestRenderInvalidOptions(t *testing.T) {
+	for _, opts := range []raytracer.Options{
+		{Width: -4},
+		{Width: 8, AspectRatio: -1},
+		{Width: 8, SamplesPerPixel: -1},
+		{Width: 8, MaxDepth: -1},
+		{Width: 8, Threads: -2},
+		{Width: 8, ShutterOpen: 0.5, ShutterClose: 0.5},
+		{Width: 8, ShutterOpen: 0.5},
+		{Width: 8, TiltX: 90},
+		{Width: 8, TiltY: -120},
+	} {
+		if _, err := raytracer.Render(context.Background(), testScene(), opts); err == nil {
+			t.Errorf("Expected an error rendering with %+v", opts)
+		}
+	}
+}
+
+// Returns the average radiance of a render.
+func mean(t *testing.T, s *raytracer.Scene) float64 {
+	t.Helper()
+	opts := raytracer.Options{Width: 32, SamplesPerPixel: 64, MaxDepth: 4, Threads: 2, Seed: 1, LookFrom: raytracer.V(0, 0, 1)}
+	result, err := raytracer.Render(context.Background(), s, opts)
+	if err != nil {
+		t.Fatalf("Error rendering: %v", err)
+	}
+	sum := 0.0
+	radiance := result.Radiance()
+	for _, v := range radiance {
+		sum += v
+	}
+	return sum / float64(len(radiance))
+}
+
+func TestRenderSampledLights(t *testing.T) {
+	// dim enough that no path is clamped, which would darken the noisier unsampled render
+	lamp := raytracer.DiffuseLight(raytracer.V(.2, .2, .2))
+	for name, light := range map[string]raytracer.Object{
+		"box":     raytracer.Box(raytracer.V(-1, 1.5, -3), raytracer.V(1, 2, -1), lamp),
+		"group":   raytracer.Group(raytracer.Sphere(raytracer.V(-1, 2, -2), 0.3, lamp), raytracer.Sphere(raytracer.V(1, 2, -2), 0.3, lamp)),
+		"rotated": raytracer.Translate(raytracer.RotateY(raytracer.Box(raytracer.V(-1, 0, -1), raytracer.V(1, 0.5, 1), lamp), 30), raytracer.V(0, 1.5, -2)),
+		"medium":  raytracer.Medium(raytracer.Sphere(raytracer.V(0, 2, -2), 0.5, lamp), 0.5, raytracer.V(1, 1, 1)),
+	} {
+		floor := raytracer.Box(raytracer.V(-4, -1, -6), raytracer.V(4, -0.9, 2), raytracer.Lambertian(raytracer.V(.73, .73, .73)))
+		unsampled := raytracer.NewScene()
+		unsampled.Add(floor, light)
+		sampled := raytracer.NewScene()
+		sampled.Add(floor)
+		sampled.AddLight(light)
+
+		// sampling the light directly only reduces the noise, the floor is lit as brightly either way
+		exp, act := mean(t, unsampled), mean(t, sampled)
+		if act == 0 || math.Abs(act-exp) > 0.1*exp {
+			t.Errorf("Expected the sampled %s light to light the scene like an unsampled one, %v, but got %v", name, exp, act)
+		}
+	}
+}
+
+func TestModelWithoutLights(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "model.obj")
+	if err := os.WriteFile(filename, []byte("v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newScene := func() *raytracer.Scene {
+		s := raytracer.NewScene()
+		s.Add(raytracer.Box(raytracer.V(-4, -1, -6), raytracer.V(4, -0.9, 2), raytracer.Lambertian(raytracer.V(.73, .73, .73))))
+		s.AddLight(raytracer.Quad(raytracer.V(-4, 2, -6), raytracer.V(8, 0, 0), raytracer.V(0, 0, 8), raytracer.DiffuseLight(raytracer.V(.2, .2, .2))))
+		return s
+	}
+	// the model is behind the camera, it doesn't change the image
+	withModel := newScene()
+	opts := raytracer.DefaultModelOptions()
+	opts.Position = raytracer.V(0, 0, 50)
+	if err := withModel.AddModel(filename, opts); err != nil {
+		t.Fatal(err)
+	}
+	exp, act := mean(t, newScene()), mean(t, withModel)
+	if act == 0 || math.Abs(act-exp) > 0.03*exp {
+		t.Errorf("Expected a model without emissive triangles not to change the lighting, %v, but got %v", exp, act)
+	}
+}
+
+func TestModelWarnings(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "model.obj")
+	if err := os.WriteFile(filename, []byte("mtllib missing.mtl\nv 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := raytracer.NewScene()
+	if err := s.AddModel(filename, raytracer.DefaultModelOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if w := s.Warnings(); len(w) != 1 || !errors.Is(w[0], fs.ErrNotExist) {
+		t.Errorf("Expected a warning about the missing MTL file, but got %v", w)
+	}
+}
@@ -0,0 +1,225 @@
+package raytracer
+
+import (
+	"github.com/nsp5488/go_raytracer/internal/hittable"
+	ImageLoader "github.com/nsp5488/go_raytracer/internal/imageloader"
+	"github.com/nsp5488/go_raytracer/internal/objLoader"
+	"github.com/nsp5488/go_raytracer/internal/vec"
+)
+
+// Vec3 is a point, direction or linear RGB color.
+type Vec3 = vec.Vec3
+
+// Creates a vector, or a linear RGB color from its red, green and blue components.
+func V(x, y, z float64) *Vec3 {
+	return vec.New(x, y, z)
+}
+
+// Object is anything which can be added to a scene: a shape, a group of shapes or a transformed object.
+type Object = hittable.Hittable
+
+// Material describes how light scatters off, or is emitted by, the surface of an object.
+type Material = hittable.Material
+
+// Texture varies a color over the surface of an object.
+type Texture = hittable.Texture
+
+// Scene collects the objects to render. Objects with emissive materials should be added with AddLight,
+// which also lets the renderer sample them directly.
+// A scene must not be changed while it is being rendered, but it may be rendered several times.
+type Scene struct {
+	objects  *hittable.HittableList
+	lights   *hittable.HittableList
+	world    hittable.Hittable // the objects grouped into a BVH, built on the first render after a change
+	warnings []error
+}
+
+// Creates an empty scene.
+func NewScene() *Scene {
+	return &Scene{objects: hittable.NewHittableList(8), lights: hittable.NewHittableList(1)}
+}
+
+// Adds objects to the scene.
+func (s *Scene) Add(objects ...Object) {
+	for _, o := range objects {
+		s.objects.Add(o)
+	}
+	s.world = nil
+}
+
+// Adds light emitting objects to the scene and samples them directly when shading other objects.
+func (s *Scene) AddLight(lights ...Object) {
+	for _, l := range lights {
+		s.objects.Add(l)
+		s.lights.Add(l)
+	}
+	s.world = nil
+}
+
+// ModelOptions control how an OBJ model is loaded by AddModel.
+type ModelOptions = objLoader.LoadObjOptions
+
+// Returns the options AddModel uses by default: the model is centered on Position, uses the materials of its MTL file
+// and nothing is written while it loads. Setting Debug to a writer describes the model as it loads.
+func DefaultModelOptions() ModelOptions {
+	opts := objLoader.DefaultLoadOptions()
+	opts.Debug = nil
+	return opts
+}
+
+// Loads an OBJ model and adds it to the scene, sampling its emissive triangles as lights.
+// A missing file gives an error matching fs.ErrNotExist, a malformed line a *ModelParseError
+// and a file without faces ErrNoTriangles. Problems the model loads despite, such as a missing MTL file or
+// texture map, are kept for Warnings.
+func (s *Scene) AddModel(filename string, opts ModelOptions) error {
+	model, lights, warnings, err := objLoader.LoadObjWithOptions(filename, opts)
+	if err != nil {
+		return err
+	}
+	s.warnings = append(s.warnings, warnings...)
+	s.objects.Add(model)
+	// an empty list would be chosen as often as the other lights while adding nothing to their density
+	if hl, ok := lights.(*hittable.HittableList); ok && hl.Len() > 0 {
+		s.lights.Add(hl)
+	}
+	s.world = nil
+	return nil
+}
+
+// Returns the problems of the models added to the scene which they were loaded despite, such as a missing MTL file
+// or texture map, which is drawn with MissingTexture instead.
+func (s *Scene) Warnings() []error {
+	return s.warnings
+}
+
+// Errors returned by AddModel and ImageTexture.
+type (
+	ModelParseError  = objLoader.ParseError
+	ImageDecodeError = ImageLoader.DecodeError
+)
+
+// ErrNoTriangles is returned by AddModel for files without any faces.
+var ErrNoTriangles = objLoader.ErrNoTriangles
+
+// Returns the objects of the scene grouped into a bounding volume hierarchy.
+func (s *Scene) bvh() hittable.Hittable {
+	if s.world == nil {
+		s.world = hittable.BuildBVH(s.objects)
+	}
+	return s.world
+}
+
+// Shapes.
+
+// A sphere.
+func Sphere(center *Vec3, radius float64, mat Material) Object {
+	return hittable.NewSphere(center, radius, mat)
+}
+
+// A sphere moving from center1 to center2 while the shutter is open, which is blurred by the motion.
+func MovingSphere(center1, center2 *Vec3, radius float64, mat Material) Object {
+	return hittable.NewMotionSphere(center1, center2, radius, mat)
+}
+
+// A parallelogram with a corner at q and sides u and v.
+func Quad(q, u, v *Vec3, mat Material) Object {
+	return hittable.NewQuad(q, u, v, mat)
+}
+
+// An axis aligned box with opposite corners a and b.
+func Box(a, b *Vec3, mat Material) Object {
+	return hittable.NewBox(a, b, mat)
+}
+
+// A triangle with the given corners.
+func Triangle(a, b, c *Vec3, mat Material) Object {
+	return hittable.NewTriangle([3]*vec.Vec3{a, b, c}, mat)
+}
+
+// A participating medium such as smoke or fog of the given density filling the boundary object.
+func Medium(boundary Object, density float64, albedo *Vec3) Object {
+	return hittable.ConstantMedium(boundary, density, albedo)
+}
+
+// Groups objects so they can be transformed together.
+func Group(objects ...Object) Object {
+	list := hittable.NewHittableList(len(objects))
+	for _, o := range objects {
+		list.Add(o)
+	}
+	return hittable.BuildBVH(list)
+}
+
+// Moves an object by offset.
+func Translate(object Object, offset *Vec3) Object {
+	return hittable.Translate(object, offset)
+}
+
+// Rotates an object by the given angle in degrees around the y axis.
+func RotateY(object Object, degrees float64) Object {
+	return hittable.RotateY(object, degrees)
+}
+
+// Materials.
+
+// A matte surface of the given color.
+func Lambertian(albedo *Vec3) Material {
+	return hittable.NewLambertian(albedo)
+}
+
+// A matte surface colored by a texture.
+func TexturedLambertian(tex Texture) Material {
+	return hittable.NewTexturedLambertian(tex)
+}
+
+// A reflective surface, blurred by fuzz from 0 (a mirror) to 1.
+func Metal(albedo *Vec3, fuzz float64) Material {
+	return hittable.NewMetal(albedo, fuzz)
+}
+
+// A clear refractive material such as glass (1.5) or water (1.33) with the given index of refraction.
+func Dielectric(refractionIndex float64) Material {
+	return hittable.NewDielectric(refractionIndex)
+}
+
+// A surface emitting light of the given color, components above 1 make brighter lights.
+func DiffuseLight(color *Vec3) Material {
+	return hittable.NewDiffuseLight(color)
+}
+
+// Textures.
+
+// A single color.
+func SolidColor(color *Vec3) Texture {
+	return hittable.NewSolidColor(color)
+}
+
+// A 3D checkerboard alternating between two textures, with squares of the given size.
+func Checkerboard(scale float64, even, odd Texture) Texture {
+	return hittable.NewCheckerboard(scale, even, odd)
+}
+
+// Loads an image file as a texture. A missing file gives an error matching fs.ErrNotExist,
+// one which can't be decoded an *ImageDecodeError. Callers can fall back to MissingTexture.
+func ImageTexture(filename string) (Texture, error) {
+	tex, err := hittable.NewImageTexture(filename)
+	if err != nil {
+		return nil, err
+	}
+	return tex, nil
+}
+
+// The magenta and black checkerboard used in place of textures which could not be loaded.
+func MissingTexture() Texture {
+	return hittable.NewMissingTexture()
+}
+
+// Grey Perlin noise, with features shrinking as scale grows.
+func Noise(scale float64) Texture {
+	return hittable.NewNoiseTexture(scale)
+}
+
+// Marble-like veins made from turbulent Perlin noise.
+func Marble(scale float64) Texture {
+	return hittable.NewNoiseTextureWithType(scale, hittable.MARBLE)
+}
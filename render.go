@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/aov"
+	"github.com/nsp5488/go_raytracer/internal/camera"
+	"github.com/nsp5488/go_raytracer/internal/cluster"
+	"github.com/nsp5488/go_raytracer/internal/denoise"
+	"github.com/nsp5488/go_raytracer/internal/encoder"
+	"github.com/nsp5488/go_raytracer/internal/framebuffer"
+	"github.com/nsp5488/go_raytracer/internal/postfx"
+	"github.com/nsp5488/go_raytracer/internal/progress/tui"
+	"github.com/nsp5488/go_raytracer/internal/scene"
+	"github.com/nsp5488/go_raytracer/internal/tiles"
+	"github.com/nsp5488/go_raytracer/internal/tonemap"
+)
+
+// Renders a built-in scene or a scene file, the command run when none is given. Returns the exit code.
+func render(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	cpuprofile := fs.String("cpuprofile", "", "Write cpu profile to file")
+	outFile := fs.String("o", "image.ppm", "Specify a custom output file, the format is chosen by its extension")
+	format := fs.String("format", "", "Override the output format (ppm, png, png16, jpeg, exr, hdr)")
+	threads := fs.Int("threads", 1, "Set the number of threads to allocate to rendering")
+	fs.IntVar(threads, "N", 1, "Shorthand for -threads")
+	sceneID := fs.Int("S", -1, "Set the scene to render, default will render a custom scene function")
+	sceneFile := fs.String("scene", "", "Render the scene described by this JSON file instead of a built-in scene")
+	exportFile := fs.String("export", "", "Write the selected scene to this JSON scene file instead of rendering it")
+	tileSize := fs.Int("tile", 32, "Size in pixels of the square tiles the image is split into for rendering")
+	tileOrder := fs.String("order", "scanline", "The order tiles are rendered in (scanline, spiral, hilbert)")
+	region := fs.String("region", "", "Only render the pixels in x0,y0,x1,y1 of the full frame, e.g. 100,50,228,178")
+	crop := fs.Bool("crop", false, "Write only the -region instead of a full-size image with the rest left transparent")
+	aovList := fs.String("aov", "", "Also write these output variables: depth, normal, shading_normal, albedo, position, object_id, material_id, uv")
+	aovLayers := fs.Bool("aov-layers", false, "Store the -aov outputs as layers of the EXR output instead of separate images")
+	tonemapOperator := fs.String("tonemap", "clamp", "Tone mapping operator for 8 and 16-bit formats (clamp, reinhard, reinhard-extended, aces, hable)")
+	exposure := fs.Float64("exposure", 0, "Exposure adjustment in stops, applied before tone mapping")
+	whitePoint := fs.Float64("white", 0, "Radiance mapped to white by the reinhard-extended and hable operators (default 4 and 11.2)")
+	postChain := fs.String("post", "", "Post-processing effects applied before tone mapping, e.g. \"bloom:threshold=1.5,strength=0.2;vignette\" (effects: bloom, vignette, chromatic, grain, sharpen, lut)")
+	denoiseStrength := fs.Float64("denoise", 0, "Denoise the image using albedo and normal buffers, from 0 (off) to 1 (fully filtered)")
+	denoiseIterations := fs.Int("denoise-iterations", denoise.DefaultOptions().Iterations, "Number of denoiser passes, each one doubles the size of the filter")
+	passSamples := fs.Int("progressive", 0, "Render progressively in passes of this many samples per pixel, writing a snapshot of the output after each pass")
+	snapshotInterval := fs.Duration("snapshot", 0, "Minimum time between progressive snapshots, e.g. 30s (default: after every pass)")
+	budget := fs.Duration("budget", 0, "Stop rendering after this much time, e.g. 2h, and write the samples taken so far")
+	checkpointFile := fs.String("checkpoint", "", "Periodically save the render state to this file between progressive passes")
+	checkpointInterval := fs.Duration("checkpoint-interval", 10*time.Minute, "Minimum time between checkpoints")
+	resumeFile := fs.String("resume", "", "Continue rendering from a checkpoint file, adding samples until the scene's sample count is reached")
+	width := fs.Int("width", 0, "Override the scene's image width in pixels")
+	aspect := fs.String("aspect", "", "Override the scene's aspect ratio, as width:height or a number, e.g. 16:9 or 1.5")
+	spp := fs.Int("spp", 0, "Override the scene's number of samples per pixel")
+	depth := fs.Int("depth", 0, "Override the scene's maximum number of bounces per ray")
+	projection := fs.String("projection", "", "Override the scene's projection (perspective, orthographic, fisheye, equirectangular)")
+	stereo := fs.String("stereo", "", "Override the scene's stereo layout, rendering both eyes' views into the image (mono, side-by-side, top-bottom)")
+	seed := fs.Uint64("seed", 0, "Seed the camera's sampler, renders with the same seed take the same camera samples (default random)")
+	listen := fs.String("listen", "", "Distribute the render to workers connecting to this address, e.g. :7878, instead of rendering it here (see the worker command)")
+	taskTimeout := fs.Duration("task-timeout", 0, "With -listen, re-issue the task of a worker taking longer than this over it (default: once its connection is lost)")
+	progressName := fs.String("progress", "auto", "How progress is reported: auto (a progress bar on a terminal, log lines otherwise), tui, log, json or none")
+
+	fs.Parse(args)
+	// settings given on the command line take precedence over the scene file's
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pprof.StartCPUProfile(f)
+		defer pprof.StopCPUProfile()
+	}
+
+	if *listen != "" {
+		for _, name := range []string{"region", "crop", "aov", "aov-layers", "denoise", "snapshot", "budget", "checkpoint", "resume", "export"} {
+			if explicit[name] {
+				log.Fatalf("-%s cannot be used with -listen", name)
+			}
+		}
+	}
+	if *checkpointFile != "" && *passSamples <= 0 {
+		log.Fatal("-checkpoint requires -progressive, checkpoints are taken between passes")
+	}
+
+	order, err := tiles.ParseOrder(*tileOrder)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var roi image.Rectangle
+	if *region != "" {
+		roi, err = parseRegion(*region)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else if *crop {
+		log.Fatal("-crop requires -region")
+	}
+
+	aovs, err := aov.ParseList(*aovList)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reporter, err := tui.ByName(*progressName, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c := camera.Camera{}
+	world, lights, sceneOutput, err := loadScene(*sceneFile, *sceneID, &c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := overrideCamera(&c, explicit, *width, *aspect, *spp, *depth, *projection, *stereo); err != nil {
+		log.Fatal(err)
+	}
+
+	// Pick an encoder for the output file.
+	var enc encoder.Encoder
+	if *format != "" {
+		enc, err = encoder.ByName(*format)
+	} else {
+		enc, err = encoder.ForFile(*outFile)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	op, err := tonemap.ParseOperator(*tonemapOperator)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mapper := tonemap.Mapper{Operator: op, Exposure: *exposure, WhitePoint: *whitePoint}
+	if m := sceneOutput.Tonemap; m != nil {
+		if !explicit["tonemap"] {
+			mapper.Operator = m.Operator
+		}
+		if !explicit["exposure"] {
+			mapper.Exposure = m.Exposure
+		}
+		if !explicit["white"] {
+			mapper.WhitePoint = m.WhitePoint
+		}
+	}
+	enc = encoder.WithTonemap(enc, mapper)
+	if _, ok := enc.(encoder.EXR); *aovLayers && !ok {
+		log.Fatal("-aov-layers requires EXR output")
+	}
+
+	post, err := postfx.Parse(*postChain)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !explicit["post"] {
+		post = append(post, sceneOutput.Post...)
+	}
+	if !explicit["denoise"] {
+		*denoiseStrength = sceneOutput.Denoise
+	}
+
+	if *listen != "" && *denoiseStrength > 0 {
+		log.Print("Warning: the scene asks for denoising, which is skipped when rendering with workers")
+		*denoiseStrength = 0
+	}
+
+	out := &output{filename: *outFile, enc: enc, aovs: aovs, layers: *aovLayers, post: post}
+	if *denoiseStrength > 0 {
+		opts := denoise.DefaultOptions()
+		opts.Strength = min(*denoiseStrength, 1)
+		opts.Iterations = *denoiseIterations
+		out.denoise = &opts
+		// the denoiser is guided by the albedo and normals of the first hit
+		for _, kind := range []aov.Kind{aov.ALBEDO, aov.SHADING_NORMAL} {
+			if !slices.Contains(aovs, kind) {
+				aovs = append(aovs, kind)
+			}
+		}
+	}
+
+	// Attempt to create the output file before rendering so an invalid path fails fast.
+	if *exportFile == "" {
+		file, err := os.Create(*outFile)
+		if err != nil {
+			log.Fatal("Error creating output file\n")
+		}
+		file.Close()
+	}
+
+	// Initialize the camera.
+	c.MaxThreads = *threads
+	c.Seed = *seed
+	c.Progress = reporter
+	c.TileSize = *tileSize
+	c.TileOrder = order
+	c.Region = roi
+	c.CropToRegion = *crop
+	c.AOVs = aovs
+	c.PassSamples = *passSamples
+	c.SnapshotInterval = *snapshotInterval
+	c.TimeBudget = *budget
+	c.CheckpointFile = *checkpointFile
+	c.CheckpointInterval = *checkpointInterval
+	if *resumeFile != "" {
+		cp, err := camera.LoadCheckpoint(*resumeFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.Resume = cp
+	}
+	if *passSamples > 0 {
+		c.Snapshot = func(_ *framebuffer.Framebuffer, passes int) {
+			if err := out.write(&c); err != nil {
+				log.Printf("Error writing snapshot after pass %d: %v", passes, err)
+			}
+		}
+	}
+
+	if *exportFile != "" {
+		if err := scene.Save(*exportFile, &c, world, lights); err != nil {
+			log.Fatalf("Error exporting scene: %v", err)
+		}
+		return 0
+	}
+
+	// Stop on the first interrupt and write what has been rendered, a second one ends the program.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	completion := c.Completion
+	if *listen != "" {
+		// tasks cover larger tiles than threads do, so each worker spreads them over its threads
+		taskSize := 128
+		if explicit["tile"] {
+			taskSize = *tileSize
+		}
+		co := &cluster.Coordinator{TileSize: taskSize, TileOrder: order, TaskTimeout: *taskTimeout, Progress: reporter}
+		err = distribute(ctx, co, &c, world, lights, *sceneFile, *listen)
+		completion = co.Completion
+	} else {
+		err = c.Render(ctx, world, lights)
+	}
+	interrupted := errors.Is(err, context.Canceled)
+	if errors.Is(err, camera.ErrCheckpoint) {
+		// the image is still worth writing
+		log.Printf("Warning: %v", err)
+	} else if err != nil && !interrupted {
+		log.Fatal(err)
+	}
+
+	// Write the image to the output file.
+	if interrupted {
+		out.marked = c.Region
+		if c.CropToRegion || c.Region.Empty() {
+			out.marked = c.Image().Bounds()
+		}
+	}
+	if err := out.write(&c); err != nil {
+		log.Fatalf("Error writing image: %v", err)
+	}
+	if interrupted {
+		log.Printf("Render interrupted at %.0f%%, wrote the partial image to %s", 100*completion(), *outFile)
+		return 130
+	}
+	return 0
+}
+
+// Applies the camera settings given on the command line over those of the scene.
+func overrideCamera(c *camera.Camera, explicit map[string]bool, width int, aspect string, spp, depth int, projection, stereo string) error {
+	if explicit["width"] {
+		if width <= 0 {
+			return fmt.Errorf("-width must be positive, got %d", width)
+		}
+		c.Width = width
+	}
+	if explicit["aspect"] {
+		ratio, err := parseAspect(aspect)
+		if err != nil {
+			return err
+		}
+		c.AspectRatio = ratio
+	}
+	if explicit["spp"] {
+		if spp <= 0 {
+			return fmt.Errorf("-spp must be positive, got %d", spp)
+		}
+		c.SamplesPerPixel = spp
+	}
+	if explicit["depth"] {
+		if depth <= 0 {
+			return fmt.Errorf("-depth must be positive, got %d", depth)
+		}
+		c.MaxDepth = depth
+	}
+	if explicit["projection"] {
+		p, err := camera.ParseProjection(projection)
+		if err != nil {
+			return err
+		}
+		c.Projection = p
+	}
+	if explicit["stereo"] {
+		s, err := camera.ParseStereo(stereo)
+		if err != nil {
+			return err
+		}
+		c.Stereo = s
+	}
+	return nil
+}
+
+// Parses an aspect ratio given as width:height, width/height or a single number.
+func parseAspect(s string) (float64, error) {
+	var w, h float64
+	var err error
+	if num, den, ok := strings.Cut(strings.ReplaceAll(s, "/", ":"), ":"); ok {
+		if w, err = strconv.ParseFloat(num, 64); err == nil {
+			h, err = strconv.ParseFloat(den, 64)
+		}
+	} else {
+		h = 1
+		w, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil || w <= 0 || h <= 0 || math.IsInf(w/h, 0) {
+		return 0, fmt.Errorf("invalid aspect ratio %q, expected width:height or a positive number", s)
+	}
+	return w / h, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/server"
+)
+
+// Serves a render queue over HTTP until interrupted. Returns the exit code.
+func serve(args []string) int {
+	config := server.DefaultConfig()
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	fs.IntVar(&config.Concurrency, "jobs", config.Concurrency, "Number of jobs rendered at the same time")
+	fs.IntVar(&config.Threads, "threads", runtime.NumCPU(), "Default number of threads each job renders on")
+	fs.IntVar(&config.Threads, "N", runtime.NumCPU(), "Shorthand for -threads")
+	fs.IntVar(&config.QueueSize, "queue", config.QueueSize, "Number of jobs which can wait to be rendered before submissions are refused")
+	fs.IntVar(&config.MaxPixels, "max-pixels", config.MaxPixels, "Largest image a job may render, in pixels")
+	fs.StringVar(&config.Dir, "dir", config.Dir, "Directory that image and model files named in submitted scenes are read from")
+	fs.Parse(args)
+	if config.Concurrency <= 0 || config.Threads <= 0 || config.QueueSize <= 0 || config.MaxPixels <= 0 {
+		log.Print("-jobs, -threads, -queue and -max-pixels must be positive")
+		return 2
+	}
+
+	srv := server.New(config)
+	httpServer := &http.Server{Addr: *addr, Handler: srv, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Print("Shutting down, cancelling the remaining jobs")
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+
+	log.Printf("Serving render jobs on http://%s", *addr)
+	err := httpServer.ListenAndServe()
+	srv.Close()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nsp5488/go_raytracer/internal/scene"
+)
+
+// Checks scene files without rendering them, printing every problem found. Returns the exit code.
+func validate(files []string) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-raytracer validate scene.json...")
+		return 2
+	}
+	code := 0
+	for _, file := range files {
+		problems, err := scene.ValidateFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			code = 1
+		} else {
+			fmt.Printf("%s: ok\n", file)
+		}
+	}
+	return code
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/nsp5488/go_raytracer/internal/cluster"
+)
+
+// Takes part in a render distributed by render -listen until it is done. Returns the exit code.
+func worker(args []string) int {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	addr := fs.String("connect", "localhost:7878", "Address of the coordinator, the -listen address of the render command")
+	threads := fs.Int("threads", runtime.NumCPU(), "Set the number of threads to allocate to rendering")
+	fs.IntVar(threads, "N", runtime.NumCPU(), "Shorthand for -threads")
+	dir := fs.String("dir", "", "Directory the scene's image and model files are read from (default: the coordinator's scene directory)")
+	name, _ := os.Hostname()
+	fs.StringVar(&name, "name", name, "Name identifying this worker in the coordinator's log")
+	retry := fs.Duration("retry", time.Minute, "Keep trying to connect to the coordinator for this long")
+	fs.Parse(args)
+	if *threads <= 0 {
+		log.Print("-threads must be positive")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := dial(ctx, *addr, *retry)
+	if err != nil {
+		log.Printf("Could not connect to the coordinator: %v", err)
+		return 1
+	}
+	log.Printf("Connected to the coordinator at %s", *addr)
+	w := &cluster.Worker{Name: name, Threads: *threads, Dir: *dir}
+	err = w.Serve(ctx, conn)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return 130
+	case err != nil:
+		log.Print(err)
+		return 1
+	}
+	log.Print("The render is done")
+	return 0
+}
+
+// Connects to addr, retrying every second until it succeeds, retry has elapsed or ctx is cancelled.
+func dial(ctx context.Context, addr string, retry time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(retry)
+	dialer := net.Dialer{}
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil || ctx.Err() != nil || time.Now().After(deadline) {
+			return conn, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}